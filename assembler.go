@@ -1,8 +1,12 @@
 package stackvm
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/pmuston/stackvm/internal/asm"
@@ -17,16 +21,37 @@ type Assembler interface {
 	// AssembleFile reads a file and assembles it.
 	AssembleFile(path string) (Program, error)
 
+	// AssembleListing assembles source and also returns a listing that
+	// shows, per source line, the address and encoded bytes of the
+	// instructions it produced next to the original text.
+	AssembleListing(source string) (Program, string, error)
+
+	// AssembleWithDiagnostics assembles source and also returns warnings
+	// about suspicious but valid code. Warnings never cause failure.
+	AssembleWithDiagnostics(source string) (Program, []Diagnostic, error)
+
 	// SetRegistry enables custom instruction names.
 	SetRegistry(registry InstructionRegistry)
+
+	// LastSymbols returns the symbols of the most recent successful
+	// assembly: every label mapped to its instruction address, and every
+	// .define, .data, and .bss constant mapped to its value. A label and a
+	// constant with the same name report the label. It returns nil before
+	// the first assembly and after a failed one.
+	LastSymbols() map[string]int
 }
 
-// AssemblerError represents an error during assembly.
+// AssemblerError represents an error during assembly. Every assembly
+// failure is reported as one, so errors.As can always recover the
+// position; Line and Column are 0 when the failure has no single source
+// position, such as an unreadable file.
 type AssemblerError struct {
 	Line    int
 	Column  int
 	Message string
 	Source  string // The problematic line
+	File    string // The file being assembled, if any
+	Err     error  // The underlying error
 }
 
 func (e *AssemblerError) Error() string {
@@ -36,16 +61,148 @@ func (e *AssemblerError) Error() string {
 	return fmt.Sprintf("assembler error at %d:%d: %s", e.Line, e.Column, e.Message)
 }
 
+// Unwrap returns the underlying error.
+func (e *AssemblerError) Unwrap() error {
+	return e.Err
+}
+
+// lineError reports a code generation error at a source position. The
+// message starts with the line number.
+func lineError(line, column int, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	return &AssemblerError{
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf("line %d: %v", line, err),
+		Err:     err,
+	}
+}
+
+// stmtError reports a code generation error at a statement. Statements
+// linked in from another file also name that file, as AssembleFile does.
+func stmtError(stmt asm.Statement, format string, args ...interface{}) error {
+	err := lineError(stmt.Line, stmt.Column, format, args...)
+	if stmt.File != "" {
+		asmErr := err.(*AssemblerError)
+		asmErr.Message = fmt.Sprintf("%s (in file %s)", asmErr.Message, stmt.File)
+		asmErr.File = stmt.File
+	}
+	return err
+}
+
+// Severity classifies a diagnostic.
+type Severity uint8
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+// String returns the severity name.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", s)
+	}
+}
+
+// Diagnostic is a message about a source location reported by the assembler.
+// Line is 0 for diagnostics about the program as a whole.
+type Diagnostic struct {
+	Line     int
+	Column   int
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
+}
+
+// assembly is the output of code generation.
+type assembly struct {
+	program     Program
+	lines       []int          // source line of each instruction
+	labels      map[string]int // label addresses, without generated labels
+	diagnostics []Diagnostic
+}
+
+// AssemblerOptions configures how source is assembled.
+type AssemblerOptions struct {
+	// IntPush assembles PUSH with an integer literal as PUSHI, so PUSH 5
+	// pushes Int 5 instead of Float 5. Float literals such as PUSH 5.0 or
+	// PUSH 2.5 still push floats.
+	IntPush bool
+
+	// Registry supplies custom instruction names (optional).
+	// Equivalent to calling SetRegistry on the assembler.
+	Registry InstructionRegistry
+
+	// RequireHalt rejects programs in which execution can run past the
+	// last instruction, which the VM otherwise treats as a halt. Every
+	// reachable path must end in HALT, EXIT, RET, or an unconditional jump.
+	RequireHalt bool
+
+	// Aliases maps extra mnemonics to standard or custom instruction
+	// names, as in {"BRZ": "JMPZ"}. They are added to the built-in aliases
+	// (JZ, JNZ, JUMP, MULT, RETURN), replacing any with the same name.
+	// Aliases are matched case-insensitively, so two names that differ
+	// only in case are an error, and never shadow a standard or custom
+	// instruction name.
+	Aliases map[string]string
+}
+
+// defaultAliases are the mnemonics accepted in place of standard names.
+var defaultAliases = map[string]string{
+	"JZ":     "JMPZ",
+	"JNZ":    "JMPNZ",
+	"JUMP":   "JMP",
+	"MULT":   "MUL",
+	"RETURN": "RET",
+}
+
 // assembler implements the Assembler interface.
 type assembler struct {
 	registry InstructionRegistry
+	options  AssemblerOptions
+	aliases  map[string]string // options.Aliases keyed by upper-case name
+	err      error             // invalid options, reported by every assembly
+	symbols  map[string]int    // symbols of the last assembly
 }
 
-// NewAssembler creates a new assembler.
+// NewAssembler creates a new assembler with default options.
 func NewAssembler() Assembler {
 	return &assembler{}
 }
 
+// NewAssemblerWithOptions creates an assembler with custom options.
+// Aliases that differ only in case are an error, reported when assembling.
+func NewAssemblerWithOptions(opts AssemblerOptions) Assembler {
+	a := &assembler{
+		registry: opts.Registry,
+		options:  opts,
+		aliases:  make(map[string]string, len(opts.Aliases)),
+	}
+	first := make(map[string]string, len(opts.Aliases)) // upper-case name -> key
+	for _, alias := range slices.Sorted(maps.Keys(opts.Aliases)) {
+		name := strings.ToUpper(alias)
+		if prev, exists := first[name]; exists && a.err == nil {
+			err := fmt.Errorf("aliases %q and %q differ only in case", prev, alias)
+			a.err = &AssemblerError{Message: err.Error(), Err: err}
+		}
+		first[name] = alias
+		a.aliases[name] = opts.Aliases[alias]
+	}
+	return a
+}
+
 // SetRegistry sets the instruction registry for custom opcodes.
 func (a *assembler) SetRegistry(registry InstructionRegistry) {
 	a.registry = registry
@@ -53,6 +210,31 @@ func (a *assembler) SetRegistry(registry InstructionRegistry) {
 
 // Assemble parses and compiles source to a program.
 func (a *assembler) Assemble(source string) (Program, error) {
+	out, err := a.assemble(source)
+	if err != nil {
+		return nil, err
+	}
+	return out.program, nil
+}
+
+// AssembleWithDiagnostics assembles source and returns any warnings.
+func (a *assembler) AssembleWithDiagnostics(source string) (Program, []Diagnostic, error) {
+	out, err := a.assemble(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.program, out.diagnostics, nil
+}
+
+// LastSymbols returns the symbols of the last successful assembly.
+func (a *assembler) LastSymbols() map[string]int {
+	return a.symbols
+}
+
+// assemble runs the lexer, parser, and code generator over source.
+func (a *assembler) assemble(source string) (*assembly, error) {
+	a.symbols = nil
+
 	// Lexical analysis
 	lexer := asm.NewLexer(source)
 	tokens, err := lexer.Tokenize()
@@ -68,76 +250,437 @@ func (a *assembler) Assemble(source string) (Program, error) {
 	}
 
 	// Code generation
-	program, err := a.generate(statements)
+	out, err := a.generate(statements)
 	if err != nil {
 		return nil, a.wrapError(err, source)
 	}
 
-	return program, nil
+	a.symbols = make(map[string]int)
+	for name, value := range parser.Defines() {
+		a.symbols[name] = int(value)
+	}
+	for name, addr := range out.labels {
+		a.symbols[name] = addr
+	}
+	return out, nil
+}
+
+// AssembleListing assembles source and returns a listing alongside the
+// program. Each source line is printed with the address and encoded bytes
+// of the instructions it produced; lines that produce no code (labels,
+// directives, comments) are printed with blank columns.
+func (a *assembler) AssembleListing(source string) (Program, string, error) {
+	out, err := a.assemble(source)
+	if err != nil {
+		return nil, "", err
+	}
+	program := out.program
+
+	// Group instruction addresses by source line.
+	byLine := make(map[int][]int)
+	for addr, line := range out.lines {
+		byLine[line] = append(byLine[line], addr)
+	}
+
+	var sb strings.Builder
+	blank := strings.Repeat(" ", listingPrefixWidth)
+	for i, text := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+		addrs := byLine[i+1]
+		if len(addrs) == 0 {
+			sb.WriteString(strings.TrimRight(blank+text, " "))
+			sb.WriteString("\n")
+			continue
+		}
+		for n, addr := range addrs {
+			inst, _ := program.InstructionAt(addr)
+			row := fmt.Sprintf("%04d  % x  ", addr, appendInstruction(nil, inst))
+			if n == 0 {
+				row += text
+			}
+			sb.WriteString(strings.TrimRight(row, " "))
+			sb.WriteString("\n")
+		}
+	}
+
+	return program, sb.String(), nil
 }
 
+// listingPrefixWidth is the width of the address and bytes columns of an
+// assembler listing: "0000  xx xx xx xx xx  ".
+const listingPrefixWidth = 4 + 2 + encodedInstructSize*3 - 1 + 2
+
 // AssembleFile reads a file and assembles it.
 func (a *assembler) AssembleFile(path string) (Program, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+		return nil, &AssemblerError{
+			Message: fmt.Sprintf("failed to read file %s: %v", path, err),
+			File:    path,
+			Err:     err,
+		}
 	}
 
 	program, err := a.Assemble(string(data))
 	if err != nil {
 		// Add file path to error message
-		if asmErr, ok := err.(*AssemblerError); ok {
-			asmErr.Message = fmt.Sprintf("%s (in file %s)", asmErr.Message, path)
-			return nil, asmErr
-		}
-		return nil, fmt.Errorf("failed to assemble %s: %w", path, err)
+		asmErr := err.(*AssemblerError)
+		asmErr.Message = fmt.Sprintf("%s (in file %s)", asmErr.Message, path)
+		asmErr.File = path
+		return nil, asmErr
 	}
 
 	return program, nil
 }
 
-// generate generates a program from parsed statements.
-func (a *assembler) generate(statements []asm.Statement) (Program, error) {
+// generate generates a program from parsed statements, recording the
+// source line of each instruction and any warnings.
+func (a *assembler) generate(statements []asm.Statement) (*assembly, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	// Data slots take addresses in source order, so collect them before
+	// subroutine bodies are moved.
+	var data []Value
+	for _, stmt := range statements {
+		switch stmt.Type {
+		case asm.StmtData:
+			data = append(data, operandValue(stmt.Operand))
+		case asm.StmtBss:
+			for range stmt.Operand.Number {
+				data = append(data, NilValue())
+			}
+		}
+	}
+
+	statements, err := layoutSubroutines(statements)
+	if err != nil {
+		return nil, err
+	}
+	statements, err = resolveLocalLabels(statements)
+	if err != nil {
+		return nil, err
+	}
+
 	builder := NewProgramBuilder()
+	for _, v := range data {
+		builder.Data(v)
+	}
 	opcodeMap := makeOpcodeMap()
 	customMap := make(map[string]Opcode)
 
-	// Build custom opcode map if registry is set
+	// Build custom opcode map if registry is set. If two opcodes share a
+	// name, the lower opcode wins.
 	if a.registry != nil {
 		names := a.registry.Names()
-		for opcode, name := range names {
-			customMap[strings.ToUpper(name)] = opcode
+		for _, opcode := range a.registry.List() {
+			name, ok := names[opcode]
+			if !ok {
+				continue
+			}
+			if _, exists := customMap[strings.ToUpper(name)]; !exists {
+				customMap[strings.ToUpper(name)] = opcode
+			}
 		}
 	}
 
 	// Process statements
+	skipLabel := "" // pending SKIPZ target, placed after the next instruction
+	var skipStmt asm.Statement
+	generated := 0
+	out := &assembly{}
+	var sources []asm.Statement // statement that emitted each instruction
+	var entryStmt asm.Statement
+	entrySet := false
+	metaSet := make(map[string]bool)
 	for _, stmt := range statements {
 		if stmt.Type == asm.StmtLabel {
 			builder.Label(stmt.Label)
+		} else if stmt.Type == asm.StmtMeta {
+			if metaSet[stmt.Label] {
+				return nil, stmtError(stmt, "duplicate .%s directive", stmt.Label)
+			}
+			*metadataField(&builder.metadata, stmt.Label) = stmt.Text
+			metaSet[stmt.Label] = true
+		} else if stmt.Type == asm.StmtEntry {
+			if entrySet {
+				return nil, stmtError(stmt, "duplicate .entry directive")
+			}
+			builder.SetEntry(stmt.Label)
+			entryStmt = stmt
+			entrySet = true
 		} else if stmt.Type == asm.StmtInstruction {
-			if err := a.emitInstruction(builder, stmt, opcodeMap, customMap); err != nil {
-				return nil, fmt.Errorf("line %d: %w", stmt.Line, err)
+			pending := skipLabel
+			skipLabel = ""
+			if strings.ToUpper(stmt.Opcode) == "SKIPZ" {
+				if stmt.Operand != nil {
+					return nil, stmtError(stmt, "SKIPZ does not accept an operand")
+				}
+				skipLabel = fmt.Sprintf("%sskip%d", generatedLabelPrefix, generated)
+				skipStmt = stmt
+				generated++
+				builder.JmpZ(skipLabel)
+			} else if err := a.emitInstruction(builder, stmt, opcodeMap, customMap); err != nil {
+				return nil, stmtError(stmt, "%w", err)
+			} else if op := stmt.Operand; op != nil && op.Type == asm.OperandNumber && op.IsFloat {
+				if opcode := builder.instructions[len(builder.instructions)-1].Opcode; opcode != OpPUSH && opcode != OpPUSHC {
+					out.warn(stmt, "float %v truncated to %d in %s", op.FloatValue, int64(op.FloatValue), opcode)
+				}
+			}
+			if pending != "" {
+				builder.Label(pending)
+			}
+			for len(out.lines) < len(builder.instructions) {
+				out.lines = append(out.lines, stmt.Line)
+				sources = append(sources, stmt)
 			}
 		}
 	}
+	if skipLabel != "" {
+		return nil, stmtError(skipStmt, "SKIPZ must be followed by an instruction")
+	}
+
+	// Report unresolved labels at the statement that uses them; Build
+	// would only know the instruction index.
+	for _, ref := range builder.references {
+		if _, ok := builder.labels[ref.labelName]; !ok {
+			src := sources[ref.instIndex]
+			return nil, stmtError(src, "%w: %s", ErrUnresolvedLabel, ref.labelName)
+		}
+	}
+	if _, ok := builder.labels[builder.entry]; entrySet && !ok {
+		return nil, stmtError(entryStmt, "%w: %s", ErrUnresolvedLabel, builder.entry)
+	}
 
 	// Build the program (resolves label references)
 	program, err := builder.Build()
 	if err != nil {
 		return nil, err
 	}
+	out.program = program
+	out.labels = make(map[string]int, len(builder.labels))
+	for name, addr := range builder.labels {
+		if !strings.HasPrefix(name, generatedLabelPrefix) {
+			out.labels[name] = addr
+		}
+	}
 
-	return program, nil
+	if a.options.RequireHalt {
+		if addr, ok := fallsOffEnd(program); ok {
+			if addr < 0 {
+				return nil, fmt.Errorf("program has no instructions")
+			}
+			inst, _ := program.InstructionAt(addr)
+			return nil, stmtError(sources[addr], "execution can run past the end of the program after %s", inst.Opcode)
+		}
+	}
+
+	a.lint(out, statements, builder)
+	return out, nil
+}
+
+// lint adds warnings for labels that are never referenced and for programs
+// with no HALT or EXIT.
+func (a *assembler) lint(out *assembly, statements []asm.Statement, builder *ProgramBuilder) {
+	referenced := make(map[string]bool)
+	for _, ref := range builder.references {
+		referenced[ref.labelName] = true
+	}
+	referenced[builder.entry] = true
+
+	for _, stmt := range statements {
+		if stmt.Type == asm.StmtLabel && !referenced[stmt.Label] && !strings.HasPrefix(stmt.Label, generatedLabelPrefix) {
+			out.warn(stmt, "label '%s' is never referenced", stmt.Label)
+		}
+	}
+
+	if len(builder.instructions) == 0 {
+		return
+	}
+	for _, inst := range builder.instructions {
+		if inst.Opcode == OpHALT || inst.Opcode == OpEXIT {
+			return
+		}
+	}
+	out.diagnostics = append(out.diagnostics, Diagnostic{
+		Severity: SeverityWarning,
+		Message:  "program has no HALT or EXIT",
+	})
+}
+
+// fallsOffEnd reports whether execution can run past the last instruction
+// of program, and the address of the lowest reachable instruction that
+// leads there (-1 for an empty program).
+func fallsOffEnd(program Program) (int, bool) {
+	insts := program.Instructions()
+	entry := 0
+	if ep, ok := program.(EntryPointer); ok {
+		entry = ep.EntryPoint()
+	}
+	if entry >= len(insts) {
+		return -1, true
+	}
+
+	reach := reachable(insts, entry)
+	for pc := range insts {
+		if !reach[pc] {
+			continue
+		}
+		for _, next := range successors(insts, pc) {
+			if next == len(insts) {
+				return pc, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// operandValue converts a numeric operand to a float or int Value.
+func operandValue(op *asm.Operand) Value {
+	if op.IsFloat {
+		return FloatValue(op.FloatValue)
+	}
+	return IntValue(op.Number)
+}
+
+// metadataField returns the ProgramMetadata field set by a metadata
+// directive.
+func metadataField(metadata *ProgramMetadata, directive string) *string {
+	switch directive {
+	case "name":
+		return &metadata.Name
+	case "version":
+		return &metadata.Version
+	case "author":
+		return &metadata.Author
+	default:
+		return &metadata.Description
+	}
+}
+
+// layoutSubroutines moves each ".sub NAME ... .endsub" body after the main
+// code, behind a guard JMP so execution can't fall into it. Each body starts
+// with the label NAME and gets a trailing RET if it doesn't already end with
+// one.
+func layoutSubroutines(statements []asm.Statement) ([]asm.Statement, error) {
+	var main, subs []asm.Statement
+	var open *asm.Statement
+	var last string // opcode of the last instruction in the open body
+	for i := range statements {
+		stmt := statements[i]
+		switch stmt.Type {
+		case asm.StmtSub:
+			if open != nil {
+				return nil, stmtError(stmt, ".sub %s inside .sub %s", stmt.Label, open.Label)
+			}
+			open, last = &statements[i], ""
+			subs = append(subs, asm.Statement{Type: asm.StmtLabel, Label: stmt.Label, Line: stmt.Line, Column: stmt.Column, File: stmt.File})
+		case asm.StmtEndSub:
+			if open == nil {
+				return nil, stmtError(stmt, ".endsub without .sub")
+			}
+			if last != "RET" {
+				subs = append(subs, asm.Statement{Type: asm.StmtInstruction, Opcode: "RET", Line: stmt.Line, Column: stmt.Column, File: stmt.File})
+			}
+			open = nil
+		default:
+			if open == nil {
+				main = append(main, stmt)
+				continue
+			}
+			if stmt.Type == asm.StmtInstruction {
+				last = strings.ToUpper(stmt.Opcode)
+			}
+			subs = append(subs, stmt)
+		}
+	}
+	if open != nil {
+		return nil, stmtError(*open, ".sub %s is missing .endsub", open.Label)
+	}
+	if len(subs) == 0 {
+		return statements, nil
+	}
+
+	end := generatedLabelPrefix + "subs_end"
+	guard := subs[0]
+	main = append(main, asm.Statement{
+		Type:    asm.StmtInstruction,
+		Opcode:  "JMP",
+		Operand: &asm.Operand{Type: asm.OperandLabel, Label: end},
+		Line:    guard.Line,
+		Column:  guard.Column,
+		File:    guard.File,
+	})
+	main = append(main, subs...)
+	return append(main, asm.Statement{Type: asm.StmtLabel, Label: end, Line: guard.Line}), nil
+}
+
+// resolveLocalLabels gives each numeric local label ("1:") a unique
+// generated name and points every "1f" or "1b" reference at the nearest
+// matching definition after or before it.
+func resolveLocalLabels(statements []asm.Statement) ([]asm.Statement, error) {
+	defs := make(map[string][]int) // label -> statement indices, ascending
+	for i, stmt := range statements {
+		if stmt.Type == asm.StmtLabel && asm.IsLocalLabel(stmt.Label) {
+			defs[stmt.Label] = append(defs[stmt.Label], i)
+		}
+	}
+
+	generatedName := func(label string, index int) string {
+		return fmt.Sprintf("%slocal%s_%d", generatedLabelPrefix, label, index)
+	}
+
+	resolved := make([]asm.Statement, len(statements))
+	for i, stmt := range statements {
+		if stmt.Type == asm.StmtLabel && asm.IsLocalLabel(stmt.Label) {
+			stmt.Label = generatedName(stmt.Label, i)
+		} else if op := stmt.Operand; op != nil && (op.Type == asm.OperandLabel || op.Type == asm.OperandAddress) && asm.IsLocalRef(op.Label) {
+			label, forward := op.Label[:len(op.Label)-1], op.Label[len(op.Label)-1] == 'f'
+			target := -1
+			for _, d := range defs[label] {
+				if forward && d > i {
+					target = d
+					break
+				}
+				if !forward && d < i {
+					target = d
+				}
+			}
+			if target < 0 {
+				direction := "before"
+				if forward {
+					direction = "after"
+				}
+				return nil, stmtError(stmt, "no local label '%s:' %s %s", label, direction, op.Label)
+			}
+			operand := *op
+			operand.Label = generatedName(label, target)
+			stmt.Operand = &operand
+		}
+		resolved[i] = stmt
+	}
+	return resolved, nil
+}
+
+// warn records a warning at a statement's position.
+func (out *assembly) warn(stmt asm.Statement, format string, args ...interface{}) {
+	out.diagnostics = append(out.diagnostics, Diagnostic{
+		Line:     stmt.Line,
+		Column:   stmt.Column,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf(format, args...),
+	})
 }
 
 func (a *assembler) emitInstruction(builder *ProgramBuilder, stmt asm.Statement, opcodeMap, customMap map[string]Opcode) error {
 	opcodeName := strings.ToUpper(stmt.Opcode)
 
-	// Check for standard opcode
-	opcode, exists := opcodeMap[opcodeName]
+	opcode, exists := lookupOpcode(opcodeName, opcodeMap, customMap)
 	if !exists {
-		// Check for custom opcode
-		opcode, exists = customMap[opcodeName]
+		// Fall back to an alias for a standard or custom name
+		if name, ok := a.alias(opcodeName); ok {
+			opcode, exists = lookupOpcode(strings.ToUpper(name), opcodeMap, customMap)
+		}
 		if !exists {
 			return fmt.Errorf("unknown opcode '%s'", stmt.Opcode)
 		}
@@ -151,6 +694,26 @@ func (a *assembler) emitInstruction(builder *ProgramBuilder, stmt asm.Statement,
 	}
 }
 
+// lookupOpcode finds a standard or custom opcode by upper-case name.
+// Standard names take precedence.
+func lookupOpcode(name string, opcodeMap, customMap map[string]Opcode) (Opcode, bool) {
+	if opcode, ok := opcodeMap[name]; ok {
+		return opcode, true
+	}
+	opcode, ok := customMap[name]
+	return opcode, ok
+}
+
+// alias returns the instruction name an upper-case alias stands for,
+// preferring the assembler options over the built-in aliases.
+func (a *assembler) alias(name string) (string, bool) {
+	if target, ok := a.aliases[name]; ok {
+		return target, true
+	}
+	target, ok := defaultAliases[name]
+	return target, ok
+}
+
 func (a *assembler) emitNoOperand(builder *ProgramBuilder, opcode Opcode) error {
 	switch opcode {
 	// Stack operations
@@ -164,6 +727,8 @@ func (a *assembler) emitNoOperand(builder *ProgramBuilder, opcode Opcode) error
 		builder.Over()
 	case OpROT:
 		builder.Rot()
+	case OpEMPTY:
+		builder.Empty()
 
 	// Arithmetic
 	case OpADD:
@@ -184,6 +749,10 @@ func (a *assembler) emitNoOperand(builder *ProgramBuilder, opcode Opcode) error
 		builder.Inc()
 	case OpDEC:
 		builder.Dec()
+	case OpTOF:
+		builder.ToFloat()
+	case OpTOI:
+		builder.ToInt()
 
 	// Logic
 	case OpAND:
@@ -194,6 +763,10 @@ func (a *assembler) emitNoOperand(builder *ProgramBuilder, opcode Opcode) error
 		builder.Not()
 	case OpXOR:
 		builder.Xor()
+	case OpSHR:
+		builder.Shr()
+	case OpUSHR:
+		builder.Ushr()
 
 	// Comparison
 	case OpEQ:
@@ -222,6 +795,18 @@ func (a *assembler) emitNoOperand(builder *ProgramBuilder, opcode Opcode) error
 		builder.Halt()
 	case OpNOP:
 		builder.Nop()
+	case OpEXIT:
+		builder.Exit()
+
+	// System
+	case OpPRINT:
+		builder.Print()
+	case OpFUEL:
+		builder.Fuel()
+
+	// String
+	case OpCONCAT:
+		builder.Concat()
 
 	// Math
 	case OpSQRT:
@@ -273,28 +858,121 @@ func (a *assembler) emitWithOperand(builder *ProgramBuilder, opcode Opcode, oper
 		}
 		if operand.IsFloat {
 			builder.Push(operand.FloatValue)
+		} else if a.options.IntPush {
+			value, err := operandInt(opcode, operand)
+			if err != nil {
+				return err
+			}
+			builder.PushInt(value)
 		} else {
 			builder.Push(float64(operand.Number))
 		}
 
 	case OpPUSHI:
+		if operand.Type == asm.OperandAddress {
+			builder.PushLabelAddr(operand.Label)
+			return nil
+		}
+		if operand.Type != asm.OperandNumber {
+			return fmt.Errorf("PUSHI requires a numeric operand or @label")
+		}
+		value, err := operandInt(opcode, operand)
+		if err != nil {
+			return err
+		}
+		builder.PushInt(value)
+
+	case OpPUSHC:
+		// The operand is the constant's literal, not a pool index.
 		if operand.Type != asm.OperandNumber {
-			return fmt.Errorf("PUSHI requires a numeric operand")
+			return fmt.Errorf("PUSHC requires a numeric literal")
 		}
-		builder.PushInt(operand.Number)
+		builder.PushConst(operandValue(operand))
 
 	// Memory operations with static address
 	case OpLOAD:
 		if operand.Type != asm.OperandNumber {
 			return fmt.Errorf("LOAD requires a numeric operand")
 		}
-		builder.Load(int(operand.Number))
+		value, err := operandInt(opcode, operand)
+		if err != nil {
+			return err
+		}
+		builder.Load(int(value))
 
 	case OpSTORE:
 		if operand.Type != asm.OperandNumber {
 			return fmt.Errorf("STORE requires a numeric operand")
 		}
-		builder.Store(int(operand.Number))
+		value, err := operandInt(opcode, operand)
+		if err != nil {
+			return err
+		}
+		builder.Store(int(value))
+
+	case OpMEMCLR:
+		if operand.Type != asm.OperandNumber {
+			return fmt.Errorf("MEMCLR requires a numeric operand")
+		}
+		value, err := operandInt(opcode, operand)
+		if err != nil {
+			return err
+		}
+		builder.MemClr(int(value))
+
+	case OpRLOAD, OpRSTORE:
+		if operand.Type != asm.OperandNumber || operand.IsFloat || operand.Number < 0 {
+			return fmt.Errorf("%s requires a non-negative register number", opcode)
+		}
+		value, err := operandInt(opcode, operand)
+		if err != nil {
+			return err
+		}
+		if opcode == OpRLOAD {
+			builder.RLoad(int(value))
+		} else {
+			builder.RStore(int(value))
+		}
+
+	case OpTRAP:
+		if operand.Type != asm.OperandNumber || operand.IsFloat {
+			return fmt.Errorf("TRAP requires an integer trap number")
+		}
+		value, err := operandInt(opcode, operand)
+		if err != nil {
+			return err
+		}
+		builder.Trap(int32(value))
+
+	case OpDUPN:
+		if operand.Type != asm.OperandNumber || operand.IsFloat || operand.Number < 0 {
+			return fmt.Errorf("DUPN requires a non-negative count")
+		}
+		value, err := operandInt(opcode, operand)
+		if err != nil {
+			return err
+		}
+		builder.DupN(int(value))
+
+	case OpSWAPN:
+		if operand.Type != asm.OperandNumber || operand.IsFloat || operand.Number < 0 {
+			return fmt.Errorf("SWAPN requires a non-negative depth")
+		}
+		value, err := operandInt(opcode, operand)
+		if err != nil {
+			return err
+		}
+		builder.SwapN(int(value))
+
+	case OpTYPECHK:
+		if operand.Type != asm.OperandNumber || operand.IsFloat || operand.Number < 0 || operand.Number > 255 {
+			return fmt.Errorf("TYPECHK requires a value type number (0-255)")
+		}
+		builder.TypeCheck(ValueType(operand.Number))
+
+	// Dynamic memory operations take their address from the stack
+	case OpLOADD, OpSTORED:
+		return fmt.Errorf("%s does not accept an operand; the address is popped from the stack", opcode)
 
 	// Control flow with labels
 	case OpJMP:
@@ -321,13 +999,32 @@ func (a *assembler) emitWithOperand(builder *ProgramBuilder, opcode Opcode, oper
 		}
 		builder.Call(operand.Label)
 
+	// PC-relative control flow
+	case OpJMPR, OpJMPZR, OpJMPNZR:
+		if operand.Type != asm.OperandOffset {
+			return fmt.Errorf("%s requires a relative operand ($+n or $-n)", opcode)
+		}
+		offset := int32(operand.Number)
+		switch opcode {
+		case OpJMPR:
+			builder.JmpR(offset)
+		case OpJMPZR:
+			builder.JmpZR(offset)
+		default:
+			builder.JmpNZR(offset)
+		}
+
 	default:
 		// For custom instructions, use the Custom method
 		if opcode >= 128 {
 			if operand.Type != asm.OperandNumber {
 				return fmt.Errorf("custom instruction requires a numeric operand")
 			}
-			builder.Custom(opcode, int32(operand.Number))
+			value, err := operandInt(opcode, operand)
+			if err != nil {
+				return err
+			}
+			builder.Custom(opcode, int32(value))
 		} else {
 			return fmt.Errorf("opcode %d does not accept operands", opcode)
 		}
@@ -336,34 +1033,56 @@ func (a *assembler) emitWithOperand(builder *ProgramBuilder, opcode Opcode, oper
 	return nil
 }
 
-// wrapError wraps an error in an AssemblerError if possible.
+// operandInt returns a numeric operand as an integer, truncating floats
+// toward zero. Values that do not fit in a 32-bit operand are an error.
+func operandInt(opcode Opcode, operand *asm.Operand) (int64, error) {
+	value := float64(operand.Number)
+	if operand.IsFloat {
+		value = math.Trunc(operand.FloatValue)
+	}
+	if !(value >= math.MinInt32 && value <= math.MaxInt32) {
+		if operand.IsFloat {
+			return 0, fmt.Errorf("%w: %v does not fit in %s's 32-bit operand", ErrInvalidOperand, operand.FloatValue, opcode)
+		}
+		return 0, fmt.Errorf("%w: %d does not fit in %s's 32-bit operand", ErrInvalidOperand, operand.Number, opcode)
+	}
+	return int64(value), nil
+}
+
+// wrapError converts an error from the lexer, parser, or code generator
+// to an AssemblerError, keeping the source position where one is known.
 func (a *assembler) wrapError(err error, source string) error {
 	if err == nil {
 		return nil
 	}
+	if asmErr, ok := err.(*AssemblerError); ok {
+		return asmErr
+	}
 
-	// Try to extract line information from error message
-	// Errors from the lexer/parser/codegen should include line numbers
-	// For now, just wrap in a generic AssemblerError
-	return &AssemblerError{
-		Line:    0,
-		Column:  0,
-		Message: err.Error(),
-		Source:  "",
+	asmErr := &AssemblerError{Message: err.Error(), Err: err}
+	var posErr *asm.Error
+	if errors.As(err, &posErr) {
+		asmErr.Line, asmErr.Column = posErr.Line, posErr.Column
 	}
+	return asmErr
 }
 
 // makeOpcodeMap creates a map of opcode names to opcode values.
 func makeOpcodeMap() map[string]Opcode {
 	return map[string]Opcode{
 		// Stack operations
-		"PUSH":   OpPUSH,
-		"PUSHI":  OpPUSHI,
-		"POP":    OpPOP,
-		"DUP":    OpDUP,
-		"SWAP":   OpSWAP,
-		"OVER":   OpOVER,
-		"ROT":    OpROT,
+		"PUSH":    OpPUSH,
+		"PUSHI":   OpPUSHI,
+		"POP":     OpPOP,
+		"DUP":     OpDUP,
+		"SWAP":    OpSWAP,
+		"OVER":    OpOVER,
+		"ROT":     OpROT,
+		"PUSHC":   OpPUSHC,
+		"TYPECHK": OpTYPECHK,
+		"DUPN":    OpDUPN,
+		"SWAPN":   OpSWAPN,
+		"EMPTY":   OpEMPTY,
 
 		// Arithmetic
 		"ADD": OpADD,
@@ -375,12 +1094,16 @@ func makeOpcodeMap() map[string]Opcode {
 		"ABS": OpABS,
 		"INC": OpINC,
 		"DEC": OpDEC,
+		"TOF": OpTOF,
+		"TOI": OpTOI,
 
 		// Logic
-		"AND": OpAND,
-		"OR":  OpOR,
-		"NOT": OpNOT,
-		"XOR": OpXOR,
+		"AND":  OpAND,
+		"OR":   OpOR,
+		"NOT":  OpNOT,
+		"XOR":  OpXOR,
+		"SHR":  OpSHR,
+		"USHR": OpUSHR,
 
 		// Comparison
 		"EQ": OpEQ,
@@ -395,6 +1118,9 @@ func makeOpcodeMap() map[string]Opcode {
 		"STORE":  OpSTORE,
 		"LOADD":  OpLOADD,
 		"STORED": OpSTORED,
+		"MEMCLR": OpMEMCLR,
+		"RLOAD":  OpRLOAD,
+		"RSTORE": OpRSTORE,
 
 		// Control flow
 		"JMP":   OpJMP,
@@ -404,6 +1130,12 @@ func makeOpcodeMap() map[string]Opcode {
 		"RET":   OpRET,
 		"HALT":  OpHALT,
 		"NOP":   OpNOP,
+		"EXIT":  OpEXIT,
+
+		// Relative control flow
+		"JMPR":   OpJMPR,
+		"JMPZR":  OpJMPZR,
+		"JMPNZR": OpJMPNZR,
 
 		// Math functions
 		"SQRT":  OpSQRT,
@@ -424,5 +1156,13 @@ func makeOpcodeMap() map[string]Opcode {
 		"CEIL":  OpCEIL,
 		"ROUND": OpROUND,
 		"TRUNC": OpTRUNC,
+
+		// System
+		"TRAP":  OpTRAP,
+		"PRINT": OpPRINT,
+		"FUEL":  OpFUEL,
+
+		// String
+		"CONCAT": OpCONCAT,
 	}
 }
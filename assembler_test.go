@@ -1,7 +1,11 @@
 package stackvm
 
 import (
+	"errors"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -98,6 +102,54 @@ func TestAssembleWithComments(t *testing.T) {
 	}
 }
 
+func TestAssembleBlockComments(t *testing.T) {
+	source := `
+		PUSHI 1
+		/* PUSHI 2
+		   ADD */
+		PUSHI 3 /* inline */ ; and a line comment
+		ADD
+		/**/ HALT /* two */ /* on one line */
+	`
+	program, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	want := []Instruction{
+		NewInstruction(OpPUSHI, 1),
+		NewInstruction(OpPUSHI, 3),
+		NewInstruction(OpADD, 0),
+		NewInstruction(OpHALT, 0),
+	}
+	got := program.Instructions()
+	if len(got) != len(want) {
+		t.Fatalf("Instructions() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("instruction %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"unterminated", "PUSHI 1\n  /* PUSHI 2\nHALT\n", "unterminated block comment starting at 2:3"},
+		{"not nested", "/* /* */ */\nHALT", "unexpected token OPERATOR at 1:10"},
+		{"line after block", "/*\n\n*/ PUSHI 1\nBOGUS", "line 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAssembler().Assemble(tt.source)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Assemble() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestAssembleMemoryOperations(t *testing.T) {
 	asm := NewAssembler()
 
@@ -233,6 +285,134 @@ func TestAssembleUnresolvedLabel(t *testing.T) {
 	}
 }
 
+func TestAssembleDynamicMemoryOperand(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"PUSHI 1\nLOADD 5", "line 2: LOADD does not accept an operand; the address is popped from the stack"},
+		{"STORED 3", "line 1: STORED does not accept an operand; the address is popped from the stack"},
+	}
+
+	for _, tt := range tests {
+		_, err := NewAssembler().Assemble(tt.source)
+		if err == nil {
+			t.Errorf("Assemble(%q) should fail", tt.source)
+			continue
+		}
+		asmErr, ok := err.(*AssemblerError)
+		if !ok {
+			t.Fatalf("error type = %T, want *AssemblerError", err)
+		}
+		if asmErr.Message != tt.want {
+			t.Errorf("Message = %q, want %q", asmErr.Message, tt.want)
+		}
+	}
+}
+
+func TestAssemblerErrorAs(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		wantLine   int
+		wantColumn int
+		wantErr    error
+	}{
+		{"lexer", "PUSHI 1\n  PUSHI `", 2, 9, nil},
+		{"parser", "NOP\n.bogus\nHALT", 2, 1, nil},
+		{"constant expression", "PUSHI 1\nLOAD 4/2", 2, 7, nil},
+		{"codegen", "NOP\nNOP\n  FROB 3", 3, 3, nil},
+		{"operand range", "PUSHI 9999999999", 1, 1, ErrInvalidOperand},
+		{"unresolved label", "NOP\n  JMP nowhere", 2, 3, ErrUnresolvedLabel},
+		{"unresolved entry", "NOP\n.entry nowhere", 2, 1, ErrUnresolvedLabel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAssembler().Assemble(tt.source)
+			var asmErr *AssemblerError
+			if !errors.As(err, &asmErr) {
+				t.Fatalf("Assemble() error = %v (%T), want *AssemblerError", err, err)
+			}
+			if asmErr.Line != tt.wantLine || asmErr.Column != tt.wantColumn {
+				t.Errorf("position = %d:%d, want %d:%d (%v)", asmErr.Line, asmErr.Column, tt.wantLine, tt.wantColumn, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Assemble() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bad.asm")
+		if err := os.WriteFile(path, []byte("NOP\nFROB"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := NewAssembler().AssembleFile(path)
+		var asmErr *AssemblerError
+		if !errors.As(err, &asmErr) || asmErr.Line != 2 || asmErr.File != path {
+			t.Errorf("AssembleFile() error = %v, want *AssemblerError at line 2 of %s", err, path)
+		}
+	})
+
+	t.Run("file not found", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing.asm")
+		_, err := NewAssembler().AssembleFile(path)
+		var asmErr *AssemblerError
+		if !errors.As(err, &asmErr) || asmErr.File != path {
+			t.Errorf("AssembleFile() error = %v, want *AssemblerError for %s", err, path)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("AssembleFile() error = %v, want fs.ErrNotExist", err)
+		}
+	})
+}
+
+func TestAssembleLabelAddress(t *testing.T) {
+	source := `
+		PUSHI @loop
+		STORE 0
+	loop:
+		NOP
+		HALT
+	`
+
+	program, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+
+	loopAddr := -1
+	for addr, name := range program.SymbolTable() {
+		if name == "loop" {
+			loopAddr = addr
+		}
+	}
+	if loopAddr != 2 {
+		t.Fatalf("loop address = %d, want 2", loopAddr)
+	}
+
+	memory := NewSimpleMemory(1)
+	if _, err := New().Execute(program, memory, ExecuteOptions{}); err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	val, _ := memory.Load(0)
+	if !val.Equal(IntValue(int64(loopAddr))) {
+		t.Errorf("pushed value = %v, want %d", val, loopAddr)
+	}
+}
+
+func TestAssembleLabelAddressErrors(t *testing.T) {
+	sources := []string{
+		"PUSHI @missing\nHALT",
+		"PUSHI @\nHALT",
+	}
+	for _, source := range sources {
+		if _, err := NewAssembler().Assemble(source); err == nil {
+			t.Errorf("Assemble(%q) should fail", source)
+		}
+	}
+}
+
 func TestAssembleFile(t *testing.T) {
 	asm := NewAssembler()
 
@@ -573,3 +753,1051 @@ func (h *testInstructionHandler) Execute(ctx ExecutionContext, operand int32) er
 func (h *testInstructionHandler) Name() string {
 	return h.name
 }
+
+func TestAssembleSkipZ(t *testing.T) {
+	source := `
+		LOAD 0
+		SKIPZ
+		HALT
+		PUSHI 1
+		STORE 1
+	`
+
+	program, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+
+	// SKIPZ expands to a JMPZ over exactly one instruction.
+	inst, _ := program.InstructionAt(1)
+	if want := NewInstruction(OpJMPZ, 3); inst != want {
+		t.Errorf("expansion = %v, want %v", inst, want)
+	}
+	if len(program.SymbolTable()) != 0 {
+		t.Errorf("generated labels leaked into symbol table: %v", program.SymbolTable())
+	}
+
+	tests := []struct {
+		name   string
+		cond   Value
+		want   Value
+		halted bool
+	}{
+		{"truthy halts", IntValue(1), NilValue(), true},
+		{"zero skips halt", IntValue(0), IntValue(1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewSimpleMemory(2)
+			memory.Store(0, tt.cond)
+			result, err := New().Execute(program, memory, ExecuteOptions{})
+			if err != nil {
+				t.Fatalf("Execute() failed: %v", err)
+			}
+			if result.Halted != tt.halted {
+				t.Errorf("Halted = %v, want %v", result.Halted, tt.halted)
+			}
+			val, _ := memory.Load(1)
+			if !val.Equal(tt.want) {
+				t.Errorf("memory[1] = %v, want %v", val, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembleSkipZErrors(t *testing.T) {
+	sources := []string{
+		"PUSHI 1\nSKIPZ",
+		"SKIPZ 3\nHALT",
+	}
+	for _, source := range sources {
+		if _, err := NewAssembler().Assemble(source); err == nil {
+			t.Errorf("Assemble(%q) should fail", source)
+		}
+	}
+}
+
+func TestAssembleExit(t *testing.T) {
+	program, err := NewAssembler().Assemble("PUSHI 7\nEXIT")
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if !result.Halted || result.ExitCode != 7 {
+		t.Errorf("Halted = %v, ExitCode = %d, want true, 7", result.Halted, result.ExitCode)
+	}
+}
+
+func TestAssembleConstantExpressions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   Instruction
+	}{
+		{"multiply", "PUSHI 2*8", NewInstruction(OpPUSHI, 16)},
+		{"defined base", ".define BASE 10\nLOAD BASE+4", NewInstruction(OpLOAD, 14)},
+		{"subtract without spaces", ".define BASE 10\nSTORE BASE-4", NewInstruction(OpSTORE, 6)},
+		{"precedence", "PUSHI 1+2*3", NewInstruction(OpPUSHI, 7)},
+		{"parentheses", "PUSHI (1+2)*3", NewInstruction(OpPUSHI, 9)},
+		{"unary minus", ".define N 3\nPUSHI -(N*2)", NewInstruction(OpPUSHI, -6)},
+		{"define from expression", ".define A 4\n.define B A*A\nPUSHI B", NewInstruction(OpPUSHI, 16)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := NewAssembler().Assemble(tt.source)
+			if err != nil {
+				t.Fatalf("Assemble() failed: %v", err)
+			}
+			inst, _ := program.InstructionAt(0)
+			if inst != tt.want {
+				t.Errorf("instruction = %v, want %v", inst, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembleConstantExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"division", "HALT\nPUSHI 8/2", "division is not supported in constant expressions at 2:8"},
+		{"overflow", "PUSHI 65536*65536", "constant expression overflows operand range at 1:12"},
+		{"undefined", "LOAD BASE+4", "undefined constant 'BASE' at 1:6"},
+		{"unbalanced", "PUSHI (1+2", "expected ')' at 1:11"},
+		{"redefined", ".define A 1\n.define A 2", "constant 'A' redefined at 2:9"},
+		{"float", "PUSHI 1.5*2", "expected integer in constant expression at 1:7, got '1.5'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAssembler().Assemble(tt.source)
+			if err == nil {
+				t.Fatal("Assemble() should fail")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssemblerLastSymbols(t *testing.T) {
+	asm := NewAssembler()
+	if symbols := asm.LastSymbols(); symbols != nil {
+		t.Errorf("LastSymbols() before assembly = %v, want nil", symbols)
+	}
+
+	source := `
+		.define LIMIT 3*4
+		.data counter 0
+	start:
+		PUSHI LIMIT
+		STORE counter
+	loop:
+	again:
+		LOAD counter
+		DEC
+		DUP
+		STORE counter
+		JMPNZ loop
+	1:
+		JMP 1f
+	1:
+		HALT
+	`
+	if _, err := asm.Assemble(source); err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	// Local labels are renamed by the assembler and are not symbols.
+	want := map[string]int{
+		"LIMIT":   12,
+		"counter": 0,
+		"start":   0,
+		"loop":    2,
+		"again":   2,
+	}
+	got := asm.LastSymbols()
+	if len(got) != len(want) {
+		t.Errorf("LastSymbols() = %v, want %v", got, want)
+	}
+	for name, addr := range want {
+		if got[name] != addr {
+			t.Errorf("LastSymbols()[%q] = %d, want %d", name, got[name], addr)
+		}
+	}
+
+	if _, err := asm.Assemble("JMP nowhere"); err == nil {
+		t.Fatal("Assemble() should fail for an unresolved label")
+	}
+	if symbols := asm.LastSymbols(); symbols != nil {
+		t.Errorf("LastSymbols() after failure = %v, want nil", symbols)
+	}
+}
+
+func TestAssembleListing(t *testing.T) {
+	source := "; answer\nstart:\n    PUSHI 42\n    STORE 3\n    HALT"
+
+	program, listing, err := NewAssembler().AssembleListing(source)
+	if err != nil {
+		t.Fatalf("AssembleListing() failed: %v", err)
+	}
+	if program.Len() != 3 {
+		t.Fatalf("program has %d instructions, want 3", program.Len())
+	}
+
+	want := "" +
+		"                      ; answer\n" +
+		"                      start:\n" +
+		"0000  01 00 00 00 2a      PUSHI 42\n" +
+		"0001  31 00 00 00 03      STORE 3\n" +
+		"0002  3d 00 00 00 00      HALT\n"
+	if listing != want {
+		t.Errorf("listing =\n%s\nwant\n%s", listing, want)
+	}
+
+	if _, _, err := NewAssembler().AssembleListing("BOGUS"); err == nil {
+		t.Error("AssembleListing() should fail for invalid source")
+	}
+}
+
+func TestAssembleEntryPoint(t *testing.T) {
+	source := `
+		.entry main
+	helper:
+		PUSHI 99
+		STORE 1
+		RET
+	main:
+		PUSHI 7
+		STORE 0
+		HALT
+	`
+
+	program, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	if got := program.(EntryPointer).EntryPoint(); got != 3 {
+		t.Fatalf("EntryPoint() = %d, want 3", got)
+	}
+
+	memory := NewSimpleMemory(2)
+	result, err := New().Execute(program, memory, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if result.InstructionCount != 3 {
+		t.Errorf("InstructionCount = %d, want 3", result.InstructionCount)
+	}
+	if val, _ := memory.Load(0); !val.Equal(IntValue(7)) {
+		t.Errorf("memory[0] = %v, want 7", val)
+	}
+	if val, _ := memory.Load(1); !val.IsNil() {
+		t.Errorf("memory[1] = %v, want nil (helper should not run)", val)
+	}
+
+	// The entry point survives a disassemble/reassemble round trip.
+	disassembled, err := NewDisassembler().Disassemble(program)
+	if err != nil {
+		t.Fatalf("Disassemble() failed: %v", err)
+	}
+	reassembled, err := NewAssembler().Assemble(disassembled)
+	if err != nil {
+		t.Fatalf("Reassemble failed: %v\n%s", err, disassembled)
+	}
+	if got := reassembled.(EntryPointer).EntryPoint(); got != 3 {
+		t.Errorf("reassembled EntryPoint() = %d, want 3", got)
+	}
+}
+
+func TestAssembleEntryPointErrors(t *testing.T) {
+	sources := []string{
+		".entry missing\nHALT",
+		".entry\nHALT",
+		".entry a\n.entry a\na: HALT",
+	}
+	for _, source := range sources {
+		if _, err := NewAssembler().Assemble(source); err == nil {
+			t.Errorf("Assemble(%q) should fail", source)
+		}
+	}
+}
+
+func TestAssembleWithDiagnostics(t *testing.T) {
+	source := `
+		.entry main
+	unused:
+		NOP
+	main:
+		PUSHI 2.5
+	loop:
+		JMPZ done
+		JMP loop
+	done:
+		HALT
+	`
+
+	program, diags, err := NewAssembler().AssembleWithDiagnostics(source)
+	if err != nil {
+		t.Fatalf("AssembleWithDiagnostics() failed: %v", err)
+	}
+
+	inst, _ := program.InstructionAt(1)
+	if want := NewInstruction(OpPUSHI, 2); inst != want {
+		t.Errorf("PUSHI 2.5 assembled to %v, want %v", inst, want)
+	}
+
+	want := []Diagnostic{
+		{Line: 6, Column: 3, Severity: SeverityWarning, Message: "float 2.5 truncated to 2 in PUSHI"},
+		{Line: 3, Column: 2, Severity: SeverityWarning, Message: "label 'unused' is never referenced"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("diagnostics = %v, want %v", diags, want)
+	}
+	for i := range want {
+		if diags[i] != want[i] {
+			t.Errorf("diagnostic %d = %v, want %v", i, diags[i], want[i])
+		}
+	}
+}
+
+func TestAssembleWithDiagnosticsNoHalt(t *testing.T) {
+	_, diags, err := NewAssembler().AssembleWithDiagnostics("PUSHI 1\nSTORE 0")
+	if err != nil {
+		t.Fatalf("AssembleWithDiagnostics() failed: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Line != 0 || diags[0].Message != "program has no HALT or EXIT" {
+		t.Errorf("diagnostics = %v, want a single missing-HALT warning", diags)
+	}
+
+	_, diags, _ = NewAssembler().AssembleWithDiagnostics("PUSHI 1\nHALT")
+	if len(diags) != 0 {
+		t.Errorf("clean program produced diagnostics: %v", diags)
+	}
+}
+
+func TestAssembleRept(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []Instruction
+		symbols map[int]string
+	}{
+		{
+			name:   "simple block",
+			source: ".rept 4\nINC\n.endr\nHALT",
+			want: []Instruction{
+				NewInstruction(OpINC, 0),
+				NewInstruction(OpINC, 0),
+				NewInstruction(OpINC, 0),
+				NewInstruction(OpINC, 0),
+				NewInstruction(OpHALT, 0),
+			},
+		},
+		{
+			name:   "defined count",
+			source: ".define N 2\n.rept N+1\nDUP\n.endr",
+			want: []Instruction{
+				NewInstruction(OpDUP, 0),
+				NewInstruction(OpDUP, 0),
+				NewInstruction(OpDUP, 0),
+			},
+		},
+		{
+			name:   "zero count",
+			source: ".rept 0\nINC\n.endr\nHALT",
+			want:   []Instruction{NewInstruction(OpHALT, 0)},
+		},
+		{
+			name:   "nested blocks",
+			source: ".rept 2\nPUSHI 1\n.rept 2\nINC\n.endr\n.endr",
+			want: []Instruction{
+				NewInstruction(OpPUSHI, 1),
+				NewInstruction(OpINC, 0),
+				NewInstruction(OpINC, 0),
+				NewInstruction(OpPUSHI, 1),
+				NewInstruction(OpINC, 0),
+				NewInstruction(OpINC, 0),
+			},
+		},
+		{
+			name:   "labels get suffixes",
+			source: ".rept 2\nskip:\nJMP skip\nJMP done\n.endr\ndone: HALT",
+			want: []Instruction{
+				NewInstruction(OpJMP, 0),
+				NewInstruction(OpJMP, 4),
+				NewInstruction(OpJMP, 2),
+				NewInstruction(OpJMP, 4),
+				NewInstruction(OpHALT, 0),
+			},
+			symbols: map[int]string{0: "skip_1", 2: "skip_2", 4: "done"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := NewAssembler().Assemble(tt.source)
+			if err != nil {
+				t.Fatalf("Assemble() failed: %v", err)
+			}
+			if program.Len() != len(tt.want) {
+				t.Fatalf("Len() = %d, want %d", program.Len(), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if inst, _ := program.InstructionAt(i); inst != want {
+					t.Errorf("instruction %d = %v, want %v", i, inst, want)
+				}
+			}
+			for addr, name := range tt.symbols {
+				if got := program.SymbolTable()[addr]; got != name {
+					t.Errorf("symbol at %d = %q, want %q", addr, got, name)
+				}
+			}
+		})
+	}
+}
+
+func TestAssembleReptErrors(t *testing.T) {
+	sources := []string{
+		".rept 2\nINC",
+		"INC\n.endr",
+		".rept -1\nINC\n.endr",
+		".rept\nINC\n.endr",
+		".rept 2 INC\n.endr",
+	}
+	for _, source := range sources {
+		if _, err := NewAssembler().Assemble(source); err == nil {
+			t.Errorf("Assemble(%q) should fail", source)
+		}
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{".rept 2\n.data x 5\n.endr\n.data y 7\nLOAD y", ".data is not allowed inside .rept at 2:1"},
+		{".rept 2\n.bss buf 4\n.endr", ".bss is not allowed inside .rept"},
+		{".rept 1\n.entry main\n.endr\nmain: HALT", ".entry is not allowed inside .rept"},
+		{".rept 2\n.sub f\nRET\n.endsub\n.endr", ".sub is not allowed inside .rept"},
+		{".rept 2000000000\nNOP\n.endr", "expands to 2000000000 statements"},
+		{".rept 2000\n.rept 2000\nNOP\n.endr\n.endr", "expands to 4000000 statements"},
+	}
+	for _, tt := range tests {
+		_, err := NewAssembler().Assemble(tt.source)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Assemble(%q) error = %v, want %q", tt.source, err, tt.want)
+		}
+		var asmErr *AssemblerError
+		if errors.As(err, &asmErr) && asmErr.Line == 0 {
+			t.Errorf("Assemble(%q) error has no position", tt.source)
+		}
+	}
+
+	// A block with nothing in it can be repeated any number of times.
+	if _, err := NewAssembler().Assemble(".rept 2000000000\n.endr\nHALT"); err != nil {
+		t.Errorf("Assemble(empty .rept) error = %v", err)
+	}
+}
+
+func TestAssembleConstantDedup(t *testing.T) {
+	program, err := NewAssembler().Assemble("PUSHC 2.5\nPUSHC 2.5\nPUSHC 7\nPUSHC 2.5\nHALT")
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	constants := program.(ConstantPool).Constants()
+	if len(constants) != 2 {
+		t.Fatalf("Constants() = %v, want 2 entries", constants)
+	}
+	for i, want := range []int32{0, 0, 1, 0} {
+		if inst, _ := program.InstructionAt(i); inst.Operand != want {
+			t.Errorf("instruction %d operand = %d, want %d", i, inst.Operand, want)
+		}
+	}
+}
+
+func TestAssembleOperandRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string // substring of the error; empty if none expected
+	}{
+		{"store in range", "PUSHI 1\nSTORE 2147483647", ""},
+		{"store too large", "PUSHI 1\nSTORE 3000000000", "line 2: invalid operand: 3000000000 does not fit"},
+		{"load too large", "LOAD 2147483648", "line 1: invalid operand: 2147483648"},
+		{"pushi too small", "PUSHI -2147483649", "line 1: invalid operand: -2147483649"},
+		{"pushi min", "PUSHI -2147483648", ""},
+		{"pushi large float", "PUSHI 3000000000.5", "line 1: invalid operand: 3.0000000005e+09"},
+		{"trap too large", "TRAP 5000000000", "line 1: invalid operand: 5000000000"},
+		{"push large uses constant pool", "PUSH 5000000000", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAssembler().Assemble(tt.source)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Assemble() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Assemble() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssembleExponentLiterals(t *testing.T) {
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"PUSHC 1e3", 1000},
+		{"PUSHC 1.5E-2", 0.015},
+		{"PUSHC -2.5e+4", -25000},
+		{"PUSH 6.02e23", 6.02e23},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := NewAssembler().Assemble(tt.source)
+			if err != nil {
+				t.Fatalf("Assemble() failed: %v", err)
+			}
+			constants := program.(ConstantPool).Constants()
+			if len(constants) != 1 || !constants[0].Equal(FloatValue(tt.want)) {
+				t.Errorf("constants = %v, want [%v]", constants, tt.want)
+			}
+		})
+	}
+
+	// An 'e' not followed by digits is not part of the number.
+	if _, err := NewAssembler().Assemble("PUSHI 2e"); err == nil {
+		t.Error("Assemble(\"PUSHI 2e\") should fail")
+	}
+}
+
+func TestAssembleLocalLabels(t *testing.T) {
+	t.Run("forward jump", func(t *testing.T) {
+		program, err := NewAssembler().Assemble(`
+			PUSHI 1
+			JMP 1f
+			PUSHI 2     ; skipped
+		1:	HALT
+		`)
+		if err != nil {
+			t.Fatalf("Assemble() failed: %v", err)
+		}
+		if inst, _ := program.InstructionAt(1); inst != NewInstruction(OpJMP, 3) {
+			t.Errorf("JMP 1f = %v, want JMP 3", inst)
+		}
+		if len(program.SymbolTable()) != 0 {
+			t.Errorf("local labels leaked into symbol table: %v", program.SymbolTable())
+		}
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		if result.StackDepth != 1 {
+			t.Errorf("StackDepth = %d, want 1", result.StackDepth)
+		}
+	})
+
+	t.Run("backward loop", func(t *testing.T) {
+		// Count memory[0] down from 3 to 0.
+		program, err := NewAssembler().Assemble(`
+			PUSHI 3
+			STORE 0
+		1:	LOAD 0
+			DEC
+			DUP
+			STORE 0
+			JMPNZ 1b
+			HALT
+		`)
+		if err != nil {
+			t.Fatalf("Assemble() failed: %v", err)
+		}
+		memory := NewSimpleMemory(1)
+		result, err := New().Execute(program, memory, ExecuteOptions{MaxInstructions: 100})
+		if err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		if result.InstructionCount != 2+3*5+1 {
+			t.Errorf("InstructionCount = %d, want %d", result.InstructionCount, 2+3*5+1)
+		}
+	})
+
+	t.Run("nearest definition", func(t *testing.T) {
+		program, err := NewAssembler().Assemble(`
+		1:	NOP
+			JMP 1b
+			JMP 1f
+		1:	NOP
+			JMP 1b
+			JMP 1f
+		1:	HALT
+		`)
+		if err != nil {
+			t.Fatalf("Assemble() failed: %v", err)
+		}
+		want := []int32{0, 3, 3, 6}
+		for i, addr := range []int{1, 2, 4, 5} {
+			if inst, _ := program.InstructionAt(addr); inst.Operand != want[i] {
+				t.Errorf("instruction %d = %v, want target %d", addr, inst, want[i])
+			}
+		}
+	})
+
+	t.Run("inside rept", func(t *testing.T) {
+		program, err := NewAssembler().Assemble(".rept 2\n1: JMP 1b\n.endr\nHALT")
+		if err != nil {
+			t.Fatalf("Assemble() failed: %v", err)
+		}
+		for addr, want := range []int32{0, 1} {
+			if inst, _ := program.InstructionAt(addr); inst != NewInstruction(OpJMP, want) {
+				t.Errorf("instruction %d = %v, want JMP %d", addr, inst, want)
+			}
+		}
+	})
+}
+
+func TestAssembleLocalLabelErrors(t *testing.T) {
+	sources := []string{
+		"JMP 1b\n1: HALT",
+		"1: JMP 1f\nHALT",
+		"JMP 2f\n1: HALT",
+	}
+	for _, source := range sources {
+		if _, err := NewAssembler().Assemble(source); err == nil {
+			t.Errorf("Assemble(%q) should fail", source)
+		}
+	}
+}
+
+func TestAssembleIntPush(t *testing.T) {
+	source := "PUSH 5\nPUSH 5.0\nPUSH 2.5\nHALT"
+
+	tests := []struct {
+		name  string
+		opts  AssemblerOptions
+		types []ValueType
+	}{
+		{"default", AssemblerOptions{}, []ValueType{TypeFloat, TypeFloat, TypeFloat}},
+		{"IntPush", AssemblerOptions{IntPush: true}, []ValueType{TypeInt, TypeFloat, TypeFloat}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := NewAssemblerWithOptions(tt.opts).Assemble(source)
+			if err != nil {
+				t.Fatalf("Assemble() failed: %v", err)
+			}
+			result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+			if err != nil {
+				t.Fatalf("Execute() failed: %v", err)
+			}
+			for i, want := range tt.types {
+				if got := result.Stack[i].Type; got != want {
+					t.Errorf("Stack[%d] = %v (type %d), want type %d", i, result.Stack[i], got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := NewAssemblerWithOptions(AssemblerOptions{IntPush: true}).Assemble("PUSH 3000000000"); err == nil {
+		t.Error("PUSH 3000000000 with IntPush should fail the 32-bit range check")
+	}
+}
+
+func TestAssembleRequireHalt(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string // empty if the program should assemble
+	}{
+		{"trailing HALT", "PUSHI 1\nPOP\nHALT", ""},
+		{"missing HALT", "PUSHI 1\nPOP", "line 2: execution can run past the end of the program after POP"},
+		{"ends with RET", "CALL f\nHALT\nf: RET", ""},
+		{"ends with loop", "loop: NOP\nJMP loop", ""},
+		{"dead code after HALT", "HALT\nPUSHI 1", ""},
+		{"branch falls off", "PUSHI 0\nJMPZ done\nHALT\ndone:", "line 2: execution can run past the end of the program after JMPZ"},
+		{"subroutine guard", ".sub f\nRET\n.endsub\nCALL f", "execution can run past the end of the program after JMP"},
+		{"empty", "; nothing", "program has no instructions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAssemblerWithOptions(AssemblerOptions{RequireHalt: true}).Assemble(tt.source)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Assemble() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Assemble() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Without the option, falling off the end is allowed.
+	if _, err := NewAssembler().Assemble("PUSHI 1\nPOP"); err != nil {
+		t.Errorf("Assemble() without RequireHalt failed: %v", err)
+	}
+}
+
+func TestAssembleAliases(t *testing.T) {
+	program, err := NewAssembler().Assemble("start: PUSHI 0\nJZ start\njnz start\nMULT\nHALT")
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	want := []Opcode{OpPUSHI, OpJMPZ, OpJMPNZ, OpMUL, OpHALT}
+	for i, op := range want {
+		if inst, _ := program.InstructionAt(i); inst.Opcode != op {
+			t.Errorf("instruction %d = %s, want %s", i, inst.Opcode, op)
+		}
+	}
+
+	// Hosts can add aliases, including for custom instructions, and
+	// replace built-in ones.
+	registry := NewInstructionRegistry()
+	if err := registry.Register(200, &mockHandler{name: "BEEP"}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	opts := AssemblerOptions{
+		Registry: registry,
+		Aliases:  map[string]string{"brz": "JMPZ", "bell": "beep", "JZ": "JMPNZ"},
+	}
+	program, err = NewAssemblerWithOptions(opts).Assemble("x: BRZ x\nBELL\nJZ x\nHALT")
+	if err != nil {
+		t.Fatalf("Assemble() with aliases failed: %v", err)
+	}
+	want = []Opcode{OpJMPZ, 200, OpJMPNZ, OpHALT}
+	for i, op := range want {
+		if inst, _ := program.InstructionAt(i); inst.Opcode != op {
+			t.Errorf("instruction %d = %s, want %s", i, inst.Opcode, op)
+		}
+	}
+
+	// Names that differ only in case would make the match depend on map
+	// order.
+	opts = AssemblerOptions{Aliases: map[string]string{"brz": "JMPZ", "BRZ": "JMPNZ"}}
+	_, err = NewAssemblerWithOptions(opts).Assemble("x: BRZ x\nHALT")
+	var asmErr *AssemblerError
+	if !errors.As(err, &asmErr) || !strings.Contains(err.Error(), `aliases "BRZ" and "brz" differ only in case`) {
+		t.Errorf("Assemble() with colliding aliases error = %v", err)
+	}
+
+	for _, source := range []string{"JMPZZ 0", "BAD"} {
+		opts := AssemblerOptions{Aliases: map[string]string{"BAD": "NOSUCH"}}
+		if _, err := NewAssemblerWithOptions(opts).Assemble(source); err == nil || !strings.Contains(err.Error(), "unknown opcode") {
+			t.Errorf("Assemble(%q) error = %v, want unknown opcode", source, err)
+		}
+	}
+}
+
+func TestAssembleRelativeJumps(t *testing.T) {
+	// Count memory[0] down from 3 to 0 with a backward JMPNZR, after a
+	// forward JMPR skips a store that would clobber the counter.
+	source := `
+		PUSHI 3
+		JMPR $+3
+		PUSHI 99
+		STORE 0
+		STORE 0
+		LOAD 0
+		DEC
+		DUP
+		STORE 0
+		JMPNZR $-4
+		HALT
+	`
+	program, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	if inst, _ := program.InstructionAt(1); inst != NewInstruction(OpJMPR, 3) {
+		t.Errorf("instruction 1 = %v, want JMPR 3", inst)
+	}
+	if inst, _ := program.InstructionAt(9); inst != NewInstruction(OpJMPNZR, -4) {
+		t.Errorf("instruction 9 = %v, want JMPNZR -4", inst)
+	}
+
+	memory := NewSimpleMemory(1)
+	result, err := New().Execute(program, memory, ExecuteOptions{MaxInstructions: 100})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if want := uint32(3 + 3*5 + 1); result.InstructionCount != want {
+		t.Errorf("InstructionCount = %d, want %d", result.InstructionCount, want)
+	}
+	if val, _ := memory.Load(0); val.IsTruthy() {
+		t.Errorf("memory[0] = %v, want 0", val)
+	}
+
+	// The offset doesn't depend on where the code sits.
+	shifted, err := NewAssembler().Assemble("NOP\nNOP\n" + source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	if inst, _ := shifted.InstructionAt(11); inst != NewInstruction(OpJMPNZR, -4) {
+		t.Errorf("shifted instruction 11 = %v, want JMPNZR -4", inst)
+	}
+
+	for _, bad := range []string{"JMPR 3", "JMPR loop", "JMP $+1", "JMPR $3", "JMPZR $+(1"} {
+		if _, err := NewAssembler().Assemble(bad); err == nil {
+			t.Errorf("Assemble(%q) should fail", bad)
+		}
+	}
+}
+
+func TestAssembleSubroutines(t *testing.T) {
+	source := `
+		PUSHI 7
+		CALL square
+		HALT
+
+	.sub square
+		DUP
+		MUL
+	.endsub
+	`
+	program, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+
+	want := []Instruction{
+		NewInstruction(OpPUSHI, 7),
+		NewInstruction(OpCALL, 4),
+		NewInstruction(OpHALT, 0),
+		NewInstruction(OpJMP, 7), // guard
+		NewInstruction(OpDUP, 0),
+		NewInstruction(OpMUL, 0),
+		NewInstruction(OpRET, 0),
+	}
+	if program.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", program.Len(), len(want))
+	}
+	for i, w := range want {
+		if inst, _ := program.InstructionAt(i); inst != w {
+			t.Errorf("instruction %d = %v, want %v", i, inst, w)
+		}
+	}
+	if name := program.SymbolTable()[4]; name != "square" {
+		t.Errorf("symbol at 4 = %q, want square", name)
+	}
+
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if len(result.Stack) != 1 {
+		t.Fatalf("Stack = %v, want one value", result.Stack)
+	}
+	if f, _ := result.Stack[0].AsFloat(); f != 49 {
+		t.Errorf("Stack[0] = %v, want 49", result.Stack[0])
+	}
+
+	// An explicit RET is not doubled.
+	program, err = NewAssembler().Assemble(".sub one\nPUSHI 1\nRET\n.endsub")
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	if program.Len() != 3 {
+		t.Errorf("Len() = %d, want 3 (guard, PUSHI, RET)", program.Len())
+	}
+}
+
+func TestAssembleMetadataDirectives(t *testing.T) {
+	source := `
+.name    counter
+.version 1.2.0
+.author  Jane Doe   ; not a comment
+.description Counts to ten.
+
+PUSHI 10
+HALT
+`
+	program, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+
+	metadata := program.Metadata()
+	want := ProgramMetadata{
+		Name:        "counter",
+		Version:     "1.2.0",
+		Author:      "Jane Doe   ; not a comment",
+		Description: "Counts to ten.",
+	}
+	if metadata.Name != want.Name || metadata.Version != want.Version ||
+		metadata.Author != want.Author || metadata.Description != want.Description {
+		t.Errorf("Metadata() = %+v, want %+v", metadata, want)
+	}
+	if program.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", program.Len())
+	}
+}
+
+func TestAssembleMetadataDirectiveErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{".name\nHALT", "expected text after .name"},
+		{".name a\n.name b\nHALT", "duplicate .name directive"},
+	}
+	for _, tt := range tests {
+		_, err := NewAssembler().Assemble(tt.source)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Assemble(%q) error = %v, want %q", tt.source, err, tt.want)
+		}
+	}
+}
+
+func TestAssembleDataDirective(t *testing.T) {
+	program, err := NewAssembler().Assemble(`
+.data counter 41
+.data limit   2.5
+
+    LOAD counter
+    INC
+    STORE counter
+    LOAD limit
+    HALT
+`)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+
+	want := []Instruction{
+		NewInstruction(OpLOAD, 0),
+		NewInstruction(OpINC, 0),
+		NewInstruction(OpSTORE, 0),
+		NewInstruction(OpLOAD, 1),
+		NewInstruction(OpHALT, 0),
+	}
+	for i, inst := range want {
+		if got, _ := program.InstructionAt(i); got != inst {
+			t.Errorf("instruction %d = %v, want %v", i, got, inst)
+		}
+	}
+
+	memory := NewSimpleMemory(2)
+	result, err := New().Execute(program, memory, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if counter, _ := memory.Load(0); !counter.IsNumeric() || counter.String() != "42" {
+		t.Errorf("counter = %v, want 42", counter)
+	}
+	if len(result.Stack) != 1 || !result.Stack[0].Equal(FloatValue(2.5)) {
+		t.Errorf("Stack = %v, want [2.5]", result.Stack)
+	}
+}
+
+func TestAssembleDataDirectiveErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{".data\nHALT", "expected name after .data"},
+		{".data x\nHALT", "expected a number after .data x"},
+		{".data x start\nstart: HALT", "expected a number after .data x"},
+		{".data x 0\n.data x 1\nHALT", "constant 'x' redefined"},
+		{".define x 3\n.data x 1\nHALT", "constant 'x' redefined"},
+	}
+	for _, tt := range tests {
+		_, err := NewAssembler().Assemble(tt.source)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Assemble(%q) error = %v, want %q", tt.source, err, tt.want)
+		}
+	}
+}
+
+func TestAssembleBssDirective(t *testing.T) {
+	program, err := NewAssembler().Assemble(`
+.bss  array 4
+.data count 7
+
+    PUSHI 42
+    STORE array+2
+    LOAD  array+2
+    LOAD  array+3
+    LOAD  count
+    HALT
+`)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+
+	seg := program.(DataSegment).Data()
+	if len(seg) != 5 {
+		t.Fatalf("data segment has %d slots, want 5", len(seg))
+	}
+	for i := range 4 {
+		if !seg[i].IsNil() {
+			t.Errorf("data[%d] = %v, want nil", i, seg[i])
+		}
+	}
+	if inst, _ := program.InstructionAt(1); inst != NewInstruction(OpSTORE, 2) {
+		t.Errorf("instruction 1 = %v, want STORE 2", inst)
+	}
+
+	memory := NewSimpleMemory(5)
+	result, err := New().Execute(program, memory, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	want := []Value{IntValue(42), NilValue(), IntValue(7)}
+	if len(result.Stack) != len(want) {
+		t.Fatalf("Stack = %v, want %v", result.Stack, want)
+	}
+	for i := range want {
+		if !result.Stack[i].Equal(want[i]) {
+			t.Errorf("Stack[%d] = %v, want %v", i, result.Stack[i], want[i])
+		}
+	}
+}
+
+func TestAssembleBssDirectiveErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{".bss\nHALT", "expected name after .bss"},
+		{".bss x\nHALT", "expected a count after .bss x"},
+		{".bss x 0\nHALT", ".bss x count must be positive"},
+		{".bss x 2-3\nHALT", ".bss x count must be positive"},
+		{".data x 0\n.bss x 1\nHALT", "constant 'x' redefined"},
+		{".bss x 2000000000\nHALT", ".bss x at 1:1 makes the data segment 2000000000 slots"},
+		{".bss x 1048576\n.bss y 1\nHALT", "makes the data segment 1048577 slots"},
+	}
+	for _, tt := range tests {
+		_, err := NewAssembler().Assemble(tt.source)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Assemble(%q) error = %v, want %q", tt.source, err, tt.want)
+		}
+	}
+}
+
+func TestAssembleSubroutineErrors(t *testing.T) {
+	sources := []string{
+		".sub a\n.sub b\n.endsub\n.endsub",
+		".sub a\nNOP",
+		"NOP\n.endsub",
+		".sub\nNOP\n.endsub",
+	}
+	for _, source := range sources {
+		if _, err := NewAssembler().Assemble(source); err == nil {
+			t.Errorf("Assemble(%q) should fail", source)
+		}
+	}
+}
@@ -1,12 +1,25 @@
 package stackvm
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// generatedLabelPrefix marks labels synthesized by the assembler. The prefix
+// cannot appear in source labels, and such labels are left out of the
+// symbol table.
+const generatedLabelPrefix = "$"
 
 // ProgramBuilder provides a fluent API for constructing programs.
 type ProgramBuilder struct {
 	instructions []Instruction
 	labels       map[string]int  // label name -> instruction index
 	references   []labelRef      // unresolved label references
+	constants    []Value         // constant pool for PUSHC
+	data         []Value         // initial memory contents
+	entry        string          // entry point label, if any
 	metadata     ProgramMetadata
 }
 
@@ -27,18 +40,70 @@ func NewProgramBuilder() *ProgramBuilder {
 
 // Stack Operations
 
-// Push adds a PUSH instruction (push float value).
+// Push adds an instruction that pushes a float value.
+// Whole numbers that fit in an int32 operand are emitted as PUSH. Other values
+// (fractions, -0, large magnitudes, NaN, and infinities) are stored in the
+// constant pool and emitted as PUSHC, so the value is preserved exactly.
 func (b *ProgramBuilder) Push(v float64) *ProgramBuilder {
-	b.instructions = append(b.instructions, NewInstruction(OpPUSH, int32(v)))
+	if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 && !(v == 0 && math.Signbit(v)) {
+		b.instructions = append(b.instructions, NewInstruction(OpPUSH, int32(v)))
+		return b
+	}
+	return b.PushConst(FloatValue(v))
+}
+
+// PushConst adds a PUSHC instruction that pushes the value from the constant pool.
+// Identical built-in values share a single pool entry.
+func (b *ProgramBuilder) PushConst(v Value) *ProgramBuilder {
+	index := -1
+	for i, c := range b.constants {
+		if identicalConstant(c, v) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		b.constants = append(b.constants, v)
+		index = len(b.constants) - 1
+	}
+	b.instructions = append(b.instructions, NewInstruction(OpPUSHC, int32(index)))
 	return b
 }
 
+// identicalConstant reports whether two pool values can share an entry.
+// Floats must match bit for bit, so 0 and -0 stay distinct. Custom types
+// are never shared.
+func identicalConstant(a, b Value) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case TypeFloat:
+		x, _ := a.AsFloat()
+		y, _ := b.AsFloat()
+		return math.Float64bits(x) == math.Float64bits(y)
+	case TypeNil, TypeInt, TypeBool, TypeString:
+		return a.Equal(b)
+	default:
+		return false
+	}
+}
+
 // PushInt adds a PUSHI instruction (push int value).
 func (b *ProgramBuilder) PushInt(v int64) *ProgramBuilder {
 	b.instructions = append(b.instructions, NewInstruction(OpPUSHI, int32(v)))
 	return b
 }
 
+// PushLabelAddr adds a PUSHI instruction that pushes the address of a label.
+// The label is resolved when the program is built.
+func (b *ProgramBuilder) PushLabelAddr(label string) *ProgramBuilder {
+	instIndex := len(b.instructions)
+	b.instructions = append(b.instructions, NewInstruction(OpPUSHI, 0))
+	b.references = append(b.references, labelRef{label, instIndex})
+	return b
+}
+
 // Pop adds a POP instruction.
 func (b *ProgramBuilder) Pop() *ProgramBuilder {
 	b.instructions = append(b.instructions, NewInstruction(OpPOP, 0))
@@ -69,6 +134,32 @@ func (b *ProgramBuilder) Rot() *ProgramBuilder {
 	return b
 }
 
+// DupN adds a DUPN instruction copying the top n values as a block.
+func (b *ProgramBuilder) DupN(n int) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpDUPN, int32(n)))
+	return b
+}
+
+// SwapN adds a SWAPN instruction exchanging the top value with the value n
+// places below it. SwapN(1) is the same as Swap.
+func (b *ProgramBuilder) SwapN(n int) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpSWAPN, int32(n)))
+	return b
+}
+
+// Empty adds an EMPTY instruction, which pushes whether the stack was
+// empty.
+func (b *ProgramBuilder) Empty() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpEMPTY, 0))
+	return b
+}
+
+// TypeCheck adds a TYPECHK instruction asserting the top of stack has type t.
+func (b *ProgramBuilder) TypeCheck(t ValueType) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpTYPECHK, int32(t)))
+	return b
+}
+
 // Arithmetic Operations
 
 // Add adds an ADD instruction.
@@ -125,6 +216,18 @@ func (b *ProgramBuilder) Dec() *ProgramBuilder {
 	return b
 }
 
+// ToFloat adds a TOF instruction.
+func (b *ProgramBuilder) ToFloat() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpTOF, 0))
+	return b
+}
+
+// ToInt adds a TOI instruction.
+func (b *ProgramBuilder) ToInt() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpTOI, 0))
+	return b
+}
+
 // Logic Operations
 
 // And adds an AND instruction.
@@ -151,6 +254,18 @@ func (b *ProgramBuilder) Xor() *ProgramBuilder {
 	return b
 }
 
+// Shr adds a SHR instruction (arithmetic shift right).
+func (b *ProgramBuilder) Shr() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpSHR, 0))
+	return b
+}
+
+// Ushr adds a USHR instruction (logical shift right).
+func (b *ProgramBuilder) Ushr() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpUSHR, 0))
+	return b
+}
+
 // Comparison Operations
 
 // Eq adds an EQ instruction.
@@ -215,6 +330,26 @@ func (b *ProgramBuilder) StoreD() *ProgramBuilder {
 	return b
 }
 
+// MemClr adds a MEMCLR instruction, which pops a length and clears that
+// many memory slots starting at index.
+func (b *ProgramBuilder) MemClr(index int) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpMEMCLR, int32(index)))
+	return b
+}
+
+// RLoad adds an RLOAD instruction, which pushes register index.
+func (b *ProgramBuilder) RLoad(index int) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpRLOAD, int32(index)))
+	return b
+}
+
+// RStore adds an RSTORE instruction, which pops a value into register
+// index.
+func (b *ProgramBuilder) RStore(index int) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpRSTORE, int32(index)))
+	return b
+}
+
 // Control Flow Operations
 
 // Label defines a label at the current position.
@@ -247,6 +382,50 @@ func (b *ProgramBuilder) JmpNZ(label string) *ProgramBuilder {
 	return b
 }
 
+// JmpR adds a JMPR instruction that jumps offset instructions from itself.
+func (b *ProgramBuilder) JmpR(offset int32) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpJMPR, offset))
+	return b
+}
+
+// JmpZR adds a JMPZR instruction that jumps offset instructions from itself
+// if the popped value is zero/false.
+func (b *ProgramBuilder) JmpZR(offset int32) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpJMPZR, offset))
+	return b
+}
+
+// JmpNZR adds a JMPNZR instruction that jumps offset instructions from
+// itself if the popped value is non-zero/true.
+func (b *ProgramBuilder) JmpNZR(offset int32) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpJMPNZR, offset))
+	return b
+}
+
+// Switch adds a dispatch that pops a value and jumps to the label of the
+// case equal to it, or to defaultLabel if none is. Cases are compared in
+// ascending key order with EQ, so an int on the stack matches the int
+// keys. The labels are resolved when the program is built.
+func (b *ProgramBuilder) Switch(cases map[int64]string, defaultLabel string) *ProgramBuilder {
+	keys := make([]int64, 0, len(cases))
+	for k := range cases {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	for _, k := range keys {
+		b.Dup()
+		if k >= math.MinInt32 && k <= math.MaxInt32 {
+			b.PushInt(k)
+		} else {
+			b.PushConst(IntValue(k))
+		}
+		// On a mismatch, skip the POP and JMP to the next comparison.
+		b.Eq().JmpZR(3).Pop().Jmp(cases[k])
+	}
+	return b.Pop().Jmp(defaultLabel)
+}
+
 // Call adds a CALL instruction to the specified label.
 func (b *ProgramBuilder) Call(label string) *ProgramBuilder {
 	instIndex := len(b.instructions)
@@ -267,6 +446,32 @@ func (b *ProgramBuilder) Halt() *ProgramBuilder {
 	return b
 }
 
+// Trap adds a TRAP instruction that calls the host's handler for trap n.
+func (b *ProgramBuilder) Trap(n int32) *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpTRAP, n))
+	return b
+}
+
+// Print adds a PRINT instruction, which writes the popped value to the
+// VM's output.
+func (b *ProgramBuilder) Print() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpPRINT, 0))
+	return b
+}
+
+// Fuel adds a FUEL instruction, which pushes the number of instructions
+// the program may still run.
+func (b *ProgramBuilder) Fuel() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpFUEL, 0))
+	return b
+}
+
+// Exit adds an EXIT instruction, which halts with the popped exit code.
+func (b *ProgramBuilder) Exit() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpEXIT, 0))
+	return b
+}
+
 // Nop adds a NOP instruction.
 func (b *ProgramBuilder) Nop() *ProgramBuilder {
 	b.instructions = append(b.instructions, NewInstruction(OpNOP, 0))
@@ -329,6 +534,14 @@ func (b *ProgramBuilder) Round() *ProgramBuilder {
 	return b
 }
 
+// String Operations
+
+// Concat adds a CONCAT instruction.
+func (b *ProgramBuilder) Concat() *ProgramBuilder {
+	b.instructions = append(b.instructions, NewInstruction(OpCONCAT, 0))
+	return b
+}
+
 // Custom Operations
 
 // Custom adds a custom instruction with the specified opcode and operand.
@@ -337,6 +550,15 @@ func (b *ProgramBuilder) Custom(opcode Opcode, operand int32) *ProgramBuilder {
 	return b
 }
 
+// Data Operations
+
+// Data appends a value to the data segment. The nth call initializes
+// memory address n before the program runs.
+func (b *ProgramBuilder) Data(v Value) *ProgramBuilder {
+	b.data = append(b.data, v)
+	return b
+}
+
 // Metadata Operations
 
 // SetMetadata sets the program metadata.
@@ -345,6 +567,13 @@ func (b *ProgramBuilder) SetMetadata(metadata ProgramMetadata) *ProgramBuilder {
 	return b
 }
 
+// SetEntry marks the label where execution begins. Instructions before it
+// run only if reached by a jump or call.
+func (b *ProgramBuilder) SetEntry(label string) *ProgramBuilder {
+	b.entry = label
+	return b
+}
+
 // Build constructs the final Program.
 // Returns an error if there are unresolved label references.
 func (b *ProgramBuilder) Build() (Program, error) {
@@ -361,11 +590,31 @@ func (b *ProgramBuilder) Build() (Program, error) {
 	// Create symbol table from labels
 	symbols := make(map[int]string)
 	for name, addr := range b.labels {
+		if strings.HasPrefix(name, generatedLabelPrefix) {
+			continue
+		}
 		symbols[addr] = name
 	}
 
 	program := NewProgramWithMetadata(b.instructions, b.metadata)
 	program.SetSymbolTable(symbols)
+	if b.entry != "" {
+		entry, exists := b.labels[b.entry]
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrUnresolvedLabel, b.entry)
+		}
+		program.SetEntryPoint(entry)
+	}
+	if len(b.constants) > 0 {
+		constants := make([]Value, len(b.constants))
+		copy(constants, b.constants)
+		program.SetConstants(constants)
+	}
+	if len(b.data) > 0 {
+		data := make([]Value, len(b.data))
+		copy(data, b.data)
+		program.SetData(data)
+	}
 
 	return program, nil
 }
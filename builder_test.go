@@ -1,7 +1,10 @@
 package stackvm
 
 import (
+	"errors"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestNewProgramBuilder(t *testing.T) {
@@ -583,6 +586,100 @@ func TestBuilderPushInt(t *testing.T) {
 	}
 }
 
+func TestBuilderPushFloat(t *testing.T) {
+	program, err := NewProgramBuilder().
+		Push(10).
+		Push(2.5).
+		Add().
+		Store(0).
+		Halt().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	instructions := program.Instructions()
+	if instructions[0].Opcode != OpPUSH {
+		t.Errorf("Whole number should use PUSH, got %s", instructions[0].Opcode)
+	}
+	if instructions[1].Opcode != OpPUSHC {
+		t.Errorf("Fractional number should use PUSHC, got %s", instructions[1].Opcode)
+	}
+
+	memory := NewSimpleMemory(1)
+	if _, err := New().Execute(program, memory, ExecuteOptions{}); err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	val, _ := memory.Load(0)
+	if !val.Equal(FloatValue(12.5)) {
+		t.Errorf("memory[0] = %v, want 12.5", val)
+	}
+}
+
+func TestBuilderConstantDedup(t *testing.T) {
+	program, err := NewProgramBuilder().
+		PushConst(StringValue("x")).
+		PushConst(StringValue("x")).
+		PushConst(StringValue("x")).
+		Halt().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	constants := program.(ConstantPool).Constants()
+	if len(constants) != 1 {
+		t.Fatalf("len(Constants()) = %d, want 1", len(constants))
+	}
+	for i := 0; i < 3; i++ {
+		if inst, _ := program.InstructionAt(i); inst != NewInstruction(OpPUSHC, 0) {
+			t.Errorf("instruction %d = %v, want PUSHC 0", i, inst)
+		}
+	}
+
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if len(result.Stack) != 3 {
+		t.Fatalf("stack = %v, want three copies", result.Stack)
+	}
+	for i, v := range result.Stack {
+		if !v.Equal(StringValue("x")) {
+			t.Errorf("stack[%d] = %v, want x", i, v)
+		}
+	}
+}
+
+func TestBuilderConstantDedupDistinct(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []Value
+		want   int
+	}{
+		{"same float", []Value{FloatValue(2.5), FloatValue(2.5)}, 1},
+		{"zero and negative zero", []Value{FloatValue(0), FloatValue(math.Copysign(0, -1))}, 2},
+		{"int and float", []Value{IntValue(3), FloatValue(3)}, 2},
+		{"different strings", []Value{StringValue("a"), StringValue("b"), StringValue("a")}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewProgramBuilder()
+			for _, v := range tt.values {
+				builder.PushConst(v)
+			}
+			program, err := builder.Build()
+			if err != nil {
+				t.Fatalf("Build() failed: %v", err)
+			}
+			if got := len(program.(ConstantPool).Constants()); got != tt.want {
+				t.Errorf("len(Constants()) = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestBuilderNop(t *testing.T) {
 	builder := NewProgramBuilder()
 	program, err := builder.
@@ -677,3 +774,87 @@ func TestBuilderMetadata(t *testing.T) {
 		t.Errorf("Metadata.Description = %s, want 'A test program'", meta.Description)
 	}
 }
+
+func TestBuilderMetadataCreated(t *testing.T) {
+	t.Run("defaults to build time", func(t *testing.T) {
+		before := time.Now()
+		program := mustBuild(t, NewProgramBuilder().SetMetadata(ProgramMetadata{Name: "p"}).Halt())
+		after := time.Now()
+
+		created := program.Metadata().Created
+		if created.Before(before) || created.After(after) {
+			t.Errorf("Created = %v, want between %v and %v", created, before, after)
+		}
+	})
+
+	t.Run("explicit value kept", func(t *testing.T) {
+		explicit := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		program := mustBuild(t, NewProgramBuilder().SetMetadata(ProgramMetadata{Created: explicit}).Halt())
+		if created := program.Metadata().Created; !created.Equal(explicit) {
+			t.Errorf("Created = %v, want %v", created, explicit)
+		}
+
+		direct := NewProgramWithMetadata(nil, ProgramMetadata{Created: explicit})
+		if created := direct.Metadata().Created; !created.Equal(explicit) {
+			t.Errorf("NewProgramWithMetadata Created = %v, want %v", created, explicit)
+		}
+	})
+}
+
+func TestBuilderSetEntry(t *testing.T) {
+	program, err := NewProgramBuilder().
+		SetEntry("main").
+		Label("sub").
+		Ret().
+		Label("main").
+		PushInt(1).
+		Halt().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	if got := program.(EntryPointer).EntryPoint(); got != 1 {
+		t.Errorf("EntryPoint() = %d, want 1", got)
+	}
+
+	_, err = NewProgramBuilder().SetEntry("nowhere").Halt().Build()
+	if !errors.Is(err, ErrUnresolvedLabel) {
+		t.Errorf("Build() error = %v, want ErrUnresolvedLabel", err)
+	}
+}
+
+func TestBuilderSwitch(t *testing.T) {
+	build := func(selector int64) Program {
+		return mustBuild(t, NewProgramBuilder().
+			PushConst(IntValue(selector)).
+			Switch(map[int64]string{1: "one", 2: "two", 5000000000: "big"}, "other").
+			Label("one").PushInt(10).Halt().
+			Label("two").PushInt(20).Halt().
+			Label("big").PushInt(30).Halt().
+			Label("other").PushInt(-1).Halt())
+	}
+
+	tests := []struct {
+		selector int64
+		want     int64
+	}{
+		{1, 10},
+		{2, 20},
+		{5000000000, 30},
+		{3, -1},
+	}
+	for _, tt := range tests {
+		result, err := New().Execute(build(tt.selector), NewSimpleMemory(0), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() with selector %d failed: %v", tt.selector, err)
+		}
+		// The selector is consumed; only the branch's value remains.
+		if len(result.Stack) != 1 || !result.Stack[0].Equal(IntValue(tt.want)) {
+			t.Errorf("selector %d: stack = %v, want [%d]", tt.selector, result.Stack, tt.want)
+		}
+	}
+
+	if _, err := NewProgramBuilder().PushInt(1).Switch(map[int64]string{1: "missing"}, "end").Label("end").Build(); err == nil {
+		t.Error("Build() should fail for an undefined case label")
+	}
+}
@@ -0,0 +1,241 @@
+package stackvm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCallReturn(t *testing.T) {
+	program := MustAssemble(`
+		PUSHI 1
+		CALL twice
+		PUSHI 3
+		HALT
+	twice:
+		CALL once
+		CALL once
+		RET
+	once:
+		PUSHI 2
+		RET
+	`)
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	want := []Value{IntValue(1), IntValue(2), IntValue(2), IntValue(3)}
+	if len(result.Stack) != len(want) {
+		t.Fatalf("Stack = %v, want %v", result.Stack, want)
+	}
+	for i := range want {
+		if !result.Stack[i].Equal(want[i]) {
+			t.Errorf("Stack[%d] = %v, want %v", i, result.Stack[i], want[i])
+		}
+	}
+}
+
+func TestRetWithoutCallHalts(t *testing.T) {
+	program := MustAssemble("PUSHI 1\nRET\nPUSHI 2")
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if result.StackDepth != 1 || !result.Halted {
+		t.Errorf("StackDepth = %d, Halted = %v; want 1, true", result.StackDepth, result.Halted)
+	}
+}
+
+func TestCallDepthLimit(t *testing.T) {
+	program := MustAssemble("f: CALL f")
+	vm := NewWithConfig(Config{MaxCallDepth: 8})
+	_, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{MaxInstructions: 1000})
+	if !errors.Is(err, ErrCallStackOverflow) {
+		t.Fatalf("Execute() error = %v, want ErrCallStackOverflow", err)
+	}
+}
+
+func TestCustomInstructionUnwindsCalls(t *testing.T) {
+	const opThrow Opcode = 200
+
+	// THROW n unwinds n frames and resumes at the return address of the
+	// outermost one, like longjmp.
+	registry := NewInstructionRegistry()
+	err := registry.Register(opThrow, &mockHandler{
+		name: "THROW",
+		fn: func(ctx ExecutionContext, operand int32) error {
+			if ctx.CallDepth() < int(operand) {
+				return ErrCallStackUnderflow
+			}
+			var addr int
+			for i := int32(0); i < operand; i++ {
+				var err error
+				if addr, err = ctx.PopCall(); err != nil {
+					return err
+				}
+			}
+			ctx.SetPC(addr - 1)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+
+	program := mustBuild(t, NewProgramBuilder().
+		Call("outer").
+		PushInt(99). // resumes here
+		Halt().
+		Label("outer").
+		Call("inner").
+		PushInt(1). // skipped
+		Ret().
+		Label("inner").
+		Custom(opThrow, 2).
+		PushInt(2). // skipped
+		Ret())
+
+	vm := NewWithConfig(Config{InstructionRegistry: registry})
+	result, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if len(result.Stack) != 1 || !result.Stack[0].Equal(IntValue(99)) {
+		t.Errorf("Stack = %v, want [99]", result.Stack)
+	}
+}
+
+func TestExecutionContextCallStackGuards(t *testing.T) {
+	const opCheck Opcode = 201
+
+	var errs []error
+	registry := NewInstructionRegistry()
+	err := registry.Register(opCheck, &mockHandler{
+		name: "CHECK",
+		fn: func(ctx ExecutionContext, operand int32) error {
+			_, err := ctx.PopCall()
+			errs = append(errs, err)
+			errs = append(errs, ctx.PushCall(-1))
+			errs = append(errs, ctx.PushCall(100))
+			errs = append(errs, ctx.PushCall(1))
+			errs = append(errs, ctx.PushCall(1))
+			if ctx.CallDepth() != 1 {
+				t.Errorf("CallDepth() = %d, want 1", ctx.CallDepth())
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+
+	program := mustBuild(t, NewProgramBuilder().Custom(opCheck, 0).Halt())
+	vm := NewWithConfig(Config{InstructionRegistry: registry, MaxCallDepth: 1})
+	if _, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{}); err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+
+	want := []error{ErrCallStackUnderflow, ErrInvalidOperand, ErrInvalidOperand, nil, ErrCallStackOverflow}
+	for i, w := range want {
+		if !errors.Is(errs[i], w) {
+			t.Errorf("error %d = %v, want %v", i, errs[i], w)
+		}
+	}
+}
+
+func TestCallStackBacktrace(t *testing.T) {
+	program := MustAssemble(`
+	main:
+		CALL outer
+		HALT
+	outer:
+		CALL inner
+		RET
+	inner:
+		PUSHI 1
+		PUSHI 0
+		DIV
+		RET
+	`)
+	_, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if !errors.Is(err, ErrDivisionByZero) {
+		t.Fatalf("Execute() error = %v, want ErrDivisionByZero", err)
+	}
+
+	var vmErr *VMError
+	if !errors.As(err, &vmErr) {
+		t.Fatalf("Execute() error = %T, want *VMError", err)
+	}
+	if len(vmErr.CallStack) != 2 || vmErr.CallStack[0] != 1 || vmErr.CallStack[1] != 3 {
+		t.Errorf("CallStack = %v, want [1 3]", vmErr.CallStack)
+	}
+
+	want := "division by zero\n\tat 6 (inner+2)\n\tat 2 (outer)\n\tat 0 (main)"
+	if msg := err.Error(); !strings.HasSuffix(msg, want) {
+		t.Errorf("Error() = %q, want suffix %q", msg, want)
+	}
+}
+
+func TestCheckStackBalance(t *testing.T) {
+	// square takes one argument and returns one result, copy returns a
+	// result without consuming its argument, and scratch uses the stack
+	// internally but leaves it as it found it.
+	balanced := `
+		PUSHI 3
+		CALL square
+		CALL copy
+		CALL scratch
+		HALT
+	square:
+		DUP
+		MUL
+		RET
+	copy:
+		DUP
+		RET
+	scratch:
+		PUSHI 1
+		POP
+		RET
+	`
+	// leaky forgets to drop its temporary.
+	leaky := `
+		PUSHI 3
+		CALL square
+		CALL leaky
+		HALT
+	square:
+		DUP
+		MUL
+		RET
+	leaky:
+		PUSHI 1
+		RET
+	`
+	opts := ExecuteOptions{CheckStackBalance: true, StackEffects: map[string]int{"square": 0, "copy": 1}}
+
+	if _, err := New().Execute(MustAssemble(balanced), NewSimpleMemory(0), opts); err != nil {
+		t.Errorf("Execute() balanced program failed: %v", err)
+	}
+
+	_, err := New().Execute(MustAssemble(leaky), NewSimpleMemory(0), opts)
+	if !errors.Is(err, ErrStackImbalance) {
+		t.Fatalf("Execute() error = %v, want ErrStackImbalance", err)
+	}
+	var vmErr *VMError
+	if !errors.As(err, &vmErr) || vmErr.PC != 8 || !strings.Contains(vmErr.Message, "leaky") {
+		t.Errorf("Execute() error = %v, want the RET of leaky at PC 8", err)
+	}
+
+	// The declared effect is the contract: square squares in place, so
+	// declaring it as returning an extra result is a mismatch.
+	opts.StackEffects["square"] = 1
+	if _, err := New().Execute(MustAssemble(balanced), NewSimpleMemory(0), opts); !errors.Is(err, ErrStackImbalance) {
+		t.Errorf("Execute() error = %v, want ErrStackImbalance for square", err)
+	}
+
+	// Without the option the leak goes unnoticed.
+	if _, err := New().Execute(MustAssemble(leaky), NewSimpleMemory(0), ExecuteOptions{}); err != nil {
+		t.Errorf("Execute() without CheckStackBalance failed: %v", err)
+	}
+}
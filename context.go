@@ -37,6 +37,26 @@ type ExecutionContext interface {
 	// This is equivalent to SetPC(offset).
 	Jump(offset int)
 
+	// Call Stack
+	//
+	// These let a handler implement non-local control flow, such as
+	// unwinding several frames to an exception handler. Addresses are
+	// return addresses: the instruction after the CALL that pushed them.
+	// The VM advances the PC after a handler returns, so to resume at an
+	// address popped from the call stack, call SetPC(addr - 1).
+
+	// CallDepth returns the number of return addresses on the call stack.
+	CallDepth() int
+
+	// PushCall pushes a return address for a later RET. It returns
+	// ErrCallStackOverflow if the call depth limit is reached and
+	// ErrInvalidOperand if addr is outside the program.
+	PushCall(addr int) error
+
+	// PopCall removes and returns the most recent return address.
+	// It returns ErrCallStackUnderflow if the call stack is empty.
+	PopCall() (int, error)
+
 	// Memory
 
 	// Memory returns the memory provider associated with this execution.
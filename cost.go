@@ -0,0 +1,49 @@
+package stackvm
+
+// EstimateCost returns the sum of the static per-opcode costs over a
+// program's instruction stream. Each instruction is counted once, in order;
+// jumps and loops are not followed. Opcodes missing from costs count as 0.
+//
+// This gives a cheap estimate for scheduling without executing the program.
+func EstimateCost(program Program, costs map[Opcode]uint64) uint64 {
+	var total uint64
+	for i := 0; i < program.Len(); i++ {
+		inst, ok := program.InstructionAt(i)
+		if !ok {
+			break
+		}
+		total += costs[inst.Opcode]
+	}
+	return total
+}
+
+// AnalyzePushes counts the push instructions in a program by the type of
+// value they push: PUSH pushes a Float, PUSHI an Int, and PUSHC the type of
+// its constant pool entry. PUSHC instructions whose operand is outside the
+// pool are not counted. Like EstimateCost, each instruction is counted
+// once regardless of control flow.
+func AnalyzePushes(program Program) map[ValueType]int {
+	var constants []Value
+	if cp, ok := program.(ConstantPool); ok {
+		constants = cp.Constants()
+	}
+
+	counts := make(map[ValueType]int)
+	for i := 0; i < program.Len(); i++ {
+		inst, ok := program.InstructionAt(i)
+		if !ok {
+			break
+		}
+		switch inst.Opcode {
+		case OpPUSH:
+			counts[TypeFloat]++
+		case OpPUSHI:
+			counts[TypeInt]++
+		case OpPUSHC:
+			if inst.Operand >= 0 && int(inst.Operand) < len(constants) {
+				counts[constants[inst.Operand].Type]++
+			}
+		}
+	}
+	return counts
+}
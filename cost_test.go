@@ -0,0 +1,69 @@
+package stackvm
+
+import "testing"
+
+func TestEstimateCost(t *testing.T) {
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 2),
+		NewInstruction(OpPUSHI, 3),
+		NewInstruction(OpMUL, 0),
+		NewInstruction(OpSQRT, 0),
+		NewInstruction(OpJMP, 5),
+		NewInstruction(OpHALT, 0),
+	})
+	costs := map[Opcode]uint64{
+		OpPUSHI: 1,
+		OpMUL:   3,
+		OpSQRT:  10,
+		OpHALT:  1,
+	}
+
+	tests := []struct {
+		name    string
+		program Program
+		costs   map[Opcode]uint64
+		want    uint64
+	}{
+		{"custom costs", program, costs, 1 + 1 + 3 + 10 + 1},
+		{"nil cost map", program, nil, 0},
+		{"empty program", NewProgram(nil), costs, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateCost(tt.program, tt.costs); got != tt.want {
+				t.Errorf("EstimateCost() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzePushes(t *testing.T) {
+	program := mustBuild(t, NewProgramBuilder().
+		PushInt(1).
+		PushInt(2).
+		Push(3).   // PUSH
+		Push(2.5). // PUSHC float
+		Push(2.5). // shares the pool entry, still counted
+		PushConst(StringValue("s")).
+		PushInt(4).
+		Dup().
+		Halt())
+
+	got := AnalyzePushes(program)
+	want := map[ValueType]int{TypeInt: 3, TypeFloat: 3, TypeString: 1}
+	if len(got) != len(want) {
+		t.Errorf("AnalyzePushes() = %v, want %v", got, want)
+	}
+	for typ, n := range want {
+		if got[typ] != n {
+			t.Errorf("AnalyzePushes()[%d] = %d, want %d", typ, got[typ], n)
+		}
+	}
+
+	// A PUSHC outside the pool is not counted.
+	bad := NewProgram([]Instruction{NewInstruction(OpPUSHC, 0), NewInstruction(OpPUSHI, 0)})
+	if got := AnalyzePushes(bad); len(got) != 1 || got[TypeInt] != 1 {
+		t.Errorf("AnalyzePushes() with missing constant = %v, want only 1 int", got)
+	}
+}
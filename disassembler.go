@@ -2,7 +2,12 @@ package stackvm
 
 import (
 	"fmt"
+	"maps"
+	"math"
+	"strconv"
 	"strings"
+
+	"github.com/pmuston/stackvm/internal/asm"
 )
 
 // Disassembler converts bytecode programs back to assembly source.
@@ -10,10 +15,63 @@ type Disassembler interface {
 	// Disassemble converts a program to assembly source.
 	Disassemble(program Program) (string, error)
 
+	// DisassembleStructured converts a program to a list of lines, the
+	// content Disassemble renders as text.
+	DisassembleStructured(program Program) ([]DisasmLine, error)
+
 	// SetRegistry enables custom instruction names.
 	SetRegistry(registry InstructionRegistry)
 }
 
+// DisasmLineKind identifies what a DisasmLine holds.
+type DisasmLineKind uint8
+
+const (
+	DisasmInstruction DisasmLineKind = iota // An instruction, possibly labeled
+	DisasmDirective                         // A directive such as .entry or .data
+	DisasmComment                           // A comment on a line of its own
+	DisasmBlank                             // An empty separator line
+)
+
+// DisasmLine is one line of disassembly. A label is carried by the
+// instruction it names and rendered on a line of its own before it.
+type DisasmLine struct {
+	Kind DisasmLineKind
+
+	// Label is the label defined at the instruction ("" = none)
+	Label string
+
+	// Address is the instruction address, or -1 for other kinds of line
+	Address int
+
+	// Mnemonic is the instruction name, or the directive name including
+	// its leading dot
+	Mnemonic string
+
+	// Operand is the instruction operand (nil = none)
+	Operand *DisasmOperand
+
+	// Args holds a directive's arguments, such as the name and value of
+	// a .data slot
+	Args []string
+
+	// Comment is the comment text, without the leading semicolon
+	Comment string
+}
+
+// DisasmOperand is an instruction operand as it appears in disassembly.
+type DisasmOperand struct {
+	// Kind is how the instruction interprets the operand
+	Kind OperandKind
+
+	// Value is the raw operand
+	Value int32
+
+	// Text is the operand's source form: a number, a $+n offset, or the
+	// literal of a PUSHC constant
+	Text string
+}
+
 // DisassemblerOptions configures disassembler output.
 type DisassemblerOptions struct {
 	// IncludeAddresses adds instruction addresses as comments
@@ -24,6 +82,23 @@ type DisassemblerOptions struct {
 
 	// IndentInstructions indents instructions under labels
 	IndentInstructions bool
+
+	// IndentString is the indentation used when IndentInstructions is set.
+	// Defaults to four spaces.
+	IndentString string
+
+	// AlignOperands pads mnemonics to the width of the longest one so that
+	// operands line up in a column.
+	AlignOperands bool
+
+	// Registry supplies custom instruction names (optional).
+	// Equivalent to calling SetRegistry on the disassembler.
+	Registry InstructionRegistry
+
+	// TolerateUnknown emits unknown opcodes by their Opcode.String() name
+	// (e.g. CUSTOM_199) with their raw operand instead of failing. The
+	// output may not reassemble.
+	TolerateUnknown bool
 }
 
 // disassembler implements the Disassembler interface.
@@ -44,8 +119,19 @@ func NewDisassembler() Disassembler {
 // NewDisassemblerWithOptions creates a disassembler with custom options.
 func NewDisassemblerWithOptions(opts DisassemblerOptions) Disassembler {
 	return &disassembler{
-		options: opts,
+		registry: opts.Registry,
+		options:  opts,
+	}
+}
+
+// DisassembleBytes decodes a binary program produced by EncodeProgram and
+// disassembles it in one step.
+func DisassembleBytes(data []byte, opts DisassemblerOptions) (string, error) {
+	program, err := DecodeProgram(data)
+	if err != nil {
+		return "", err
 	}
+	return NewDisassemblerWithOptions(opts).Disassemble(program)
 }
 
 // SetRegistry sets the instruction registry for custom opcodes.
@@ -55,26 +141,41 @@ func (d *disassembler) SetRegistry(registry InstructionRegistry) {
 
 // Disassemble converts a program to assembly source.
 func (d *disassembler) Disassemble(program Program) (string, error) {
-	var sb strings.Builder
+	lines, err := d.DisassembleStructured(program)
+	if err != nil {
+		return "", err
+	}
+	return d.render(lines), nil
+}
 
-	// Add metadata if requested
+// DisassembleStructured converts a program to a list of lines.
+func (d *disassembler) DisassembleStructured(program Program) ([]DisasmLine, error) {
+	var lines []DisasmLine
+	directive := func(name string, args ...string) {
+		lines = append(lines, DisasmLine{Kind: DisasmDirective, Address: -1, Mnemonic: name, Args: args})
+	}
+	blank := func() {
+		lines = append(lines, DisasmLine{Kind: DisasmBlank, Address: -1})
+	}
+
+	// Add metadata if requested, as directives the assembler reads back
 	if d.options.IncludeMetadata {
 		metadata := program.Metadata()
-		if metadata.Name != "" || metadata.Version != "" || metadata.Author != "" {
-			sb.WriteString("; Program Metadata\n")
+		if metadata.Name != "" || metadata.Version != "" || metadata.Author != "" || metadata.Description != "" {
+			lines = append(lines, DisasmLine{Kind: DisasmComment, Address: -1, Comment: "Program Metadata"})
 			if metadata.Name != "" {
-				sb.WriteString(fmt.Sprintf("; Name: %s\n", metadata.Name))
+				directive(".name", metadata.Name)
 			}
 			if metadata.Version != "" {
-				sb.WriteString(fmt.Sprintf("; Version: %s\n", metadata.Version))
+				directive(".version", metadata.Version)
 			}
 			if metadata.Author != "" {
-				sb.WriteString(fmt.Sprintf("; Author: %s\n", metadata.Author))
+				directive(".author", metadata.Author)
 			}
 			if metadata.Description != "" {
-				sb.WriteString(fmt.Sprintf("; Description: %s\n", metadata.Description))
+				directive(".description", metadata.Description)
 			}
-			sb.WriteString("\n")
+			blank()
 		}
 	}
 
@@ -92,166 +193,237 @@ func (d *disassembler) Disassemble(program Program) (string, error) {
 	// Get symbol table for labels
 	symbols := program.SymbolTable()
 
-	// Disassemble instructions
-	instructions := program.Instructions()
-	for i, inst := range instructions {
-		// Check if there's a label at this address
-		if label, exists := symbols[i]; exists {
-			if i > 0 {
-				sb.WriteString("\n")
+	// Declare a non-zero entry point so the output reassembles the same way.
+	// Programs with no label there, such as decoded ones, get a placeholder.
+	if ep, ok := program.(EntryPointer); ok && ep.EntryPoint() != 0 {
+		entry := ep.EntryPoint()
+		label, exists := symbols[entry]
+		if !exists {
+			label = fmt.Sprintf("entry_%d", entry)
+			named := make(map[int]string, len(symbols)+1)
+			maps.Copy(named, symbols)
+			named[entry] = label
+			symbols = named
+		}
+		directive(".entry", label)
+		blank()
+	}
+
+	// Declare the data segment; slot names are placeholders, since LOAD
+	// and STORE operands are shown as addresses
+	if seg, ok := program.(DataSegment); ok && len(seg.Data()) > 0 {
+		data := seg.Data()
+		for i := 0; i < len(data); i++ {
+			v := data[i]
+			if v.IsNil() {
+				// Runs of nil slots are reserved with .bss
+				n := 1
+				for i+n < len(data) && data[i+n].IsNil() {
+					n++
+				}
+				directive(".bss", fmt.Sprintf("data_%d", i), strconv.Itoa(n))
+				i += n - 1
+				continue
+			}
+			literal, ok := constantLiteral(v)
+			if !ok || !v.IsNumeric() {
+				// .data accepts only numeric literals
+				return nil, fmt.Errorf("data %d: no literal for %s", i, v)
 			}
-			sb.WriteString(fmt.Sprintf("%s:\n", label))
+			directive(".data", fmt.Sprintf("data_%d", i), literal)
 		}
+		blank()
+	}
 
-		// Add address comment if requested
-		if d.options.IncludeAddresses {
-			sb.WriteString(fmt.Sprintf("; [%04d] ", i))
+	// Get constant pool for PUSHC operands
+	var constants []Value
+	if pool, ok := program.(ConstantPool); ok {
+		constants = pool.Constants()
+	}
+
+	for i := range program.Len() {
+		inst, _ := program.InstructionAt(i)
+		line, err := d.disassembleInstruction(inst, opcodeNames, constants)
+		if err != nil {
+			return nil, fmt.Errorf("error at instruction %d: %w", i, err)
 		}
+		line.Address = i
 
-		// Add indentation if requested
-		if d.options.IndentInstructions {
-			sb.WriteString("    ")
+		// Separate labeled blocks with a blank line
+		if label, exists := symbols[i]; exists {
+			if i > 0 {
+				blank()
+			}
+			line.Label = label
 		}
+		lines = append(lines, line)
+	}
 
-		// Disassemble instruction
-		line, err := d.disassembleInstruction(inst, opcodeNames)
-		if err != nil {
-			return "", fmt.Errorf("error at instruction %d: %w", i, err)
+	return lines, nil
+}
+
+// render formats lines as assembly source according to the options.
+func (d *disassembler) render(lines []DisasmLine) string {
+	// Operands line up after the longest mnemonic that has one
+	width := 0
+	for _, line := range lines {
+		if line.Kind == DisasmInstruction && line.Operand != nil && len(line.Mnemonic) > width {
+			width = len(line.Mnemonic)
 		}
+	}
 
-		sb.WriteString(line)
+	indent := d.options.IndentString
+	if indent == "" {
+		indent = "    "
+	}
+
+	var sb strings.Builder
+	for _, line := range lines {
+		switch line.Kind {
+		case DisasmDirective:
+			sb.WriteString(line.Mnemonic)
+			for _, arg := range line.Args {
+				sb.WriteString(" ")
+				sb.WriteString(arg)
+			}
+		case DisasmComment:
+			sb.WriteString("; ")
+			sb.WriteString(line.Comment)
+		case DisasmInstruction:
+			if line.Label != "" {
+				sb.WriteString(fmt.Sprintf("%s:\n", line.Label))
+			}
+
+			// Add address comment if requested
+			if d.options.IncludeAddresses {
+				sb.WriteString(fmt.Sprintf("; [%04d] ", line.Address))
+			}
+
+			// Add indentation if requested
+			if d.options.IndentInstructions {
+				sb.WriteString(indent)
+			}
+
+			sb.WriteString(line.Mnemonic)
+			if line.Operand != nil {
+				if d.options.AlignOperands {
+					sb.WriteString(strings.Repeat(" ", width-len(line.Mnemonic)))
+				}
+				sb.WriteString(" ")
+				sb.WriteString(line.Operand.Text)
+			}
+			if line.Comment != "" {
+				sb.WriteString(" ; ")
+				sb.WriteString(line.Comment)
+			}
+		}
 		sb.WriteString("\n")
 	}
 
-	return sb.String(), nil
+	return sb.String()
 }
 
-func (d *disassembler) disassembleInstruction(inst Instruction, opcodeNames map[Opcode]string) (string, error) {
+// disassembleInstruction returns the mnemonic, operand, and comment of an
+// instruction as a DisasmInstruction line. The operand is nil for
+// instructions that take none.
+func (d *disassembler) disassembleInstruction(inst Instruction, opcodeNames map[Opcode]string, constants []Value) (DisasmLine, error) {
+	line := DisasmLine{Kind: DisasmInstruction}
+	operand := func(kind OperandKind, text string) *DisasmOperand {
+		return &DisasmOperand{Kind: kind, Value: inst.Operand, Text: text}
+	}
+
 	opcodeName, exists := opcodeNames[inst.Opcode]
 	if !exists {
-		return "", fmt.Errorf("unknown opcode %d", inst.Opcode)
+		if d.options.TolerateUnknown {
+			line.Mnemonic = inst.Opcode.String()
+			line.Operand = operand(OperandNumber, fmt.Sprintf("%d", inst.Operand))
+			return line, nil
+		}
+		return line, fmt.Errorf("unknown opcode %d", inst.Opcode)
+	}
+	line.Mnemonic = opcodeName
+
+	// Constant pool references show the literal rather than the index.
+	// The assembler reads a PUSHC operand as a literal, so a value with no
+	// literal syntax can't be written out.
+	if inst.Opcode == OpPUSHC && inst.Operand >= 0 && int(inst.Operand) < len(constants) {
+		c := constants[inst.Operand]
+		literal, ok := constantLiteral(c)
+		if !ok {
+			return line, fmt.Errorf("constant %d: no literal for %s", inst.Operand, c)
+		}
+		line.Operand = operand(OperandConstant, literal)
+		return line, nil
 	}
 
 	// Instructions that don't use operands
 	if d.hasNoOperand(inst.Opcode) {
-		return opcodeName, nil
+		return line, nil
 	}
 
 	// Instructions with numeric operands
 	if d.hasNumericOperand(inst.Opcode) {
-		return fmt.Sprintf("%s %d", opcodeName, inst.Operand), nil
+		kind := OperandNumber
+		if info, ok := inst.Opcode.Info(); ok {
+			kind = info.Operand
+		}
+		line.Operand = operand(kind, fmt.Sprintf("%d", inst.Operand))
+		return line, nil
+	}
+
+	// PC-relative jumps use the assembler's $+n form
+	if info, ok := inst.Opcode.Info(); ok && info.Operand == OperandOffset {
+		line.Operand = operand(OperandOffset, fmt.Sprintf("$%+d", inst.Operand))
+		return line, nil
 	}
 
 	// Instructions with label operands (control flow)
 	// For disassembly, we just show the address
 	// A smarter version would look up the label name from symbol table
-	return fmt.Sprintf("%s %d", opcodeName, inst.Operand), nil
+	line.Operand = operand(OperandLabel, fmt.Sprintf("%d", inst.Operand))
+	return line, nil
 }
 
-func (d *disassembler) hasNoOperand(opcode Opcode) bool {
-	noOperandOps := []Opcode{
-		// Stack
-		OpPOP, OpDUP, OpSWAP, OpOVER, OpROT,
-		// Arithmetic
-		OpADD, OpSUB, OpMUL, OpDIV, OpMOD, OpNEG, OpABS, OpINC, OpDEC,
-		// Logic
-		OpAND, OpOR, OpNOT, OpXOR,
-		// Comparison
-		OpEQ, OpNE, OpGT, OpLT, OpGE, OpLE,
-		// Memory (dynamic)
-		OpLOADD, OpSTORED,
-		// Control
-		OpRET, OpHALT, OpNOP,
-		// Math
-		OpSQRT, OpSIN, OpCOS, OpTAN, OpASIN, OpACOS, OpATAN, OpATAN2,
-		OpLOG, OpLOG10, OpEXP, OpPOW,
-		OpMIN, OpMAX, OpFLOOR, OpCEIL, OpROUND, OpTRUNC,
-	}
-
-	for _, op := range noOperandOps {
-		if opcode == op {
-			return true
+// constantLiteral returns the assembly literal for a constant pool value.
+// Floats use the assembler's own literal format so they read back exactly.
+func constantLiteral(v Value) (string, bool) {
+	switch v.Type {
+	case TypeFloat:
+		f, _ := v.AsFloat()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", false
 		}
+		return asm.FormatFloat(f), true
+	case TypeInt:
+		i, _ := v.AsInt()
+		return strconv.FormatInt(i, 10), true
+	case TypeString:
+		s, _ := v.AsString()
+		return strconv.Quote(s), true
+	default:
+		return "", false
 	}
+}
 
-	return false
+func (d *disassembler) hasNoOperand(opcode Opcode) bool {
+	info, ok := opcode.Info()
+	return ok && info.Operand == OperandNone
 }
 
 func (d *disassembler) hasNumericOperand(opcode Opcode) bool {
-	// PUSH, PUSHI, LOAD, STORE, and custom instructions use numeric operands
-	return opcode == OpPUSH || opcode == OpPUSHI || opcode == OpLOAD || opcode == OpSTORE || opcode >= 128
+	// Custom instructions always show their operand as a number
+	if opcode.IsCustomOpcode() {
+		return true
+	}
+	info, ok := opcode.Info()
+	return ok && (info.Operand == OperandNumber || info.Operand == OperandConstant)
 }
 
 // makeOpcodeNameMap creates a reverse mapping from opcode to name.
 func (d *disassembler) makeOpcodeNameMap() map[Opcode]string {
-	return map[Opcode]string{
-		// Stack operations
-		OpPUSH:  "PUSH",
-		OpPUSHI: "PUSHI",
-		OpPOP:   "POP",
-		OpDUP:   "DUP",
-		OpSWAP:  "SWAP",
-		OpOVER:  "OVER",
-		OpROT:   "ROT",
-
-		// Arithmetic
-		OpADD: "ADD",
-		OpSUB: "SUB",
-		OpMUL: "MUL",
-		OpDIV: "DIV",
-		OpMOD: "MOD",
-		OpNEG: "NEG",
-		OpABS: "ABS",
-		OpINC: "INC",
-		OpDEC: "DEC",
-
-		// Logic
-		OpAND: "AND",
-		OpOR:  "OR",
-		OpNOT: "NOT",
-		OpXOR: "XOR",
-
-		// Comparison
-		OpEQ: "EQ",
-		OpNE: "NE",
-		OpGT: "GT",
-		OpLT: "LT",
-		OpGE: "GE",
-		OpLE: "LE",
-
-		// Memory
-		OpLOAD:   "LOAD",
-		OpSTORE:  "STORE",
-		OpLOADD:  "LOADD",
-		OpSTORED: "STORED",
-
-		// Control flow
-		OpJMP:   "JMP",
-		OpJMPZ:  "JMPZ",
-		OpJMPNZ: "JMPNZ",
-		OpCALL:  "CALL",
-		OpRET:   "RET",
-		OpHALT:  "HALT",
-		OpNOP:   "NOP",
-
-		// Math functions
-		OpSQRT:  "SQRT",
-		OpSIN:   "SIN",
-		OpCOS:   "COS",
-		OpTAN:   "TAN",
-		OpASIN:  "ASIN",
-		OpACOS:  "ACOS",
-		OpATAN:  "ATAN",
-		OpATAN2: "ATAN2",
-		OpLOG:   "LOG",
-		OpLOG10: "LOG10",
-		OpEXP:   "EXP",
-		OpPOW:   "POW",
-		OpMIN:   "MIN",
-		OpMAX:   "MAX",
-		OpFLOOR: "FLOOR",
-		OpCEIL:  "CEIL",
-		OpROUND: "ROUND",
-		OpTRUNC: "TRUNC",
+	names := make(map[Opcode]string, len(opcodeTable))
+	for op, info := range opcodeTable {
+		names[op] = info.Name
 	}
+	return names
 }
@@ -1,6 +1,9 @@
 package stackvm
 
 import (
+	"errors"
+	"math"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -116,6 +119,14 @@ func TestDisassembleAllOpcodes(t *testing.T) {
 		Swap().
 		Over().
 		Rot().
+		DupN(2).
+		SwapN(1).
+		Empty().
+		Pop().
+		PushInt(1).
+		MemClr(0).
+		RLoad(2).
+		RStore(3).
 		Pop().
 		Pop().
 		Pop().
@@ -138,6 +149,10 @@ func TestDisassembleAllOpcodes(t *testing.T) {
 		Or().
 		Not().
 		Xor().
+		PushInt(1).
+		Shr().
+		PushInt(1).
+		Ushr().
 		// Comparison
 		Push(5).
 		Push(3).
@@ -153,6 +168,16 @@ func TestDisassembleAllOpcodes(t *testing.T) {
 		Push(0).
 		Sin().
 		Pop().
+		// System
+		Push(1).
+		Print().
+		Fuel().
+		Pop().
+		// String
+		PushConst(StringValue("a")).
+		PushConst(StringValue("b")).
+		Concat().
+		Pop().
 		// Control
 		Halt().
 		Build()
@@ -171,6 +196,33 @@ func TestDisassembleAllOpcodes(t *testing.T) {
 	if len(output) == 0 {
 		t.Error("Disassemble() produced empty output")
 	}
+	if !strings.Contains(output, "DUPN 2") {
+		t.Errorf("output missing DUPN 2:\n%s", output)
+	}
+	if !strings.Contains(output, "EMPTY") {
+		t.Errorf("output missing EMPTY:\n%s", output)
+	}
+	if !strings.Contains(output, "SWAPN 1") {
+		t.Errorf("output missing SWAPN 1:\n%s", output)
+	}
+	if !strings.Contains(output, "RSTORE 3") {
+		t.Errorf("output missing RSTORE 3:\n%s", output)
+	}
+	if !strings.Contains(output, "MEMCLR 0") {
+		t.Errorf("output missing MEMCLR 0:\n%s", output)
+	}
+	if !strings.Contains(output, "USHR") {
+		t.Errorf("output missing USHR:\n%s", output)
+	}
+	if !strings.Contains(output, "CONCAT") {
+		t.Errorf("output missing CONCAT:\n%s", output)
+	}
+	if !strings.Contains(output, "PRINT") {
+		t.Errorf("output missing PRINT:\n%s", output)
+	}
+	if !strings.Contains(output, "FUEL") {
+		t.Errorf("output missing FUEL:\n%s", output)
+	}
 }
 
 func TestDisassembleAndReassemble(t *testing.T) {
@@ -318,3 +370,413 @@ func TestDisassemblerOptions(t *testing.T) {
 		}
 	})
 }
+
+func TestDisassembleConstants(t *testing.T) {
+	program, err := NewProgramBuilder().
+		Push(3.14).
+		PushConst(StringValue("hello")).
+		Halt().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	output, err := NewDisassemblerWithOptions(DisassemblerOptions{}).Disassemble(program)
+	if err != nil {
+		t.Fatalf("Disassemble() failed: %v", err)
+	}
+
+	want := "PUSHC 3.14\nPUSHC \"hello\"\nHALT\n"
+	if output != want {
+		t.Errorf("Disassemble() = %q, want %q", output, want)
+	}
+}
+
+func TestReassembleFloatConstants(t *testing.T) {
+	program1, err := NewAssembler().Assemble("PUSH 2.5\nPUSH -0.0\nPUSHC 7\nHALT")
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+
+	disassembled, err := NewDisassembler().Disassemble(program1)
+	if err != nil {
+		t.Fatalf("Disassemble() failed: %v", err)
+	}
+
+	program2, err := NewAssembler().Assemble(disassembled)
+	if err != nil {
+		t.Fatalf("Reassemble failed: %v\n%s", err, disassembled)
+	}
+
+	want := program1.(ConstantPool).Constants()
+	got := program2.(ConstantPool).Constants()
+	if len(got) != len(want) {
+		t.Fatalf("constant count = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || got[i].String() != want[i].String() {
+			t.Errorf("constant %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDisassembleBytes(t *testing.T) {
+	registry := NewInstructionRegistry()
+	if err := registry.Register(128, &testInstructionHandler{name: "DOUBLE"}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+
+	program, err := NewProgramBuilder().
+		Push(2.5).
+		Custom(128, 0).
+		Halt().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	data, err := EncodeProgram(program)
+	if err != nil {
+		t.Fatalf("EncodeProgram() failed: %v", err)
+	}
+
+	output, err := DisassembleBytes(data, DisassemblerOptions{Registry: registry})
+	if err != nil {
+		t.Fatalf("DisassembleBytes() failed: %v", err)
+	}
+
+	want := "PUSHC 2.5\nDOUBLE 0\nHALT\n"
+	if output != want {
+		t.Errorf("DisassembleBytes() = %q, want %q", output, want)
+	}
+
+	// Decoded programs have no symbols, so the entry point gets a label.
+	entryProgram := MustAssemble(".entry main\nsub: PUSHI 9\nHALT\nmain: PUSHI 1\nHALT")
+	data, err = EncodeProgram(entryProgram)
+	if err != nil {
+		t.Fatalf("EncodeProgram() failed: %v", err)
+	}
+	output, err = DisassembleBytes(data, DisassemblerOptions{})
+	if err != nil {
+		t.Fatalf("DisassembleBytes() failed: %v", err)
+	}
+	if !strings.Contains(output, ".entry entry_2\n") || !strings.Contains(output, "entry_2:\n") {
+		t.Errorf("DisassembleBytes() output missing entry point:\n%s", output)
+	}
+	reassembled, err := NewAssembler().Assemble(output)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v\n%s", err, output)
+	}
+	if got := reassembled.(EntryPointer).EntryPoint(); got != 2 {
+		t.Errorf("reassembled EntryPoint() = %d, want 2", got)
+	}
+
+	if _, err := DisassembleBytes([]byte("junk"), DisassemblerOptions{}); !errors.Is(err, ErrInvalidProgram) {
+		t.Errorf("DisassembleBytes(junk) error = %v, want ErrInvalidProgram", err)
+	}
+}
+
+func TestDisassembleIndentAndAlign(t *testing.T) {
+	program, err := NewProgramBuilder().
+		PushInt(1).
+		Load(12).
+		Add().
+		Custom(128, 5).
+		Halt().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	registry := NewInstructionRegistry()
+	if err := registry.Register(128, &testInstructionHandler{name: "SATURATE"}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts DisassemblerOptions
+		want string
+	}{
+		{
+			name: "tab indentation",
+			opts: DisassemblerOptions{IndentInstructions: true, IndentString: "\t"},
+			want: "\tPUSHI 1\n\tLOAD 12\n\tADD\n\tSATURATE 5\n\tHALT\n",
+		},
+		{
+			name: "aligned operands",
+			opts: DisassemblerOptions{IndentInstructions: true, AlignOperands: true},
+			want: "    PUSHI    1\n    LOAD     12\n    ADD\n    SATURATE 5\n    HALT\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Registry = registry
+			output, err := NewDisassemblerWithOptions(tt.opts).Disassemble(program)
+			if err != nil {
+				t.Fatalf("Disassemble() failed: %v", err)
+			}
+			if output != tt.want {
+				t.Errorf("Disassemble() = %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisassembleTolerateUnknown(t *testing.T) {
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 1),
+		NewInstruction(Opcode(199), 7),
+		NewInstruction(OpHALT, 0),
+	})
+
+	if _, err := NewDisassembler().Disassemble(program); err == nil {
+		t.Error("Disassemble() should fail on an unknown opcode by default")
+	}
+
+	output, err := NewDisassemblerWithOptions(DisassemblerOptions{TolerateUnknown: true}).Disassemble(program)
+	if err != nil {
+		t.Fatalf("Disassemble() failed: %v", err)
+	}
+	if want := "PUSHI 1\nCUSTOM_199 7\nHALT\n"; output != want {
+		t.Errorf("Disassemble() = %q, want %q", output, want)
+	}
+}
+
+func TestReassembleFloatRoundTrip(t *testing.T) {
+	values := []float64{
+		3.14,
+		-0.5,
+		0.1,
+		-2.718281828459045,
+		1e21,
+		6.02214076e23,
+		-1.5e-7,
+		1e-300,
+		5e9,
+		math.MaxFloat64,
+		math.SmallestNonzeroFloat64,
+		math.Copysign(0, -1),
+	}
+
+	for _, want := range values {
+		t.Run(strconv.FormatFloat(want, 'g', -1, 64), func(t *testing.T) {
+			program := mustBuild(t, NewProgramBuilder().Push(want).Halt())
+			source, err := NewDisassembler().Disassemble(program)
+			if err != nil {
+				t.Fatalf("Disassemble() failed: %v", err)
+			}
+			reassembled, err := NewAssembler().Assemble(source)
+			if err != nil {
+				t.Fatalf("Reassemble failed: %v\n%s", err, source)
+			}
+			constants := reassembled.(ConstantPool).Constants()
+			if len(constants) != 1 || constants[0].Type != TypeFloat {
+				t.Fatalf("constants = %v, want one float\n%s", constants, source)
+			}
+			if got, _ := constants[0].AsFloat(); math.Float64bits(got) != math.Float64bits(want) {
+				t.Errorf("round trip = %v, want %v\n%s", got, want, source)
+			}
+		})
+	}
+}
+
+func TestDisassembleMetadataRoundTrip(t *testing.T) {
+	metadata := ProgramMetadata{
+		Name:        "round-trip",
+		Version:     "2.1",
+		Author:      "tester",
+		Description: "Checks ; and # survive",
+	}
+	program := mustBuild(t, NewProgramBuilder().SetMetadata(metadata).PushInt(1).Halt())
+
+	source, err := NewDisassembler().Disassemble(program)
+	if err != nil {
+		t.Fatalf("Disassemble() failed: %v", err)
+	}
+	reassembled, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v\n%s", err, source)
+	}
+
+	got := reassembled.Metadata()
+	if got.Name != metadata.Name || got.Version != metadata.Version ||
+		got.Author != metadata.Author || got.Description != metadata.Description {
+		t.Errorf("metadata = %+v, want %+v\n%s", got, metadata, source)
+	}
+	if diff := ProgramDiff(program, reassembled); diff != "" {
+		t.Errorf("round trip changed program:\n%s", diff)
+	}
+}
+
+func TestDisassembleDataRoundTrip(t *testing.T) {
+	program := MustAssemble(".data a 3\n.bss buf 2\n.data b -0.5\nLOAD b\nSTORE a\nHALT")
+
+	source, err := NewDisassembler().Disassemble(program)
+	if err != nil {
+		t.Fatalf("Disassemble() failed: %v", err)
+	}
+	if !strings.Contains(source, ".bss data_1 2\n") {
+		t.Errorf("output missing .bss directive:\n%s", source)
+	}
+	reassembled, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v\n%s", err, source)
+	}
+	if diff := ProgramDiff(program, reassembled); diff != "" {
+		t.Errorf("round trip changed program:\n%s", diff)
+	}
+}
+
+func TestDisassembleDataWithoutLiteral(t *testing.T) {
+	// .data takes only numbers, and any other source would reassemble to
+	// a different initial value, so disassembly fails.
+	for _, v := range []Value{StringValue("hi"), BoolValue(true), FloatValue(math.NaN()), FloatValue(math.Inf(1))} {
+		program := mustBuild(t, NewProgramBuilder().Data(IntValue(4)).Data(v).Load(1).Halt())
+		if source, err := NewDisassembler().Disassemble(program); err == nil {
+			t.Errorf("Disassemble() with data %v = %q, want error", v, source)
+		}
+	}
+
+	// Numeric slots reassemble to the same data segment.
+	program := mustBuild(t, NewProgramBuilder().Data(IntValue(4)).Data(FloatValue(-0.5)).Load(1).Halt())
+	source, err := NewDisassembler().Disassemble(program)
+	if err != nil {
+		t.Fatalf("Disassemble() failed: %v", err)
+	}
+	reassembled, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v\n%s", err, source)
+	}
+	got, want := reassembled.(DataSegment).Data(), program.(DataSegment).Data()
+	if len(got) != len(want) {
+		t.Fatalf("Data() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("Data()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDisassembleStructured(t *testing.T) {
+	program := MustAssemble(`
+.data count 5
+start:
+    LOAD count
+    JMPZ done
+    PUSHC 2.5
+    JMPR $-3
+done:
+    HALT
+`)
+
+	lines, err := NewDisassembler().DisassembleStructured(program)
+	if err != nil {
+		t.Fatalf("DisassembleStructured() failed: %v", err)
+	}
+
+	type line struct {
+		kind     DisasmLineKind
+		label    string
+		address  int
+		mnemonic string
+		operand  *DisasmOperand
+		args     []string
+	}
+	want := []line{
+		{DisasmDirective, "", -1, ".data", nil, []string{"data_0", "5"}},
+		{DisasmBlank, "", -1, "", nil, nil},
+		{DisasmInstruction, "start", 0, "LOAD", &DisasmOperand{OperandNumber, 0, "0"}, nil},
+		{DisasmInstruction, "", 1, "JMPZ", &DisasmOperand{OperandLabel, 4, "4"}, nil},
+		{DisasmInstruction, "", 2, "PUSHC", &DisasmOperand{OperandConstant, 0, "2.5"}, nil},
+		{DisasmInstruction, "", 3, "JMPR", &DisasmOperand{OperandOffset, -3, "$-3"}, nil},
+		{DisasmBlank, "", -1, "", nil, nil},
+		{DisasmInstruction, "done", 4, "HALT", nil, nil},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(lines), len(want), lines)
+	}
+	for i, w := range want {
+		got := lines[i]
+		if got.Kind != w.kind || got.Label != w.label || got.Address != w.address || got.Mnemonic != w.mnemonic {
+			t.Errorf("line %d = %+v, want %+v", i, got, w)
+		}
+		if (got.Operand == nil) != (w.operand == nil) || (got.Operand != nil && *got.Operand != *w.operand) {
+			t.Errorf("line %d operand = %+v, want %+v", i, got.Operand, w.operand)
+		}
+		if strings.Join(got.Args, " ") != strings.Join(w.args, " ") {
+			t.Errorf("line %d args = %q, want %q", i, got.Args, w.args)
+		}
+	}
+
+}
+
+func TestDisassembleConstantRoundTrip(t *testing.T) {
+	// Constants with a literal reassemble to the same value; the rest
+	// can't be written as source, so disassembly fails.
+	tests := []struct {
+		value   Value
+		literal bool
+	}{
+		{FloatValue(2.5), true},
+		{IntValue(1 << 40), true},
+		{FloatValue(math.NaN()), false},
+		{FloatValue(math.Inf(1)), false},
+		{FloatValue(math.Inf(-1)), false},
+		{BoolValue(true), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value.String(), func(t *testing.T) {
+			program := mustBuild(t, NewProgramBuilder().PushInt(1).PushConst(tt.value).Halt())
+			source, err := NewDisassembler().Disassemble(program)
+			if !tt.literal {
+				if err == nil {
+					t.Errorf("Disassemble() = %q, want error", source)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Disassemble() failed: %v", err)
+			}
+			reassembled, err := NewAssembler().Assemble(source)
+			if err != nil {
+				t.Fatalf("Assemble() failed: %v\n%s", err, source)
+			}
+			result, err := New().Execute(reassembled, NewSimpleMemory(0), ExecuteOptions{})
+			if err != nil {
+				t.Fatalf("Execute() failed: %v", err)
+			}
+			if len(result.Stack) != 2 || !result.Stack[1].Equal(tt.value) {
+				t.Errorf("Stack = %v, want [1 %v]", result.Stack, tt.value)
+			}
+		})
+	}
+}
+
+func TestDisassembleRelativeJumps(t *testing.T) {
+	program := mustBuild(t, NewProgramBuilder().
+		PushInt(0).
+		JmpZR(2).
+		Nop().
+		JmpR(-3).
+		Halt())
+
+	source, err := NewDisassemblerWithOptions(DisassemblerOptions{}).Disassemble(program)
+	if err != nil {
+		t.Fatalf("Disassemble() failed: %v", err)
+	}
+	for _, want := range []string{"JMPZR $+2\n", "JMPR $-3\n"} {
+		if !strings.Contains(source, want) {
+			t.Errorf("output missing %q:\n%s", want, source)
+		}
+	}
+
+	reassembled, err := NewAssembler().Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+	if diff := ProgramDiff(program, reassembled); diff != "" {
+		t.Errorf("round trip changed program:\n%s", diff)
+	}
+}
@@ -0,0 +1,365 @@
+package stackvm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Binary program format.
+//
+//	[Magic: 4 bytes "SVMP"]
+//	[Version: 1 byte]
+//	[Instruction Count: 4 bytes, big-endian]
+//	[Instructions: 5 bytes each, opcode then int32 operand, big-endian]
+//	[Constant Count: 4 bytes, big-endian]             (version 2+)
+//	[Constants: 1 type byte followed by a payload]     (version 2+)
+//	[Data Count: 4 bytes, big-endian]                 (version 3+)
+//	[Data: encoded like constants]                    (version 3+)
+//	[Entry Point: 4 bytes, big-endian]                (version 4+)
+//
+// Constant payloads: float and int are 8 bytes (IEEE-754 bits and two's
+// complement respectively), bool is 1 byte, string is a 4-byte length
+// followed by the bytes, and nil has no payload.
+const (
+	// EncodingVersion is the binary format version written by EncodeProgram.
+	// Version 2 added the constant pool, version 3 the data segment, and
+	// version 4 the entry point.
+	EncodingVersion = 4
+
+	encodingMagic       = "SVMP"
+	encodingHeaderSize  = 5 // magic + version
+	encodedInstructSize = 5 // opcode + int32 operand
+)
+
+// EncodeProgram serializes a program to the binary format.
+// Constant pool and data segment values are stored losslessly; floats keep
+// their exact IEEE-754 bits. Custom-typed values cannot be encoded. The
+// entry point is kept, but the symbol table and metadata are not.
+func EncodeProgram(program Program) ([]byte, error) {
+	if program == nil {
+		return nil, fmt.Errorf("%w: nil program", ErrInvalidProgram)
+	}
+
+	instructions := programInstructions(program)
+	var constants []Value
+	if pool, ok := program.(ConstantPool); ok {
+		constants = pool.Constants()
+	}
+	var data []Value
+	if seg, ok := program.(DataSegment); ok {
+		data = seg.Data()
+	}
+
+	buf := make([]byte, 0, max(EncodedSize(program), 0))
+	buf = append(buf, encodingMagic...)
+	buf = append(buf, EncodingVersion)
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(instructions)))
+	for _, inst := range instructions {
+		buf = appendInstruction(buf, inst)
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(constants)))
+	for i, c := range constants {
+		var err error
+		buf, err = appendConstant(buf, c)
+		if err != nil {
+			return nil, fmt.Errorf("constant %d: %w", i, err)
+		}
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	for i, v := range data {
+		var err error
+		buf, err = appendConstant(buf, v)
+		if err != nil {
+			return nil, fmt.Errorf("data %d: %w", i, err)
+		}
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(programEntry(program)))
+
+	return buf, nil
+}
+
+// EncodedSize returns the number of bytes EncodeProgram produces for
+// program, without encoding it. It returns -1 if EncodeProgram would fail,
+// because the program is nil or holds a value that cannot be encoded.
+func EncodedSize(program Program) int {
+	if program == nil {
+		return -1
+	}
+	// Header, instruction count, instructions, and entry point
+	size := encodingHeaderSize + 4 + program.Len()*encodedInstructSize + 4
+
+	var constants, data []Value
+	if pool, ok := program.(ConstantPool); ok {
+		constants = pool.Constants()
+	}
+	if seg, ok := program.(DataSegment); ok {
+		data = seg.Data()
+	}
+	for _, values := range [][]Value{constants, data} {
+		size += 4
+		for _, v := range values {
+			n := encodedValueSize(v)
+			if n < 0 {
+				return -1
+			}
+			size += n
+		}
+	}
+	return size
+}
+
+// encodedValueSize returns the number of bytes appendConstant writes for
+// v, or -1 if v cannot be encoded.
+func encodedValueSize(v Value) int {
+	switch v.Type {
+	case TypeNil:
+		return 1
+	case TypeFloat, TypeInt:
+		return 1 + 8
+	case TypeBool:
+		return 1 + 1
+	case TypeString:
+		s, err := v.AsString()
+		if err != nil {
+			return -1
+		}
+		return 1 + 4 + len(s)
+	default:
+		return -1
+	}
+}
+
+// DecodeProgram deserializes a program produced by EncodeProgram.
+// Returns an error wrapping ErrInvalidProgram if the data is malformed.
+func DecodeProgram(data []byte) (Program, error) {
+	if len(data) < encodingHeaderSize || string(data[:4]) != encodingMagic {
+		return nil, fmt.Errorf("%w: missing SVMP header", ErrInvalidProgram)
+	}
+	version := data[4]
+	if version < 1 || version > EncodingVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidProgram, version)
+	}
+
+	r := &byteReader{data: data, pos: encodingHeaderSize}
+
+	count, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(count)*encodedInstructSize > uint64(r.remaining()) {
+		return nil, fmt.Errorf("%w: truncated instruction stream", ErrInvalidProgram)
+	}
+	instructions := make([]Instruction, count)
+	for i := range instructions {
+		instructions[i], r.pos, _ = DecodeInstruction(data, r.pos)
+	}
+
+	program := NewProgram(instructions)
+
+	if version >= 2 {
+		constCount, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if constCount > 0 {
+			if uint64(constCount) > uint64(r.remaining()) {
+				return nil, fmt.Errorf("%w: truncated constant pool", ErrInvalidProgram)
+			}
+			constants := make([]Value, constCount)
+			for i := range constants {
+				constants[i], err = r.constant()
+				if err != nil {
+					return nil, fmt.Errorf("constant %d: %w", i, err)
+				}
+			}
+			program.SetConstants(constants)
+		}
+	}
+
+	if version >= 3 {
+		dataCount, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if dataCount > 0 {
+			if uint64(dataCount) > uint64(r.remaining()) {
+				return nil, fmt.Errorf("%w: truncated data segment", ErrInvalidProgram)
+			}
+			data := make([]Value, dataCount)
+			for i := range data {
+				data[i], err = r.constant()
+				if err != nil {
+					return nil, fmt.Errorf("data %d: %w", i, err)
+				}
+			}
+			program.SetData(data)
+		}
+	}
+
+	if version >= 4 {
+		entry, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if entry > count {
+			return nil, fmt.Errorf("%w: entry point %d outside program of %d instructions", ErrInvalidProgram, entry, count)
+		}
+		program.SetEntryPoint(int(entry))
+	}
+
+	if r.remaining() != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidProgram, r.remaining())
+	}
+
+	return program, nil
+}
+
+// DecodeInstruction decodes the instruction starting at byte offset in
+// data and returns it with the offset just past it. In a program produced
+// by EncodeProgram the first instruction starts at offset 9, after the
+// header and instruction count, and each instruction is 5 bytes.
+// Returns an error wrapping ErrInvalidProgram if fewer than 5 bytes remain
+// at offset.
+func DecodeInstruction(data []byte, offset int) (Instruction, int, error) {
+	if offset < 0 || offset > len(data) || len(data)-offset < encodedInstructSize {
+		return Instruction{}, offset, fmt.Errorf("%w: truncated instruction at offset %d", ErrInvalidProgram, offset)
+	}
+	op := Opcode(data[offset])
+	operand := int32(binary.BigEndian.Uint32(data[offset+1:]))
+	return NewInstruction(op, operand), offset + encodedInstructSize, nil
+}
+
+// appendInstruction appends the 5-byte encoding of an instruction.
+func appendInstruction(buf []byte, inst Instruction) []byte {
+	buf = append(buf, byte(inst.Opcode))
+	return binary.BigEndian.AppendUint32(buf, uint32(inst.Operand))
+}
+
+// appendConstant appends the encoding of a constant pool value.
+func appendConstant(buf []byte, v Value) ([]byte, error) {
+	buf = append(buf, byte(v.Type))
+	switch v.Type {
+	case TypeNil:
+		return buf, nil
+	case TypeFloat:
+		f, err := v.AsFloat()
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	case TypeInt:
+		i, err := v.AsInt()
+		if err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.AppendUint64(buf, uint64(i)), nil
+	case TypeBool:
+		b, err := v.AsBool()
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case TypeString:
+		s, err := v.AsString()
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+		return append(buf, s...), nil
+	default:
+		return nil, fmt.Errorf("cannot encode value of type %d", v.Type)
+	}
+}
+
+// byteReader reads big-endian values from a byte slice with bounds checking.
+type byteReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *byteReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *byteReader) take(n int) ([]byte, error) {
+	if n < 0 || r.remaining() < n {
+		return nil, fmt.Errorf("%w: unexpected end of data at offset %d", ErrInvalidProgram, r.pos)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *byteReader) byte() (byte, error) {
+	b, err := r.take(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *byteReader) uint32() (uint32, error) {
+	b, err := r.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+func (r *byteReader) uint64() (uint64, error) {
+	b, err := r.take(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// constant reads one encoded constant pool value.
+func (r *byteReader) constant() (Value, error) {
+	typ, err := r.byte()
+	if err != nil {
+		return NilValue(), err
+	}
+	switch ValueType(typ) {
+	case TypeNil:
+		return NilValue(), nil
+	case TypeFloat:
+		bits, err := r.uint64()
+		if err != nil {
+			return NilValue(), err
+		}
+		return FloatValue(math.Float64frombits(bits)), nil
+	case TypeInt:
+		bits, err := r.uint64()
+		if err != nil {
+			return NilValue(), err
+		}
+		return IntValue(int64(bits)), nil
+	case TypeBool:
+		b, err := r.byte()
+		if err != nil {
+			return NilValue(), err
+		}
+		return BoolValue(b != 0), nil
+	case TypeString:
+		n, err := r.uint32()
+		if err != nil {
+			return NilValue(), err
+		}
+		b, err := r.take(int(n))
+		if err != nil {
+			return NilValue(), err
+		}
+		return StringValue(string(b)), nil
+	default:
+		return NilValue(), fmt.Errorf("%w: unknown constant type %d", ErrInvalidProgram, typ)
+	}
+}
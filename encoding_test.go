@@ -0,0 +1,316 @@
+package stackvm
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	program, err := NewProgramBuilder().
+		PushInt(7).
+		Push(10).
+		Push(2.5).
+		Add().
+		Label("END").
+		Halt().
+		Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	data, err := EncodeProgram(program)
+	if err != nil {
+		t.Fatalf("EncodeProgram() failed: %v", err)
+	}
+
+	decoded, err := DecodeProgram(data)
+	if err != nil {
+		t.Fatalf("DecodeProgram() failed: %v", err)
+	}
+
+	want := program.Instructions()
+	got := decoded.Instructions()
+	if len(got) != len(want) {
+		t.Fatalf("decoded %d instructions, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("instruction %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEncodeFloatConstantsBitExact(t *testing.T) {
+	values := []float64{
+		3.141592653589793,
+		math.Copysign(0, -1),
+		5e-324, // smallest denormal
+		1e300,
+		math.Inf(-1),
+	}
+
+	builder := NewProgramBuilder()
+	for _, v := range values {
+		builder.Push(v)
+	}
+	program, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	data, err := EncodeProgram(program)
+	if err != nil {
+		t.Fatalf("EncodeProgram() failed: %v", err)
+	}
+	decoded, err := DecodeProgram(data)
+	if err != nil {
+		t.Fatalf("DecodeProgram() failed: %v", err)
+	}
+
+	constants := decoded.(ConstantPool).Constants()
+	if len(constants) != len(values) {
+		t.Fatalf("decoded %d constants, want %d", len(constants), len(values))
+	}
+	for i, v := range values {
+		f, err := constants[i].AsFloat()
+		if err != nil {
+			t.Fatalf("constant %d: %v", i, err)
+		}
+		if math.Float64bits(f) != math.Float64bits(v) {
+			t.Errorf("constant %d bits = %#x, want %#x", i, math.Float64bits(f), math.Float64bits(v))
+		}
+	}
+}
+
+func TestEncodeOtherConstantTypes(t *testing.T) {
+	program := NewProgram(nil)
+	program.SetConstants([]Value{NilValue(), IntValue(-1 << 40), BoolValue(true), StringValue("hello")})
+
+	data, err := EncodeProgram(program)
+	if err != nil {
+		t.Fatalf("EncodeProgram() failed: %v", err)
+	}
+	decoded, err := DecodeProgram(data)
+	if err != nil {
+		t.Fatalf("DecodeProgram() failed: %v", err)
+	}
+
+	got := decoded.(ConstantPool).Constants()
+	for i, want := range program.Constants() {
+		if !got[i].Equal(want) {
+			t.Errorf("constant %d = %v, want %v", i, got[i], want)
+		}
+	}
+}
+
+func TestEncodeDataSegment(t *testing.T) {
+	program := mustBuild(t, NewProgramBuilder().
+		Data(IntValue(7)).
+		Data(FloatValue(2.5)).
+		Load(0).
+		Halt())
+
+	data, err := EncodeProgram(program)
+	if err != nil {
+		t.Fatalf("EncodeProgram() failed: %v", err)
+	}
+	decoded, err := DecodeProgram(data)
+	if err != nil {
+		t.Fatalf("DecodeProgram() failed: %v", err)
+	}
+	if diff := ProgramDiff(program, decoded); diff != "" {
+		t.Errorf("round trip changed program:\n%s", diff)
+	}
+
+	// Version 2 streams have no data segment (or entry point).
+	v2 := append([]byte{}, data[:len(data)-4-4-2*9]...)
+	v2[4] = 2
+	decoded, err = DecodeProgram(v2)
+	if err != nil {
+		t.Fatalf("DecodeProgram(v2) failed: %v", err)
+	}
+	if seg := decoded.(DataSegment).Data(); len(seg) != 0 {
+		t.Errorf("v2 Data() = %v, want none", seg)
+	}
+}
+
+func TestEncodeEntryPoint(t *testing.T) {
+	program := MustAssemble(".entry main\nsub: PUSHI 9\nHALT\nmain: PUSHI 1\nHALT")
+
+	data, err := EncodeProgram(program)
+	if err != nil {
+		t.Fatalf("EncodeProgram() failed: %v", err)
+	}
+	if len(data) != EncodedSize(program) {
+		t.Errorf("len(data) = %d, EncodedSize() = %d", len(data), EncodedSize(program))
+	}
+	decoded, err := DecodeProgram(data)
+	if err != nil {
+		t.Fatalf("DecodeProgram() failed: %v", err)
+	}
+	if got := decoded.(EntryPointer).EntryPoint(); got != 2 {
+		t.Errorf("EntryPoint() = %d, want 2", got)
+	}
+	result, err := New().Execute(decoded, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if len(result.Stack) != 1 || !result.Stack[0].Equal(IntValue(1)) {
+		t.Errorf("Stack = %v, want [1]", result.Stack)
+	}
+
+	// Version 3 streams have no entry point and start at 0.
+	v3 := append([]byte{}, data[:len(data)-4]...)
+	v3[4] = 3
+	decoded, err = DecodeProgram(v3)
+	if err != nil {
+		t.Fatalf("DecodeProgram(v3) failed: %v", err)
+	}
+	if got := decoded.(EntryPointer).EntryPoint(); got != 0 {
+		t.Errorf("v3 EntryPoint() = %d, want 0", got)
+	}
+
+	// An entry point just past the last instruction, which the assembler
+	// accepts, round-trips too.
+	end := MustAssemble(".entry done\nPUSHI 1\nHALT\ndone:")
+	data, err = EncodeProgram(end)
+	if err != nil {
+		t.Fatalf("EncodeProgram(entry at end) failed: %v", err)
+	}
+	decoded, err = DecodeProgram(data)
+	if err != nil {
+		t.Fatalf("DecodeProgram(entry at end) failed: %v", err)
+	}
+	if got := decoded.(EntryPointer).EntryPoint(); got != 2 {
+		t.Errorf("EntryPoint() = %d, want 2", got)
+	}
+
+	// An entry point outside the program is rejected.
+	bad := append([]byte{}, data...)
+	bad[len(bad)-1] = 3
+	if _, err := DecodeProgram(bad); !errors.Is(err, ErrInvalidProgram) {
+		t.Errorf("DecodeProgram(entry 3) error = %v, want ErrInvalidProgram", err)
+	}
+}
+
+func TestDecodeInstruction(t *testing.T) {
+	program := mustBuild(t, NewProgramBuilder().
+		PushInt(-7).
+		Push(2.5).
+		Add().
+		JmpR(-3).
+		Custom(200, 1<<20).
+		Halt())
+	data, err := EncodeProgram(program)
+	if err != nil {
+		t.Fatalf("EncodeProgram() failed: %v", err)
+	}
+	bulk, err := DecodeProgram(data)
+	if err != nil {
+		t.Fatalf("DecodeProgram() failed: %v", err)
+	}
+
+	offset := encodingHeaderSize + 4
+	for i := 0; i < bulk.Len(); i++ {
+		var inst Instruction
+		inst, offset, err = DecodeInstruction(data, offset)
+		if err != nil {
+			t.Fatalf("DecodeInstruction() at %d failed: %v", i, err)
+		}
+		if want, _ := bulk.InstructionAt(i); inst != want {
+			t.Errorf("instruction %d = %v, want %v", i, inst, want)
+		}
+	}
+
+	end := encodingHeaderSize + 4 + program.Len()*encodedInstructSize
+	if offset != end {
+		t.Errorf("final offset = %d, want %d", offset, end)
+	}
+
+	for _, bad := range []int{-1, len(data) - 4, len(data), len(data) + 1} {
+		if _, _, err := DecodeInstruction(data, bad); !errors.Is(err, ErrInvalidProgram) {
+			t.Errorf("DecodeInstruction(offset %d) error = %v, want ErrInvalidProgram", bad, err)
+		}
+	}
+}
+
+func TestDecodeProgramErrors(t *testing.T) {
+	program, _ := NewProgramBuilder().Push(1.5).Halt().Build()
+	data, _ := EncodeProgram(program)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", []byte("XXXX\x02")},
+		{"bad version", []byte("SVMP\x09")},
+		{"truncated", data[:len(data)-3]},
+		{"trailing bytes", append(append([]byte{}, data...), 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeProgram(tt.data)
+			if !errors.Is(err, ErrInvalidProgram) {
+				t.Errorf("DecodeProgram() error = %v, want ErrInvalidProgram", err)
+			}
+		})
+	}
+}
+
+func TestEncodeCustomConstantError(t *testing.T) {
+	program := NewProgram(nil)
+	program.SetConstants([]Value{CustomValue(200, "x")})
+
+	if _, err := EncodeProgram(program); err == nil {
+		t.Error("EncodeProgram() should fail for custom-typed constants")
+	}
+}
+
+func TestEncodedSize(t *testing.T) {
+	withPool := mustBuild(t, NewProgramBuilder().
+		Push(2.5).
+		PushConst(StringValue("hello")).
+		PushConst(BoolValue(true)).
+		PushConst(IntValue(1<<40)).
+		PushConst(NilValue()).
+		Halt())
+	withData := mustBuild(t, NewProgramBuilder().
+		Data(IntValue(1)).
+		Data(StringValue("")).
+		Load(0).
+		Halt())
+
+	tests := []struct {
+		name    string
+		program Program
+	}{
+		{"empty", NewProgram(nil)},
+		{"instructions only", NewProgram([]Instruction{NewInstruction(OpPUSHI, 1), NewInstruction(OpHALT, 0)})},
+		{"constant pool", withPool},
+		{"data segment", withData},
+		{"assembled", MustAssemble(".data x 7\nPUSH 1.5\nPUSHC 2.5\nADD\nHALT")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeProgram(tt.program)
+			if err != nil {
+				t.Fatalf("EncodeProgram() failed: %v", err)
+			}
+			if got := EncodedSize(tt.program); got != len(data) {
+				t.Errorf("EncodedSize() = %d, want %d", got, len(data))
+			}
+		})
+	}
+
+	custom := NewProgram(nil)
+	custom.SetConstants([]Value{CustomValue(200, "x")})
+	if got := EncodedSize(custom); got != -1 {
+		t.Errorf("EncodedSize() with custom constant = %d, want -1", got)
+	}
+	if got := EncodedSize(nil); got != -1 {
+		t.Errorf("EncodedSize(nil) = %d, want -1", got)
+	}
+}
@@ -3,6 +3,7 @@ package stackvm
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Standard VM errors.
@@ -20,6 +21,19 @@ var (
 	ErrInvalidOperand       = errors.New("invalid operand")
 	ErrInvalidProgram       = errors.New("invalid program")
 	ErrUnresolvedLabel      = errors.New("unresolved label")
+	ErrUnknownTrap          = errors.New("unknown trap")
+	ErrUnexpectedType       = errors.New("unexpected type")
+	ErrPoolClosed           = errors.New("pool is closed")
+	ErrHandlerPanic         = errors.New("instruction handler panicked")
+	ErrMemoryLimit          = errors.New("memory limit exceeded")
+	ErrInfiniteLoop         = errors.New("infinite loop detected")
+	ErrCallStackOverflow    = errors.New("call stack overflow")
+	ErrCallStackUnderflow   = errors.New("call stack underflow")
+	ErrUnregisteredOpcode   = errors.New("unregistered custom opcode")
+	ErrEndOfInput           = errors.New("end of input")
+	ErrStackImbalance       = errors.New("subroutine stack imbalance")
+	ErrUnexpectedStackDepth = errors.New("unexpected final stack depth")
+	ErrStackBytesLimit      = errors.New("stack bytes limit exceeded")
 )
 
 // VMError wraps errors with execution context.
@@ -41,16 +55,59 @@ type VMError struct {
 
 	// Message provides additional context
 	Message string
+
+	// CallStack holds the return addresses of the CALLs active at failure,
+	// outermost first. It is nil if the failure was outside any CALL.
+	CallStack []int
+
+	// Symbols names addresses in the backtrace printed by Error
+	// (nil = addresses only). It is usually the program's symbol table.
+	Symbols map[int]string
 }
 
-// Error implements the error interface.
+// Error implements the error interface. If the failure happened inside a
+// CALL, a backtrace follows with one line per frame, innermost first,
+// giving each frame's address and the nearest label at or before it.
 func (e *VMError) Error() string {
+	var msg string
 	if e.Message != "" {
-		return fmt.Sprintf("VM error at PC=%d (opcode=%d, instructions=%d, stack=%d): %s: %v",
+		msg = fmt.Sprintf("VM error at PC=%d (opcode=%d, instructions=%d, stack=%d): %s: %v",
 			e.PC, e.Opcode, e.InstructionCount, e.StackDepth, e.Message, e.Err)
+	} else {
+		msg = fmt.Sprintf("VM error at PC=%d (opcode=%d, instructions=%d, stack=%d): %v",
+			e.PC, e.Opcode, e.InstructionCount, e.StackDepth, e.Err)
+	}
+	if len(e.CallStack) == 0 {
+		return msg
+	}
+
+	var sb strings.Builder
+	sb.WriteString(msg)
+	e.writeFrame(&sb, e.PC)
+	for i := len(e.CallStack) - 1; i >= 0; i-- {
+		// The CALL is the instruction before its return address
+		e.writeFrame(&sb, e.CallStack[i]-1)
+	}
+	return sb.String()
+}
+
+// writeFrame writes one backtrace line for the instruction at addr.
+func (e *VMError) writeFrame(sb *strings.Builder, addr int) {
+	fmt.Fprintf(sb, "\n\tat %d", addr)
+	best := -1
+	for a := range e.Symbols {
+		if a <= addr && a > best {
+			best = a
+		}
+	}
+	if best < 0 {
+		return
+	}
+	if offset := addr - best; offset > 0 {
+		fmt.Fprintf(sb, " (%s+%d)", e.Symbols[best], offset)
+	} else {
+		fmt.Fprintf(sb, " (%s)", e.Symbols[best])
 	}
-	return fmt.Sprintf("VM error at PC=%d (opcode=%d, instructions=%d, stack=%d): %v",
-		e.PC, e.Opcode, e.InstructionCount, e.StackDepth, e.Err)
 }
 
 // Unwrap returns the underlying error.
@@ -63,6 +120,27 @@ func (e *VMError) Is(target error) bool {
 	return errors.Is(e.Err, target)
 }
 
+// StackOverflowError reports a push that would exceed the stack depth
+// limit. It matches ErrStackOverflow with errors.Is; use errors.As to read
+// the limit and the depth the push attempted to reach.
+type StackOverflowError struct {
+	// Limit is the configured maximum stack depth
+	Limit int
+
+	// Attempted is the depth the failing push would have produced
+	Attempted int
+}
+
+// Error implements the error interface.
+func (e *StackOverflowError) Error() string {
+	return fmt.Sprintf("%v: depth %d exceeds limit %d", ErrStackOverflow, e.Attempted, e.Limit)
+}
+
+// Is reports whether target is ErrStackOverflow.
+func (e *StackOverflowError) Is(target error) bool {
+	return target == ErrStackOverflow
+}
+
 // IsStackError returns true if the error is a stack overflow or underflow.
 func IsStackError(err error) bool {
 	return errors.Is(err, ErrStackOverflow) || errors.Is(err, ErrStackUnderflow)
@@ -23,6 +23,15 @@ func TestStandardErrors(t *testing.T) {
 		{"ErrInvalidOperand", ErrInvalidOperand},
 		{"ErrInvalidProgram", ErrInvalidProgram},
 		{"ErrUnresolvedLabel", ErrUnresolvedLabel},
+		{"ErrUnknownTrap", ErrUnknownTrap},
+		{"ErrUnexpectedType", ErrUnexpectedType},
+		{"ErrPoolClosed", ErrPoolClosed},
+		{"ErrHandlerPanic", ErrHandlerPanic},
+		{"ErrMemoryLimit", ErrMemoryLimit},
+		{"ErrInfiniteLoop", ErrInfiniteLoop},
+		{"ErrCallStackOverflow", ErrCallStackOverflow},
+		{"ErrCallStackUnderflow", ErrCallStackUnderflow},
+		{"ErrUnregisteredOpcode", ErrUnregisteredOpcode},
 	}
 
 	for _, tt := range tests {
@@ -1,17 +1,40 @@
 package stackvm
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"sort"
 	"time"
 )
 
 // executor implements the VM interface.
 type executor struct {
 	config     Config
-	stack      []Value
+	stack      StackBackend
 	pc         int
+	calls      []int          // return addresses pushed by CALL
+	registers  []Value        // RLOAD and RSTORE registers, cleared per run
+	frames     []callFrame    // entry state of active calls, for CheckStackBalance
+	codeLen    int            // length of the running program
+	symbols    map[int]string // symbol table of the running program
 	halted     bool
 	instrCount uint32
+	maxInstr   uint32 // instruction limit of the running program, for FUEL
+	reason     TerminationReason
+	opts       ExecuteOptions
+	constants  []Value
+	readAddrs  map[int]struct{} // MemTrace sets, reused across runs
+	writeAddrs map[int]struct{}
+	exitCode   int
+	tracing    bool          // record trace entries; set by Trace
+	allocBytes int           // approximate bytes allocated, for MaxMemoryBytes
+	stackBytes int           // bytes held by values on the stack, for MaxStackBytes
+	trace      []TraceEntry  // entries recorded while tracing
+	loops      *loopDetector // state history for DetectLoops, reused across runs
+	scratch    [3]Value      // operands moved off the stack by applyOp
 }
 
 // newExecutor creates a new executor with the given configuration.
@@ -19,95 +42,225 @@ func newExecutor(config Config) *executor {
 	if config.StackSize <= 0 {
 		config.StackSize = 256
 	}
+	if config.MaxCallDepth <= 0 {
+		config.MaxCallDepth = 256
+	}
+	if config.NumRegisters <= 0 {
+		config.NumRegisters = 16
+	}
+	var stack StackBackend
+	if config.StackBackend != nil {
+		stack = config.StackBackend()
+	} else {
+		stack = newSliceStack(config.StackSize)
+	}
 	return &executor{
-		config: config,
-		stack:  make([]Value, 0, config.StackSize),
+		config:    config,
+		stack:     stack,
+		registers: make([]Value, config.NumRegisters),
 	}
 }
 
+// callFrame records the subroutine entered by a CALL and the stack depth
+// at the CALL.
+type callFrame struct {
+	target int // subroutine address, or -1 if pushed by a handler
+	depth  int
+}
+
+// execRun holds the settings of one execution, shared by every slice of
+// it that the execution loop runs.
+type execRun struct {
+	instructions    []Instruction
+	memory          Memory
+	dispatch        OpcodeFunc // middleware chain, or nil
+	ectx            ExecutionContext
+	ctx             context.Context
+	deadline        time.Time
+	maxInstructions uint32
+	maxStackDepth   int
+	startTime       time.Time
+}
+
 // Execute runs the program with the given memory and options.
 func (e *executor) Execute(program Program, memory Memory, opts ExecuteOptions) (*Result, error) {
-	startTime := time.Now()
+	run, err := e.start(program, memory, opts)
+	if err != nil {
+		return e.result(run.startTime, err), err
+	}
+	_, _, err = e.loop(run, 0)
+	return e.result(run.startTime, err), err
+}
+
+// ExecuteFrom runs a program starting at startPC.
+func (e *executor) ExecuteFrom(program Program, memory Memory, startPC int, opts ExecuteOptions) (*Result, error) {
+	if n := program.Len(); startPC < 0 || startPC >= n {
+		return nil, fmt.Errorf("%w: start PC %d out of range [0, %d)", ErrInvalidOperand, startPC, n)
+	}
+	run, err := e.start(program, memory, opts)
+	if err != nil {
+		return e.result(run.startTime, err), err
+	}
+	e.pc = startPC
+	_, _, err = e.loop(run, 0)
+	return e.result(run.startTime, err), err
+}
+
+// start resets the VM state and prepares an execution of program. The
+// returned run is valid even if seeding the stack fails.
+func (e *executor) start(program Program, memory Memory, opts ExecuteOptions) (*execRun, error) {
+	run := &execRun{memory: memory, startTime: time.Now()}
 
 	// Reset state
-	e.stack = e.stack[:0]
+	e.stack.Reset()
 	e.pc = 0
+	e.calls = e.calls[:0]
+	e.frames = e.frames[:0]
+	clear(e.registers)
 	e.halted = false
 	e.instrCount = 0
+	e.reason = TerminationExplicitHalt
+	e.exitCode = 0
+	e.allocBytes = 0
+	e.stackBytes = 0
+	e.opts = opts
+	// Tracing state keeps its storage between runs, so pooled VMs do not
+	// reallocate it on every execution
+	if opts.MemTrace {
+		if e.readAddrs == nil {
+			e.readAddrs = make(map[int]struct{})
+			e.writeAddrs = make(map[int]struct{})
+		}
+		clear(e.readAddrs)
+		clear(e.writeAddrs)
+	}
+	if opts.DetectLoops {
+		if e.loops == nil {
+			e.loops = newLoopDetector()
+		}
+		e.loops.reset()
+	}
 
 	// Apply options
-	maxInstructions := opts.MaxInstructions
-	if maxInstructions == 0 && e.config.DefaultInstrLimit > 0 {
-		maxInstructions = e.config.DefaultInstrLimit
+	run.maxInstructions = opts.MaxInstructions
+	if run.maxInstructions == 0 && e.config.DefaultInstrLimit > 0 {
+		run.maxInstructions = e.config.DefaultInstrLimit
 	}
+	e.maxInstr = run.maxInstructions
 
-	maxStackDepth := opts.MaxStackDepth
-	if maxStackDepth <= 0 {
-		maxStackDepth = e.config.StackSize
+	run.maxStackDepth = opts.MaxStackDepth
+	if run.maxStackDepth <= 0 {
+		run.maxStackDepth = e.config.StackSize
+	}
+
+	// Seed the stack; the first value ends up at the bottom
+	for _, v := range opts.InitialStack {
+		if err := e.push(v, run.maxStackDepth); err != nil {
+			return run, err
+		}
 	}
 
 	// Set up context for timeout/cancellation
-	ctx := opts.Context
-	var deadline time.Time
+	run.ctx = opts.Context
 	if opts.Timeout > 0 {
-		deadline = startTime.Add(opts.Timeout)
+		run.deadline = run.startTime.Add(opts.Timeout)
+	}
+
+	run.instructions = programInstructions(program)
+	e.codeLen = len(run.instructions)
+	e.symbols = program.SymbolTable()
+	if ep, ok := program.(EntryPointer); ok {
+		e.pc = ep.EntryPoint()
+	}
+	e.constants = nil
+	if pool, ok := program.(ConstantPool); ok {
+		e.constants = pool.Constants()
+	}
+	if seg, ok := program.(DataSegment); ok {
+		for addr, v := range seg.Data() {
+			if err := memory.Store(addr, v); err != nil {
+				return run, fmt.Errorf("initializing data at address %d: %w", addr, err)
+			}
+		}
 	}
 
-	instructions := program.Instructions()
+	// Wrap instruction dispatch in the configured middleware
+	if len(e.config.Middleware) > 0 {
+		run.dispatch = func(_ ExecutionContext, inst Instruction) error {
+			return e.executeInstruction(inst, memory, run.maxStackDepth)
+		}
+		for i := len(e.config.Middleware) - 1; i >= 0; i-- {
+			run.dispatch = e.config.Middleware[i](run.dispatch)
+		}
+		run.ectx = newExecutionContext(e, memory)
+	}
+
+	return run, nil
+}
+
+// loop executes instructions until the program finishes, fails, or budget
+// instructions have run (0 = no budget). It reports how many instructions
+// ran and whether the program finished; a failed program has finished.
+func (e *executor) loop(run *execRun, budget uint32) (uint32, bool, error) {
+	instructions := run.instructions
+	var ran uint32
+	e.reason = TerminationExplicitHalt // resuming after a yield
 
 	// Main execution loop
 	for !e.halted && e.pc >= 0 && e.pc < len(instructions) {
-		// Check instruction limit
-		if maxInstructions > 0 && e.instrCount >= maxInstructions {
-			return &Result{
-				InstructionCount: e.instrCount,
-				StackDepth:       len(e.stack),
-				ExecutionTime:    time.Since(startTime),
-				Halted:           false,
-				Error:            ErrInstructionLimit,
-			}, ErrInstructionLimit
+		if budget > 0 && ran >= budget {
+			e.reason = TerminationYielded
+			return ran, false, nil
 		}
 
-		// Check timeout
-		if !deadline.IsZero() && time.Now().After(deadline) {
-			return &Result{
-				InstructionCount: e.instrCount,
-				StackDepth:       len(e.stack),
-				ExecutionTime:    time.Since(startTime),
-				Halted:           false,
-				Error:            ErrTimeout,
-			}, ErrTimeout
-		}
-
-		// Check context cancellation
-		if ctx != nil {
-			select {
-			case <-ctx.Done():
-				err := ctx.Err()
-				return &Result{
-					InstructionCount: e.instrCount,
-					StackDepth:       len(e.stack),
-					ExecutionTime:    time.Since(startTime),
-					Halted:           false,
-					Error:            err,
-				}, err
-			default:
-			}
+		// Check instruction limit, timeout, and cancellation
+		if err := e.checkLimits(run.ctx, run.deadline, run.maxInstructions); err != nil {
+			return ran, true, err
 		}
 
 		// Fetch instruction
 		inst := instructions[e.pc]
+		if e.opts.DetectLoops && e.loops.visit(e.stateHash(run.memory)) {
+			return ran, true, &VMError{
+				Err:              ErrInfiniteLoop,
+				PC:               e.pc,
+				InstructionCount: e.instrCount,
+				StackDepth:       e.stack.Depth(),
+				Opcode:           inst.Opcode,
+			}
+		}
+		if watch, ok := e.opts.Watchpoints[e.pc]; ok {
+			watch(stackValues(e.stack))
+		}
 		e.instrCount++
+		ran++
 
 		// Execute instruction
-		if err := e.executeInstruction(inst, memory, maxStackDepth); err != nil {
-			return &Result{
-				InstructionCount: e.instrCount,
-				StackDepth:       len(e.stack),
-				ExecutionTime:    time.Since(startTime),
-				Halted:           e.halted,
-				Error:            err,
-			}, err
+		pc := e.pc
+		var err error
+		if e.opts.StrictOperands && inst.Operand != 0 {
+			if info, ok := inst.Opcode.Info(); ok && info.Operand == OperandNone {
+				return ran, true, e.wrapError(&VMError{
+					Err:              ErrInvalidOperand,
+					PC:               pc,
+					InstructionCount: e.instrCount,
+					StackDepth:       e.stack.Depth(),
+					Opcode:           inst.Opcode,
+					Message:          fmt.Sprintf("%s takes no operand, got %d", inst.Opcode, inst.Operand),
+				}, pc, inst)
+			}
+		}
+		if run.dispatch != nil {
+			err = run.dispatch(run.ectx, inst)
+		} else {
+			err = e.executeInstruction(inst, run.memory, run.maxStackDepth)
+		}
+		if err != nil {
+			return ran, true, e.wrapError(err, pc, inst)
+		}
+		if e.tracing && (e.opts.MaxTrace <= 0 || len(e.trace) < e.opts.MaxTrace) {
+			top, _ := e.stack.Peek(0)
+			e.trace = append(e.trace, TraceEntry{PC: pc, Instruction: inst, StackDepth: e.stack.Depth(), Top: top})
 		}
 
 		// Move to next instruction (unless a jump occurred or halted)
@@ -117,26 +270,182 @@ func (e *executor) Execute(program Program, memory Memory, opts ExecuteOptions)
 	}
 
 	// Check if we ran out of instructions without halting
-	if !e.halted && e.pc >= len(instructions) {
-		// Reached end of program without HALT - this is allowed
-		e.halted = true
+	if !e.halted {
+		e.reason = TerminationEndOfProgram
+		if e.pc >= len(instructions) {
+			// Reached end of program without HALT - this is allowed
+			e.halted = true
+		}
 	}
+	if e.halted {
+		if err := e.checkFinalDepth(instructions); err != nil {
+			return ran, true, err
+		}
+	}
+
+	return ran, true, nil
+}
 
-	return &Result{
+// checkFinalDepth verifies the stack depth of a halted program against
+// ExpectStackDepth.
+func (e *executor) checkFinalDepth(instructions []Instruction) error {
+	want := e.opts.ExpectStackDepth
+	if want == nil || e.stack.Depth() == *want {
+		return nil
+	}
+	err := &VMError{
+		Err:              ErrUnexpectedStackDepth,
+		PC:               e.pc,
 		InstructionCount: e.instrCount,
-		StackDepth:       len(e.stack),
-		ExecutionTime:    time.Since(startTime),
-		Halted:           e.halted,
-		Error:            nil,
-	}, nil
+		StackDepth:       e.stack.Depth(),
+		Message:          fmt.Sprintf("halted with %d values on the stack, want %d", e.stack.Depth(), *want),
+	}
+	if e.pc < len(instructions) {
+		err.Opcode = instructions[e.pc].Opcode
+	}
+	return err
+}
+
+// checkLimits reports whether execution must stop before the next
+// instruction is fetched. All three limits are checked at the same point,
+// so when one fires InstructionCount is exactly the number of instructions
+// that completed and HaltPC is the instruction that did not run.
+func (e *executor) checkLimits(ctx context.Context, deadline time.Time, maxInstructions uint32) error {
+	if maxInstructions > 0 && e.instrCount >= maxInstructions {
+		return ErrInstructionLimit
+	}
+
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return ErrTimeout
+	}
+
+	if ctx != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	return nil
+}
+
+// result builds a Result from the current executor state.
+func (e *executor) result(startTime time.Time, err error) *Result {
+	reason := e.reason
+	if err != nil {
+		reason = TerminationError
+	}
+	result := &Result{
+		InstructionCount:  e.instrCount,
+		StackDepth:        e.stack.Depth(),
+		ExecutionTime:     time.Since(startTime),
+		Halted:            e.halted,
+		ExitCode:          e.exitCode,
+		HaltPC:            e.pc,
+		TerminationReason: reason,
+		Error:             err,
+	}
+	result.Stack = stackValues(e.stack)
+	if e.opts.MemTrace {
+		result.ReadAddrs = sortedAddrs(e.readAddrs)
+		result.WriteAddrs = sortedAddrs(e.writeAddrs)
+	}
+	return result
+}
+
+// ExecuteSafe validates the program before delegating to Execute.
+func (e *executor) ExecuteSafe(program Program, memory Memory, opts ExecuteOptions) (*Result, error) {
+	if err := ValidateProgram(program); err != nil {
+		return nil, err
+	}
+	if err := ValidateCustomOpcodes(program, e.config.InstructionRegistry); err != nil {
+		return nil, err
+	}
+	return e.Execute(program, memory, opts)
 }
 
 // Reset clears the VM state for reuse.
 func (e *executor) Reset() {
-	e.stack = e.stack[:0]
+	e.stack.Reset()
 	e.pc = 0
+	e.calls = e.calls[:0]
+	e.frames = e.frames[:0]
+	clear(e.registers)
 	e.halted = false
 	e.instrCount = 0
+	e.maxInstr = 0
+	e.reason = TerminationExplicitHalt
+	e.exitCode = 0
+	e.stackBytes = 0
+	e.opts = ExecuteOptions{}
+	e.constants = nil
+	e.symbols = nil
+	clear(e.readAddrs)
+	clear(e.writeAddrs)
+	if e.loops != nil {
+		e.loops.reset()
+	}
+}
+
+// pushCall pushes a return address onto the call stack. The address must
+// be within the program; the program length itself is allowed and ends
+// execution when returned to.
+func (e *executor) pushCall(addr int) error {
+	if len(e.calls) >= e.config.MaxCallDepth {
+		return fmt.Errorf("%w: depth %d exceeds limit %d", ErrCallStackOverflow, len(e.calls)+1, e.config.MaxCallDepth)
+	}
+	if addr < 0 || addr > e.codeLen {
+		return fmt.Errorf("%w: return address %d outside program", ErrInvalidOperand, addr)
+	}
+	e.calls = append(e.calls, addr)
+	if e.opts.CheckStackBalance {
+		e.frames = append(e.frames, callFrame{target: -1, depth: e.stack.Depth()})
+	}
+	return nil
+}
+
+// popCall removes and returns the most recent return address.
+func (e *executor) popCall() (int, error) {
+	if len(e.calls) == 0 {
+		return 0, ErrCallStackUnderflow
+	}
+	addr := e.calls[len(e.calls)-1]
+	e.calls = e.calls[:len(e.calls)-1]
+	if len(e.frames) > 0 {
+		e.frames = e.frames[:len(e.frames)-1]
+	}
+	return addr, nil
+}
+
+// checkStackBalance verifies that the subroutine returning from the
+// innermost CALL changed the stack depth by its declared stack effect, or
+// left it unchanged if it has none.
+func (e *executor) checkStackBalance(inst Instruction) error {
+	if len(e.frames) == 0 {
+		return nil
+	}
+	frame := e.frames[len(e.frames)-1]
+	if frame.target < 0 {
+		return nil
+	}
+	name := e.symbols[frame.target]
+	want := e.opts.StackEffects[name]
+	got := e.stack.Depth() - frame.depth
+	if got == want {
+		return nil
+	}
+	if name == "" {
+		name = fmt.Sprintf("at %d", frame.target)
+	}
+	return &VMError{
+		Err:              ErrStackImbalance,
+		PC:               e.pc,
+		InstructionCount: e.instrCount,
+		StackDepth:       e.stack.Depth(),
+		Opcode:           inst.Opcode,
+		Message:          fmt.Sprintf("subroutine %s changed the stack depth by %d, want %d", name, got, want),
+	}
 }
 
 // executeInstruction executes a single instruction.
@@ -149,6 +458,33 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 		return e.push(FloatValue(float64(inst.Operand)), maxStackDepth)
 	case OpPUSHI:
 		return e.push(IntValue(int64(inst.Operand)), maxStackDepth)
+	case OpPUSHC:
+		if inst.Operand < 0 || int(inst.Operand) >= len(e.constants) {
+			return ErrInvalidOperand
+		}
+		return e.push(e.constants[inst.Operand], maxStackDepth)
+	case OpDUPN:
+		return e.dupN(int(inst.Operand), maxStackDepth)
+	case OpSWAPN:
+		return e.swapN(int(inst.Operand))
+	case OpEMPTY:
+		return e.push(BoolValue(e.stack.Depth() == 0), maxStackDepth)
+	case OpTYPECHK:
+		val, err := e.peek()
+		if err != nil {
+			return err
+		}
+		if want := ValueType(inst.Operand); inst.Operand < 0 || inst.Operand > 255 || val.Type != want {
+			return &VMError{
+				Err:              ErrUnexpectedType,
+				PC:               e.pc,
+				InstructionCount: e.instrCount,
+				StackDepth:       e.stack.Depth(),
+				Opcode:           inst.Opcode,
+				Message:          fmt.Sprintf("got type %d, want type %d", val.Type, inst.Operand),
+			}
+		}
+		return nil
 	case OpPOP:
 		_, err = e.pop()
 		return err
@@ -159,111 +495,115 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 		}
 		return e.push(val, maxStackDepth)
 	case OpSWAP:
-		if len(e.stack) < 2 {
-			return ErrStackUnderflow
-		}
-		top := len(e.stack) - 1
-		e.stack[top], e.stack[top-1] = e.stack[top-1], e.stack[top]
-		return nil
+		return e.applyOp(inst.Opcode, func(stack []Value) ([]Value, error) {
+			stack[0], stack[1] = stack[1], stack[0]
+			return stack, nil
+		})
 	case OpOVER:
-		if len(e.stack) < 2 {
-			return ErrStackUnderflow
+		val, err := e.peekN(1)
+		if err != nil {
+			return err
 		}
-		val := e.stack[len(e.stack)-2]
 		return e.push(val, maxStackDepth)
 	case OpROT:
-		if len(e.stack) < 3 {
-			return ErrStackUnderflow
-		}
-		top := len(e.stack) - 1
-		e.stack[top-2], e.stack[top-1], e.stack[top] = e.stack[top-1], e.stack[top], e.stack[top-2]
-		return nil
+		return e.applyOp(inst.Opcode, func(stack []Value) ([]Value, error) {
+			stack[0], stack[1], stack[2] = stack[1], stack[2], stack[0]
+			return stack, nil
+		})
 
 	// Arithmetic operations
 	case OpADD:
-		e.stack, err = opAdd(e.stack)
+		err = e.applyOp(inst.Opcode, e.opAdd)
 	case OpSUB:
-		e.stack, err = opSub(e.stack)
+		err = e.applyOp(inst.Opcode, e.opSub)
 	case OpMUL:
-		e.stack, err = opMul(e.stack)
+		err = e.applyOp(inst.Opcode, e.opMul)
 	case OpDIV:
-		e.stack, err = opDiv(e.stack)
+		err = e.applyOp(inst.Opcode, e.opDiv)
 	case OpMOD:
-		e.stack, err = opMod(e.stack)
+		err = e.applyOp(inst.Opcode, e.opMod)
 	case OpNEG:
-		e.stack, err = opNeg(e.stack)
+		err = e.applyOp(inst.Opcode, e.opNeg)
 	case OpABS:
-		e.stack, err = opAbs(e.stack)
+		err = e.applyOp(inst.Opcode, e.opAbs)
 	case OpINC:
-		e.stack, err = opInc(e.stack)
+		err = e.applyOp(inst.Opcode, e.opInc)
 	case OpDEC:
-		e.stack, err = opDec(e.stack)
+		err = e.applyOp(inst.Opcode, e.opDec)
+	case OpTOF:
+		err = e.applyOp(inst.Opcode, e.opToFloat)
+	case OpTOI:
+		err = e.applyOp(inst.Opcode, e.opToInt)
 
 	// Logic operations
 	case OpAND:
-		e.stack, err = opAnd(e.stack)
+		err = e.applyOp(inst.Opcode, e.opAnd)
 	case OpOR:
-		e.stack, err = opOr(e.stack)
+		err = e.applyOp(inst.Opcode, e.opOr)
 	case OpNOT:
-		e.stack, err = opNot(e.stack)
+		err = e.applyOp(inst.Opcode, e.opNot)
 	case OpXOR:
-		e.stack, err = opXor(e.stack)
+		err = e.applyOp(inst.Opcode, e.opXor)
+	case OpSHR:
+		err = e.applyOp(inst.Opcode, e.opShr)
+	case OpUSHR:
+		err = e.applyOp(inst.Opcode, e.opUshr)
 
 	// Comparison operations
 	case OpEQ:
-		e.stack, err = opEq(e.stack)
+		err = e.applyOp(inst.Opcode, e.opEq)
 	case OpNE:
-		e.stack, err = opNe(e.stack)
+		err = e.applyOp(inst.Opcode, e.opNe)
 	case OpGT:
-		e.stack, err = opGt(e.stack)
+		err = e.applyOp(inst.Opcode, e.opGt)
 	case OpLT:
-		e.stack, err = opLt(e.stack)
+		err = e.applyOp(inst.Opcode, e.opLt)
 	case OpGE:
-		e.stack, err = opGe(e.stack)
+		err = e.applyOp(inst.Opcode, e.opGe)
 	case OpLE:
-		e.stack, err = opLe(e.stack)
+		err = e.applyOp(inst.Opcode, e.opLe)
 
 	// Math functions
 	case OpSQRT:
-		e.stack, err = opSqrt(e.stack)
+		err = e.applyOp(inst.Opcode, e.opSqrt)
 	case OpSIN:
-		e.stack, err = opSin(e.stack)
+		err = e.applyOp(inst.Opcode, e.opSin)
 	case OpCOS:
-		e.stack, err = opCos(e.stack)
+		err = e.applyOp(inst.Opcode, e.opCos)
 	case OpTAN:
-		e.stack, err = opTan(e.stack)
+		err = e.applyOp(inst.Opcode, e.opTan)
 	case OpASIN:
-		e.stack, err = opAsin(e.stack)
+		err = e.applyOp(inst.Opcode, e.opAsin)
 	case OpACOS:
-		e.stack, err = opAcos(e.stack)
+		err = e.applyOp(inst.Opcode, e.opAcos)
 	case OpATAN:
-		e.stack, err = opAtan(e.stack)
+		err = e.applyOp(inst.Opcode, e.opAtan)
 	case OpATAN2:
-		e.stack, err = opAtan2(e.stack)
+		err = e.applyOp(inst.Opcode, e.opAtan2)
 	case OpLOG:
-		e.stack, err = opLog(e.stack)
+		err = e.applyOp(inst.Opcode, e.opLog)
 	case OpLOG10:
-		e.stack, err = opLog10(e.stack)
+		err = e.applyOp(inst.Opcode, e.opLog10)
 	case OpEXP:
-		e.stack, err = opExp(e.stack)
+		err = e.applyOp(inst.Opcode, e.opExp)
 	case OpPOW:
-		e.stack, err = opPow(e.stack)
+		err = e.applyOp(inst.Opcode, e.opPow)
 	case OpMIN:
-		e.stack, err = opMin(e.stack)
+		err = e.applyOp(inst.Opcode, e.opMin)
 	case OpMAX:
-		e.stack, err = opMax(e.stack)
+		err = e.applyOp(inst.Opcode, e.opMax)
 	case OpFLOOR:
-		e.stack, err = opFloor(e.stack)
+		err = e.applyOp(inst.Opcode, e.opFloor)
 	case OpCEIL:
-		e.stack, err = opCeil(e.stack)
+		err = e.applyOp(inst.Opcode, e.opCeil)
 	case OpROUND:
-		e.stack, err = opRound(e.stack)
+		err = e.applyOp(inst.Opcode, e.opRound)
 	case OpTRUNC:
-		e.stack, err = opTrunc(e.stack)
+		err = e.applyOp(inst.Opcode, e.opTrunc)
 
 	// Memory operations
 	case OpLOAD:
-		val, err := memory.Load(int(inst.Operand))
+		val, err := e.load(memory, int(inst.Operand))
 		if err != nil {
 			return err
 		}
@@ -273,17 +613,17 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 		if err != nil {
 			return err
 		}
-		return memory.Store(int(inst.Operand), val)
+		return e.store(memory, int(inst.Operand), val)
 	case OpLOADD:
 		addr, err := e.pop()
 		if err != nil {
 			return err
 		}
-		addrInt, err := toInt64(addr)
+		addrInt, err := e.toInt64(addr)
 		if err != nil {
 			return err
 		}
-		val, err := memory.Load(int(addrInt))
+		val, err := e.load(memory, int(addrInt))
 		if err != nil {
 			return err
 		}
@@ -297,11 +637,36 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 		if err != nil {
 			return err
 		}
-		addrInt, err := toInt64(addr)
+		addrInt, err := e.toInt64(addr)
+		if err != nil {
+			return err
+		}
+		return e.store(memory, int(addrInt), val)
+	case OpMEMCLR:
+		n, err := e.pop()
 		if err != nil {
 			return err
 		}
-		return memory.Store(int(addrInt), val)
+		length, err := e.toInt64(n)
+		if err != nil {
+			return err
+		}
+		return e.clearMemory(memory, int(inst.Operand), length)
+	case OpRLOAD:
+		if err := e.checkRegister(inst.Operand); err != nil {
+			return err
+		}
+		return e.push(e.registers[inst.Operand], maxStackDepth)
+	case OpRSTORE:
+		if err := e.checkRegister(inst.Operand); err != nil {
+			return err
+		}
+		val, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.registers[inst.Operand] = val
+		return nil
 
 	// Control flow
 	case OpJMP:
@@ -313,7 +678,7 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 		if err != nil {
 			return err
 		}
-		if !toBool(val) {
+		if !e.toBool(val) {
 			e.pc = int(inst.Operand) - 1
 		}
 		return nil
@@ -322,19 +687,54 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 		if err != nil {
 			return err
 		}
-		if toBool(val) {
+		if e.toBool(val) {
 			e.pc = int(inst.Operand) - 1
 		}
 		return nil
+	case OpJMPR:
+		// The offset is relative to this instruction (subtract 1 because main loop increments)
+		e.pc += int(inst.Operand) - 1
+		return nil
+	case OpJMPZR:
+		val, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if !e.toBool(val) {
+			e.pc += int(inst.Operand) - 1
+		}
+		return nil
+	case OpJMPNZR:
+		val, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if e.toBool(val) {
+			e.pc += int(inst.Operand) - 1
+		}
+		return nil
 	case OpCALL:
-		// TODO: Implement call stack for proper CALL/RET support
-		// For now, just jump to the address
+		if err := e.pushCall(e.pc + 1); err != nil {
+			return err
+		}
+		if e.opts.CheckStackBalance {
+			e.frames[len(e.frames)-1].target = int(inst.Operand)
+		}
 		e.pc = int(inst.Operand) - 1
 		return nil
 	case OpRET:
-		// TODO: Implement call stack for proper CALL/RET support
-		// For now, just halt
-		e.halted = true
+		// A RET with no caller ends the program
+		if len(e.calls) == 0 {
+			e.halted = true
+			return nil
+		}
+		if e.opts.CheckStackBalance {
+			if err := e.checkStackBalance(inst); err != nil {
+				return err
+			}
+		}
+		addr, _ := e.popCall()
+		e.pc = addr - 1
 		return nil
 	case OpHALT:
 		e.halted = true
@@ -342,6 +742,47 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 	case OpNOP:
 		// No operation
 		return nil
+	case OpEXIT:
+		val, err := e.pop()
+		if err != nil {
+			return err
+		}
+		code, err := e.toInt64(val)
+		if err != nil {
+			return err
+		}
+		e.exitCode = int(code)
+		e.halted = true
+		return nil
+
+	// System operations
+	case OpTRAP:
+		handler, exists := e.config.TrapHandlers[inst.Operand]
+		if !exists {
+			return fmt.Errorf("%w: %d", ErrUnknownTrap, inst.Operand)
+		}
+		return handler(newExecutionContext(e, memory))
+	case OpPRINT:
+		val, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if e.config.Output == nil {
+			return nil
+		}
+		_, err = io.WriteString(e.config.Output, val.String()+"\n")
+		return err
+	case OpFUEL:
+		// The count already includes this instruction
+		fuel := int64(-1)
+		if e.maxInstr > 0 {
+			fuel = int64(e.maxInstr) - int64(e.instrCount)
+		}
+		return e.push(IntValue(fuel), maxStackDepth)
+
+	// String operations
+	case OpCONCAT:
+		err = e.applyOp(inst.Opcode, e.opConcat)
 
 	default:
 		// Check for custom instructions
@@ -349,7 +790,11 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 			handler, exists := e.config.InstructionRegistry.Get(inst.Opcode)
 			if exists {
 				ctx := newExecutionContext(e, memory)
-				return handler.Execute(ctx, inst.Operand)
+				err := e.callHandler(handler, ctx, inst)
+				if err != nil && e.opts.OnCustomError != nil {
+					return e.opts.OnCustomError(err, e.pc)
+				}
+				return err
 			}
 		}
 		return ErrInvalidOpcode
@@ -358,42 +803,316 @@ func (e *executor) executeInstruction(inst Instruction, memory Memory, maxStackD
 	return err
 }
 
+// wrapError wraps a bare stack overflow, or any error raised inside a
+// CALL, in a VMError recording where it happened. A VMError raised inside
+// a CALL gains the call stack. Other errors are returned unchanged.
+func (e *executor) wrapError(err error, pc int, inst Instruction) error {
+	var calls []int
+	if len(e.calls) > 0 {
+		calls = append(calls, e.calls...)
+	}
+	if vmErr, ok := err.(*VMError); ok {
+		if vmErr.CallStack == nil && calls != nil {
+			vmErr.CallStack = calls
+			vmErr.Symbols = e.symbols
+		}
+		return vmErr
+	}
+	if _, ok := err.(*StackOverflowError); !ok && calls == nil && !errors.Is(err, ErrEndOfInput) {
+		return err
+	}
+	return &VMError{
+		Err:              err,
+		PC:               pc,
+		InstructionCount: e.instrCount,
+		StackDepth:       e.stack.Depth(),
+		Opcode:           inst.Opcode,
+		CallStack:        calls,
+		Symbols:          e.symbols,
+	}
+}
+
+// callHandler runs a custom instruction handler, converting a panic into
+// an ErrHandlerPanic VMError so a faulty handler cannot crash the host.
+func (e *executor) callHandler(handler InstructionHandler, ctx ExecutionContext, inst Instruction) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &VMError{
+				Err:              ErrHandlerPanic,
+				PC:               e.pc,
+				InstructionCount: e.instrCount,
+				StackDepth:       e.stack.Depth(),
+				Opcode:           inst.Opcode,
+				Message:          fmt.Sprint(r),
+			}
+		}
+	}()
+	return handler.Execute(ctx, inst.Operand)
+}
+
+// Memory access helpers
+
+// load reads a memory slot. It enforces the memory window and records the
+// address when tracing is enabled.
+func (e *executor) load(memory Memory, addr int) (Value, error) {
+	if addr < 0 || !e.opts.MemoryWindow.contains(addr) {
+		return NilValue(), ErrInvalidMemoryAddress
+	}
+	val, err := memory.Load(addr)
+	if err != nil {
+		return val, err
+	}
+	if e.opts.MemTrace {
+		e.readAddrs[addr] = struct{}{}
+	}
+	if e.opts.DetectLoops {
+		e.loops.addrs[addr] = struct{}{}
+	}
+	return val, nil
+}
+
+// store writes a memory slot. It enforces the memory window and records the
+// address when tracing is enabled.
+func (e *executor) store(memory Memory, addr int, val Value) error {
+	if addr < 0 || !e.opts.MemoryWindow.contains(addr) {
+		return ErrInvalidMemoryAddress
+	}
+	if err := memory.Store(addr, val); err != nil {
+		return err
+	}
+	if e.opts.MemTrace {
+		e.writeAddrs[addr] = struct{}{}
+	}
+	if e.opts.DetectLoops {
+		e.loops.addrs[addr] = struct{}{}
+	}
+	return nil
+}
+
+// checkRegister reports an error if n is not a register number.
+func (e *executor) checkRegister(n int32) error {
+	if n < 0 || int(n) >= len(e.registers) {
+		return fmt.Errorf("%w: register %d out of range [0, %d)", ErrInvalidOperand, n, len(e.registers))
+	}
+	return nil
+}
+
+// clearMemory sets length slots starting at start to nil. The whole range
+// is checked against the memory size and window first, so a failing
+// MEMCLR leaves memory unchanged.
+func (e *executor) clearMemory(memory Memory, start int, length int64) error {
+	if length < 0 {
+		return fmt.Errorf("%w: MEMCLR length %d is negative", ErrInvalidOperand, length)
+	}
+	if length == 0 {
+		return nil
+	}
+	if start < 0 || length > int64(memory.Size()-start) {
+		return ErrInvalidMemoryAddress
+	}
+	end := start + int(length)
+	if !e.opts.MemoryWindow.contains(start) || !e.opts.MemoryWindow.contains(end-1) {
+		return ErrInvalidMemoryAddress
+	}
+	for addr := start; addr < end; addr++ {
+		if err := e.store(memory, addr, NilValue()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedAddrs returns the keys of an address set in ascending order.
+func sortedAddrs(set map[int]struct{}) []int {
+	addrs := make([]int, 0, len(set))
+	for addr := range set {
+		addrs = append(addrs, addr)
+	}
+	sort.Ints(addrs)
+	return addrs
+}
+
 // Stack operation helpers
 
 func (e *executor) push(val Value, maxStackDepth int) error {
-	if len(e.stack) >= maxStackDepth {
-		return ErrStackOverflow
+	if depth := e.stack.Depth(); depth >= maxStackDepth {
+		return &StackOverflowError{Limit: maxStackDepth, Attempted: depth + 1}
+	}
+	if err := e.account(val); err != nil {
+		return err
+	}
+	if err := e.holdBytes(val); err != nil {
+		return err
+	}
+	return e.stack.Push(val)
+}
+
+// dupN pushes a copy of the top n values, keeping their order. Underflow
+// and overflow are checked before anything is pushed.
+func (e *executor) dupN(n int, maxStackDepth int) error {
+	depth := e.stack.Depth()
+	if n < 0 {
+		return ErrInvalidOperand
+	}
+	if depth < n {
+		return ErrStackUnderflow
+	}
+	if depth+n > maxStackDepth {
+		return &StackOverflowError{Limit: maxStackDepth, Attempted: depth + n}
+	}
+	for i := 0; i < n; i++ {
+		// Each push moves the next value to copy to depth n-1
+		val, err := e.stack.Peek(n - 1)
+		if err != nil {
+			return err
+		}
+		if err := e.account(val); err != nil {
+			return err
+		}
+		if err := e.holdBytes(val); err != nil {
+			return err
+		}
+		if err := e.stack.Push(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// swapN exchanges the top value with the value n places below it. The
+// stack backend can only reach the top, so the n+1 values are popped and
+// pushed back with the two ends exchanged.
+func (e *executor) swapN(n int) error {
+	if n < 0 {
+		return ErrInvalidOperand
+	}
+	if e.stack.Depth() <= n {
+		return ErrStackUnderflow
+	}
+	if n == 0 {
+		return nil
+	}
+	values := make([]Value, n+1)
+	for i := range values {
+		values[i], _ = e.stack.Pop()
+	}
+	values[0], values[n] = values[n], values[0]
+	for i := n; i >= 0; i-- {
+		if err := e.stack.Push(values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOp runs a slice-based operation on the top of the stack. The values
+// the opcode pops are moved into a scratch slice, bottom first, and the
+// values op returns are pushed back in their place, counted against the
+// memory limits like any other push. Ops never push more values than they
+// pop, so no depth check is needed.
+func (e *executor) applyOp(opcode Opcode, op func(stack []Value) ([]Value, error)) error {
+	info, _ := opcode.Info()
+	if e.stack.Depth() < info.Pops {
+		return ErrStackUnderflow
+	}
+	args := e.scratch[:info.Pops]
+	for i := len(args) - 1; i >= 0; i-- {
+		args[i], _ = e.stack.Pop()
+		e.releaseBytes(args[i])
+	}
+	results, err := op(args)
+	for _, v := range results {
+		if accountErr := e.account(v); accountErr != nil && err == nil {
+			err = accountErr
+		}
+		if holdErr := e.holdBytes(v); holdErr != nil && err == nil {
+			err = holdErr
+		}
+		if pushErr := e.stack.Push(v); pushErr != nil && err == nil {
+			err = pushErr
+		}
+	}
+	return err
+}
+
+// account adds a pushed value to the allocation counter and enforces
+// ExecuteOptions.MaxMemoryBytes.
+func (e *executor) account(val Value) error {
+	if e.opts.MaxMemoryBytes <= 0 || val.Type != TypeString {
+		return nil
+	}
+	s, _ := val.AsString()
+	e.allocBytes += len(s)
+	if e.allocBytes > e.opts.MaxMemoryBytes {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrMemoryLimit, e.allocBytes, e.opts.MaxMemoryBytes)
 	}
-	e.stack = append(e.stack, val)
 	return nil
 }
 
+// holdBytes adds a value about to be pushed to the stack byte count and
+// enforces ExecuteOptions.MaxStackBytes.
+func (e *executor) holdBytes(val Value) error {
+	if e.opts.MaxStackBytes <= 0 {
+		return nil
+	}
+	n := e.stackBytes + stackBytesOf(val)
+	if n > e.opts.MaxStackBytes {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrStackBytesLimit, n, e.opts.MaxStackBytes)
+	}
+	e.stackBytes = n
+	return nil
+}
+
+// releaseBytes removes a popped value from the stack byte count.
+func (e *executor) releaseBytes(val Value) {
+	if e.opts.MaxStackBytes > 0 {
+		e.stackBytes -= stackBytesOf(val)
+	}
+}
+
+// stackBytesOf returns the bytes a value counts toward MaxStackBytes.
+func stackBytesOf(val Value) int {
+	if val.Type != TypeString && val.Type < 128 {
+		return 0
+	}
+	switch data := val.Data.(type) {
+	case string:
+		return len(data)
+	case []byte:
+		return len(data)
+	default:
+		return 0
+	}
+}
+
 func (e *executor) pop() (Value, error) {
-	if len(e.stack) == 0 {
-		return NilValue(), ErrStackUnderflow
+	val, err := e.stack.Pop()
+	if err == nil {
+		e.releaseBytes(val)
 	}
-	val := e.stack[len(e.stack)-1]
-	e.stack = e.stack[:len(e.stack)-1]
-	return val, nil
+	return val, err
 }
 
 func (e *executor) peek() (Value, error) {
-	if len(e.stack) == 0 {
-		return NilValue(), ErrStackUnderflow
-	}
-	return e.stack[len(e.stack)-1], nil
+	return e.stack.Peek(0)
 }
 
 func (e *executor) peekN(n int) (Value, error) {
-	if n < 0 || n >= len(e.stack) {
-		return NilValue(), ErrStackUnderflow
-	}
-	return e.stack[len(e.stack)-1-n], nil
+	return e.stack.Peek(n)
 }
 
-// Conversion helpers for numeric operations (for future use)
+// Conversion helpers for numeric operations.
+//
+// When Config.ValueConverter is set it is consulted first for values that are
+// not already of the target type. If the converter fails, or returns a value
+// of the wrong type, the built-in coercion rules apply.
 
-func toFloat64(v Value) (float64, error) {
+func (e *executor) toFloat64(v Value) (float64, error) {
+	if v.Type != TypeFloat {
+		if c, ok := e.convert(v, TypeFloat); ok {
+			v = c
+		}
+	}
 	switch v.Type {
 	case TypeFloat:
 		return v.AsFloat()
@@ -408,7 +1127,12 @@ func toFloat64(v Value) (float64, error) {
 	}
 }
 
-func toInt64(v Value) (int64, error) {
+func (e *executor) toInt64(v Value) (int64, error) {
+	if v.Type != TypeInt {
+		if c, ok := e.convert(v, TypeInt); ok {
+			v = c
+		}
+	}
 	switch v.Type {
 	case TypeInt:
 		return v.AsInt()
@@ -423,16 +1147,34 @@ func toInt64(v Value) (int64, error) {
 	}
 }
 
-func toBool(v Value) bool {
+func (e *executor) toBool(v Value) bool {
+	if v.Type != TypeBool {
+		if c, ok := e.convert(v, TypeBool); ok {
+			v = c
+		}
+	}
 	return v.IsTruthy()
 }
 
-func numericOp(a, b Value, op func(float64, float64) float64) (Value, error) {
-	aVal, err := toFloat64(a)
+// convert applies the configured ValueConverter, reporting whether it
+// produced a value of the target type.
+func (e *executor) convert(v Value, target ValueType) (Value, bool) {
+	if e.config.ValueConverter == nil {
+		return v, false
+	}
+	c, err := e.config.ValueConverter.Convert(v, target)
+	if err != nil || c.Type != target {
+		return v, false
+	}
+	return c, true
+}
+
+func (e *executor) numericOp(a, b Value, op func(float64, float64) float64) (Value, error) {
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return NilValue(), err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return NilValue(), err
 	}
@@ -440,12 +1182,12 @@ func numericOp(a, b Value, op func(float64, float64) float64) (Value, error) {
 	return FloatValue(result), nil
 }
 
-func compareOp(a, b Value, op func(float64, float64) bool) (Value, error) {
-	aVal, err := toFloat64(a)
+func (e *executor) compareOp(a, b Value, op func(float64, float64) bool) (Value, error) {
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return NilValue(), err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return NilValue(), err
 	}
@@ -453,8 +1195,8 @@ func compareOp(a, b Value, op func(float64, float64) bool) (Value, error) {
 	return BoolValue(result), nil
 }
 
-func unaryMathOp(v Value, op func(float64) float64) (Value, error) {
-	val, err := toFloat64(v)
+func (e *executor) unaryMathOp(v Value, op func(float64) float64) (Value, error) {
+	val, err := e.toFloat64(v)
 	if err != nil {
 		return NilValue(), err
 	}
@@ -466,3 +1208,13 @@ func unaryMathOp(v Value, op func(float64) float64) (Value, error) {
 func isValidFloat(f float64) bool {
 	return !math.IsNaN(f) && !math.IsInf(f, 0)
 }
+
+// programInstructions returns the instruction slice for execution. For a
+// SimpleProgram the internal slice is used directly to avoid copying on every
+// run; the executor never modifies it.
+func programInstructions(program Program) []Instruction {
+	if p, ok := program.(*SimpleProgram); ok {
+		return p.instructions
+	}
+	return program.Instructions()
+}
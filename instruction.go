@@ -7,13 +7,18 @@ type Opcode uint8
 
 // Stack operations (0-15)
 const (
-	OpPUSH  Opcode = 0  // Push immediate value (as float)
-	OpPUSHI Opcode = 1  // Push immediate value (as int)
-	OpPOP   Opcode = 2  // Remove top of stack
-	OpDUP   Opcode = 3  // Duplicate top
-	OpSWAP  Opcode = 4  // Exchange top two
-	OpOVER  Opcode = 5  // Copy second to top
-	OpROT   Opcode = 6  // Rotate top three
+	OpPUSH    Opcode = 0  // Push immediate value (as float)
+	OpPUSHI   Opcode = 1  // Push immediate value (as int)
+	OpPOP     Opcode = 2  // Remove top of stack
+	OpDUP     Opcode = 3  // Duplicate top
+	OpSWAP    Opcode = 4  // Exchange top two
+	OpOVER    Opcode = 5  // Copy second to top
+	OpROT     Opcode = 6  // Rotate top three
+	OpPUSHC   Opcode = 7  // Push constant pool entry[operand]
+	OpTYPECHK Opcode = 8  // Fail unless top has ValueType operand
+	OpDUPN    Opcode = 9  // Duplicate the top operand values as a block
+	OpSWAPN   Opcode = 10 // Exchange top with the value operand places below it
+	OpEMPTY   Opcode = 11 // Push whether the stack is empty
 )
 
 // Arithmetic operations (16-31)
@@ -27,14 +32,18 @@ const (
 	OpABS Opcode = 22 // Absolute value
 	OpINC Opcode = 23 // Increment
 	OpDEC Opcode = 24 // Decrement
+	OpTOF Opcode = 25 // Convert to float
+	OpTOI Opcode = 26 // Convert to int (truncating)
 )
 
 // Logic operations (32-39)
 const (
-	OpAND Opcode = 32 // Logical AND
-	OpOR  Opcode = 33 // Logical OR
-	OpNOT Opcode = 34 // Logical NOT
-	OpXOR Opcode = 35 // Logical XOR
+	OpAND  Opcode = 32 // Logical AND
+	OpOR   Opcode = 33 // Logical OR
+	OpNOT  Opcode = 34 // Logical NOT
+	OpXOR  Opcode = 35 // Logical XOR
+	OpSHR  Opcode = 36 // Arithmetic shift right (sign-preserving)
+	OpUSHR Opcode = 37 // Logical shift right (zero-fill)
 )
 
 // Comparison operations (40-47)
@@ -53,6 +62,9 @@ const (
 	OpSTORE  Opcode = 49 // Store to memory[index]
 	OpLOADD  Opcode = 50 // Load from memory[pop()]
 	OpSTORED Opcode = 51 // Store to memory[pop()]
+	OpMEMCLR Opcode = 52 // Clear memory[index:index+pop()]
+	OpRLOAD  Opcode = 53 // Load from register[index]
+	OpRSTORE Opcode = 54 // Store to register[index]
 )
 
 // Control flow operations (56-63)
@@ -64,6 +76,7 @@ const (
 	OpRET   Opcode = 60 // Return from subroutine
 	OpHALT  Opcode = 61 // Stop execution
 	OpNOP   Opcode = 62 // No operation
+	OpEXIT  Opcode = 63 // Stop execution with exit code pop()
 )
 
 // Math functions (64-81)
@@ -88,6 +101,27 @@ const (
 	OpTRUNC  Opcode = 81 // Truncate toward zero
 )
 
+// Relative control flow operations (88-95). The operand is a signed offset
+// from the instruction's own address, so code using them can be moved
+// without rewriting operands.
+const (
+	OpJMPR   Opcode = 88 // Jump to PC + offset
+	OpJMPZR  Opcode = 89 // Jump to PC + offset if zero/false
+	OpJMPNZR Opcode = 90 // Jump to PC + offset if non-zero/true
+)
+
+// System operations (96-103)
+const (
+	OpTRAP  Opcode = 96 // Call host trap handler[operand]
+	OpPRINT Opcode = 97 // Write pop() to the configured output
+	OpFUEL  Opcode = 98 // Push the remaining instruction budget
+)
+
+// String operations (104-111)
+const (
+	OpCONCAT Opcode = 104 // Concatenate two strings
+)
+
 // Custom operations (128-255) are reserved for host-defined extensions.
 
 // Instruction represents a VM instruction with an opcode and operand.
@@ -131,6 +165,16 @@ func (op Opcode) String() string {
 		return "OVER"
 	case OpROT:
 		return "ROT"
+	case OpPUSHC:
+		return "PUSHC"
+	case OpTYPECHK:
+		return "TYPECHK"
+	case OpDUPN:
+		return "DUPN"
+	case OpSWAPN:
+		return "SWAPN"
+	case OpEMPTY:
+		return "EMPTY"
 
 	// Arithmetic operations
 	case OpADD:
@@ -151,6 +195,10 @@ func (op Opcode) String() string {
 		return "INC"
 	case OpDEC:
 		return "DEC"
+	case OpTOF:
+		return "TOF"
+	case OpTOI:
+		return "TOI"
 
 	// Logic operations
 	case OpAND:
@@ -161,6 +209,10 @@ func (op Opcode) String() string {
 		return "NOT"
 	case OpXOR:
 		return "XOR"
+	case OpSHR:
+		return "SHR"
+	case OpUSHR:
+		return "USHR"
 
 	// Comparison operations
 	case OpEQ:
@@ -185,6 +237,12 @@ func (op Opcode) String() string {
 		return "LOADD"
 	case OpSTORED:
 		return "STORED"
+	case OpMEMCLR:
+		return "MEMCLR"
+	case OpRLOAD:
+		return "RLOAD"
+	case OpRSTORE:
+		return "RSTORE"
 
 	// Control flow operations
 	case OpJMP:
@@ -201,6 +259,14 @@ func (op Opcode) String() string {
 		return "HALT"
 	case OpNOP:
 		return "NOP"
+	case OpEXIT:
+		return "EXIT"
+	case OpJMPR:
+		return "JMPR"
+	case OpJMPZR:
+		return "JMPZR"
+	case OpJMPNZR:
+		return "JMPNZR"
 
 	// Math functions
 	case OpSQRT:
@@ -240,6 +306,18 @@ func (op Opcode) String() string {
 	case OpTRUNC:
 		return "TRUNC"
 
+	// System operations
+	case OpTRAP:
+		return "TRAP"
+	case OpPRINT:
+		return "PRINT"
+	case OpFUEL:
+		return "FUEL"
+
+	// String operations
+	case OpCONCAT:
+		return "CONCAT"
+
 	default:
 		// Custom opcodes (128-255) or unknown
 		if op >= 128 {
@@ -258,3 +336,83 @@ func (op Opcode) IsStandardOpcode() bool {
 func (op Opcode) IsCustomOpcode() bool {
 	return op >= 128
 }
+
+// OpcodeCategory groups opcodes by the range they are allocated from.
+type OpcodeCategory uint8
+
+const (
+	CategoryUnknown    OpcodeCategory = iota // Not a defined opcode
+	CategoryStack                            // 0-15
+	CategoryArithmetic                       // 16-31
+	CategoryLogic                            // 32-39
+	CategoryComparison                       // 40-47
+	CategoryMemory                           // 48-55
+	CategoryControlFlow                      // 56-63, 88-95
+	CategoryMath                             // 64-81
+	CategorySystem                           // 96-103
+	CategoryCustom                           // 128-255
+	CategoryString                           // 104-111
+)
+
+// String returns the category name.
+func (c OpcodeCategory) String() string {
+	switch c {
+	case CategoryStack:
+		return "Stack"
+	case CategoryArithmetic:
+		return "Arithmetic"
+	case CategoryLogic:
+		return "Logic"
+	case CategoryComparison:
+		return "Comparison"
+	case CategoryMemory:
+		return "Memory"
+	case CategoryControlFlow:
+		return "ControlFlow"
+	case CategoryMath:
+		return "Math"
+	case CategorySystem:
+		return "System"
+	case CategoryString:
+		return "String"
+	case CategoryCustom:
+		return "Custom"
+	default:
+		return "Unknown"
+	}
+}
+
+// Category returns the category of the opcode. Standard opcodes that are
+// not defined, even within an allocated range, report CategoryUnknown.
+func (op Opcode) Category() OpcodeCategory {
+	if op.IsCustomOpcode() {
+		return CategoryCustom
+	}
+	if !isDefinedOpcode(op) {
+		return CategoryUnknown
+	}
+	switch {
+	case op <= 15:
+		return CategoryStack
+	case op <= 31:
+		return CategoryArithmetic
+	case op <= 39:
+		return CategoryLogic
+	case op <= 47:
+		return CategoryComparison
+	case op <= 55:
+		return CategoryMemory
+	case op <= 63:
+		return CategoryControlFlow
+	case op <= 81:
+		return CategoryMath
+	case op >= 88 && op <= 95:
+		return CategoryControlFlow
+	case op >= 96 && op <= 103:
+		return CategorySystem
+	case op >= 104 && op <= 111:
+		return CategoryString
+	default:
+		return CategoryUnknown
+	}
+}
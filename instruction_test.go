@@ -43,6 +43,10 @@ func TestOpcodeString(t *testing.T) {
 		{"SWAP", OpSWAP, "SWAP"},
 		{"OVER", OpOVER, "OVER"},
 		{"ROT", OpROT, "ROT"},
+		{"TYPECHK", OpTYPECHK, "TYPECHK"},
+		{"DUPN", OpDUPN, "DUPN"},
+		{"SWAPN", OpSWAPN, "SWAPN"},
+		{"EMPTY", OpEMPTY, "EMPTY"},
 
 		// Arithmetic operations
 		{"ADD", OpADD, "ADD"},
@@ -54,6 +58,8 @@ func TestOpcodeString(t *testing.T) {
 		{"ABS", OpABS, "ABS"},
 		{"INC", OpINC, "INC"},
 		{"DEC", OpDEC, "DEC"},
+		{"TOF", OpTOF, "TOF"},
+		{"TOI", OpTOI, "TOI"},
 
 		// Logic operations
 		{"AND", OpAND, "AND"},
@@ -73,7 +79,12 @@ func TestOpcodeString(t *testing.T) {
 		{"LOAD", OpLOAD, "LOAD"},
 		{"STORE", OpSTORE, "STORE"},
 		{"LOADD", OpLOADD, "LOADD"},
+		{"SHR", OpSHR, "SHR"},
+		{"USHR", OpUSHR, "USHR"},
 		{"STORED", OpSTORED, "STORED"},
+		{"MEMCLR", OpMEMCLR, "MEMCLR"},
+		{"RLOAD", OpRLOAD, "RLOAD"},
+		{"RSTORE", OpRSTORE, "RSTORE"},
 
 		// Control flow operations
 		{"JMP", OpJMP, "JMP"},
@@ -83,6 +94,10 @@ func TestOpcodeString(t *testing.T) {
 		{"RET", OpRET, "RET"},
 		{"HALT", OpHALT, "HALT"},
 		{"NOP", OpNOP, "NOP"},
+		{"EXIT", OpEXIT, "EXIT"},
+		{"JMPR", OpJMPR, "JMPR"},
+		{"JMPZR", OpJMPZR, "JMPZR"},
+		{"JMPNZR", OpJMPNZR, "JMPNZR"},
 
 		// Math functions
 		{"SQRT", OpSQRT, "SQRT"},
@@ -104,6 +119,12 @@ func TestOpcodeString(t *testing.T) {
 		{"ROUND", OpROUND, "ROUND"},
 		{"TRUNC", OpTRUNC, "TRUNC"},
 
+		// System operations
+		{"TRAP", OpTRAP, "TRAP"},
+		{"PRINT", OpPRINT, "PRINT"},
+		{"FUEL", OpFUEL, "FUEL"},
+		{"CONCAT", OpCONCAT, "CONCAT"},
+
 		// Custom opcodes
 		{"Custom 128", Opcode(128), "CUSTOM_128"},
 		{"Custom 200", Opcode(200), "CUSTOM_200"},
@@ -194,7 +215,7 @@ func TestOpcodeIsCustomOpcode(t *testing.T) {
 
 func TestOpcodeRanges(t *testing.T) {
 	t.Run("Stack operations are 0-15", func(t *testing.T) {
-		stackOps := []Opcode{OpPUSH, OpPUSHI, OpPOP, OpDUP, OpSWAP, OpOVER, OpROT}
+		stackOps := []Opcode{OpPUSH, OpPUSHI, OpPOP, OpDUP, OpSWAP, OpOVER, OpROT, OpPUSHC, OpTYPECHK, OpDUPN, OpSWAPN, OpEMPTY}
 		for _, op := range stackOps {
 			if op < 0 || op > 15 {
 				t.Errorf("Stack operation %v (%d) is not in range 0-15", op, op)
@@ -203,7 +224,7 @@ func TestOpcodeRanges(t *testing.T) {
 	})
 
 	t.Run("Arithmetic operations are 16-31", func(t *testing.T) {
-		arithOps := []Opcode{OpADD, OpSUB, OpMUL, OpDIV, OpMOD, OpNEG, OpABS, OpINC, OpDEC}
+		arithOps := []Opcode{OpADD, OpSUB, OpMUL, OpDIV, OpMOD, OpNEG, OpABS, OpINC, OpDEC, OpTOF, OpTOI}
 		for _, op := range arithOps {
 			if op < 16 || op > 31 {
 				t.Errorf("Arithmetic operation %v (%d) is not in range 16-31", op, op)
@@ -212,7 +233,7 @@ func TestOpcodeRanges(t *testing.T) {
 	})
 
 	t.Run("Logic operations are 32-39", func(t *testing.T) {
-		logicOps := []Opcode{OpAND, OpOR, OpNOT, OpXOR}
+		logicOps := []Opcode{OpAND, OpOR, OpNOT, OpXOR, OpSHR, OpUSHR}
 		for _, op := range logicOps {
 			if op < 32 || op > 39 {
 				t.Errorf("Logic operation %v (%d) is not in range 32-39", op, op)
@@ -230,7 +251,7 @@ func TestOpcodeRanges(t *testing.T) {
 	})
 
 	t.Run("Memory operations are 48-55", func(t *testing.T) {
-		memOps := []Opcode{OpLOAD, OpSTORE, OpLOADD, OpSTORED}
+		memOps := []Opcode{OpLOAD, OpSTORE, OpLOADD, OpSTORED, OpMEMCLR, OpRLOAD, OpRSTORE}
 		for _, op := range memOps {
 			if op < 48 || op > 55 {
 				t.Errorf("Memory operation %v (%d) is not in range 48-55", op, op)
@@ -239,7 +260,7 @@ func TestOpcodeRanges(t *testing.T) {
 	})
 
 	t.Run("Control flow operations are 56-63", func(t *testing.T) {
-		ctrlOps := []Opcode{OpJMP, OpJMPZ, OpJMPNZ, OpCALL, OpRET, OpHALT, OpNOP}
+		ctrlOps := []Opcode{OpJMP, OpJMPZ, OpJMPNZ, OpCALL, OpRET, OpHALT, OpNOP, OpEXIT}
 		for _, op := range ctrlOps {
 			if op < 56 || op > 63 {
 				t.Errorf("Control flow operation %v (%d) is not in range 56-63", op, op)
@@ -247,6 +268,15 @@ func TestOpcodeRanges(t *testing.T) {
 		}
 	})
 
+	t.Run("Relative control flow operations are 88-95", func(t *testing.T) {
+		relOps := []Opcode{OpJMPR, OpJMPZR, OpJMPNZR}
+		for _, op := range relOps {
+			if op < 88 || op > 95 {
+				t.Errorf("Relative control flow operation %v (%d) is not in range 88-95", op, op)
+			}
+		}
+	})
+
 	t.Run("Math functions are 64-81", func(t *testing.T) {
 		mathOps := []Opcode{
 			OpSQRT, OpSIN, OpCOS, OpTAN, OpASIN, OpACOS, OpATAN, OpATAN2,
@@ -288,3 +318,43 @@ func TestOpcodeValues(t *testing.T) {
 		})
 	}
 }
+
+func TestOpcodeCategory(t *testing.T) {
+	tests := []struct {
+		opcode Opcode
+		want   OpcodeCategory
+	}{
+		{OpPUSH, CategoryStack},
+		{OpPUSHC, CategoryStack},
+		{OpTYPECHK, CategoryStack},
+		{OpMOD, CategoryArithmetic},
+		{OpTOI, CategoryArithmetic},
+		{OpXOR, CategoryLogic},
+		{OpUSHR, CategoryLogic},
+		{OpLE, CategoryComparison},
+		{OpSTORED, CategoryMemory},
+		{OpMEMCLR, CategoryMemory},
+		{OpEXIT, CategoryControlFlow},
+		{OpJMPNZR, CategoryControlFlow},
+		{OpTRUNC, CategoryMath},
+		{OpTRAP, CategorySystem},
+		{OpPRINT, CategorySystem},
+		{OpFUEL, CategorySystem},
+		{OpCONCAT, CategoryString},
+		{Opcode(128), CategoryCustom},
+		{Opcode(255), CategoryCustom},
+		{OpDUPN, CategoryStack},
+		{OpSWAPN, CategoryStack},
+		{OpEMPTY, CategoryStack},
+		{Opcode(12), CategoryUnknown},
+		{Opcode(100), CategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.opcode.String(), func(t *testing.T) {
+			if got := tt.opcode.Category(); got != tt.want {
+				t.Errorf("Category() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
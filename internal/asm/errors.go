@@ -0,0 +1,26 @@
+package asm
+
+import "fmt"
+
+// Error is a lexing or parsing error at a known source position. The
+// message already includes the position.
+type Error struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorAt formats an error for the source position line:column.
+func errorAt(line, column int, format string, args ...any) error {
+	return &Error{Line: line, Column: column, Err: fmt.Errorf(format, args...)}
+}
@@ -17,6 +17,10 @@ const (
 	TokenLabel      // Label definition (ends with :)
 	TokenNumber     // Numeric literal
 	TokenComment    // Comment
+	TokenAddress    // Label address reference (@label)
+	TokenDirective  // Assembler directive (.name)
+	TokenOperator   // Expression operator or parenthesis
+	TokenText       // Rest of the line after a metadata directive
 )
 
 // Token represents a lexical token.
@@ -45,6 +49,14 @@ func (tt TokenType) String() string {
 		return "NUMBER"
 	case TokenComment:
 		return "COMMENT"
+	case TokenAddress:
+		return "ADDRESS"
+	case TokenDirective:
+		return "DIRECTIVE"
+	case TokenOperator:
+		return "OPERATOR"
+	case TokenText:
+		return "TEXT"
 	default:
 		return fmt.Sprintf("TokenType(%d)", tt)
 	}
@@ -113,18 +125,38 @@ func (l *Lexer) scanToken() error {
 		l.scanComment()
 		return nil
 	}
+	if ch == '/' && l.peekAt(1) == '*' {
+		return l.scanBlockComment()
+	}
 
 	// Numbers (including negative)
 	if unicode.IsDigit(rune(ch)) || (ch == '-' && l.pos+1 < len(l.source) && unicode.IsDigit(rune(l.source[l.pos+1]))) {
 		return l.scanNumber()
 	}
 
+	// Label address references
+	if ch == '@' {
+		return l.scanAddress()
+	}
+
+	// Directives
+	if ch == '.' {
+		return l.scanDirective()
+	}
+
+	// Expression operators, and $ for the current instruction's address
+	if strings.IndexByte("+-*/()$", ch) >= 0 {
+		l.emitToken(TokenOperator, string(ch))
+		l.advance()
+		return nil
+	}
+
 	// Identifiers and labels
 	if unicode.IsLetter(rune(ch)) || ch == '_' {
 		return l.scanIdentOrLabel()
 	}
 
-	return fmt.Errorf("unexpected character '%c' at %d:%d", ch, l.line, l.column)
+	return errorAt(l.line, l.column, "unexpected character '%c' at %d:%d", ch, l.line, l.column)
 }
 
 func (l *Lexer) scanComment() {
@@ -135,6 +167,68 @@ func (l *Lexer) scanComment() {
 	}
 }
 
+// scanBlockComment skips a /* ... */ comment. Newlines inside it still
+// end statements, as if each commented line were blank, so line numbers
+// stay accurate.
+func (l *Lexer) scanBlockComment() error {
+	startLine, startCol := l.line, l.column
+	l.advance()
+	l.advance()
+	for l.pos < len(l.source) {
+		switch {
+		case l.peek() == '*' && l.peekAt(1) == '/':
+			l.advance()
+			l.advance()
+			return nil
+		case l.peek() == '\n':
+			l.emitToken(TokenNewline, "\n")
+			l.advance()
+			l.line++
+			l.column = 1
+		default:
+			l.advance()
+		}
+	}
+	return errorAt(startLine, startCol, "unterminated block comment starting at %d:%d", startLine, startCol)
+}
+
+// IsLocalLabel reports whether name is a numeric local label such as "1".
+func IsLocalLabel(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLocalRef reports whether name refers to a local label, such as "1f"
+// (next "1:" forward) or "1b" (nearest "1:" backward).
+func IsLocalRef(name string) bool {
+	n := len(name)
+	return n > 1 && (name[n-1] == 'f' || name[n-1] == 'b') && IsLocalLabel(name[:n-1])
+}
+
+// IsFloatLiteral reports whether a number literal denotes a float: it
+// has a decimal point or an exponent.
+func IsFloatLiteral(literal string) bool {
+	return strings.ContainsAny(literal, ".eE")
+}
+
+// FormatFloat returns the shortest literal that the lexer reads back as
+// exactly f. Large and small magnitudes use exponent notation. f must be
+// finite.
+func FormatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !IsFloatLiteral(s) {
+		s += ".0"
+	}
+	return s
+}
+
 func (l *Lexer) scanNumber() error {
 	start := l.pos
 	startCol := l.column
@@ -149,18 +243,49 @@ func (l *Lexer) scanNumber() error {
 		l.advance()
 	}
 
+	// Local labels: "1:" defines one, "1f" and "1b" refer to the nearest
+	// definition forward or backward.
+	if digits := l.source[start:l.pos]; IsLocalLabel(digits) {
+		if l.peek() == ':' {
+			l.advance() // consume ':'
+			l.emitTokenAt(TokenLabel, digits, l.line, startCol)
+			return nil
+		}
+		if ch := l.peek(); (ch == 'f' || ch == 'b') && !isIdentChar(l.peekAt(1)) {
+			l.advance()
+			l.emitTokenAt(TokenIdent, l.source[start:l.pos], l.line, startCol)
+			return nil
+		}
+	}
+
+	// Optional exponent: e or E, an optional sign, then digits
+	if ch := l.peek(); ch == 'e' || ch == 'E' {
+		digits := l.pos + 1
+		if digits < len(l.source) && (l.source[digits] == '+' || l.source[digits] == '-') {
+			digits++
+		}
+		if digits < len(l.source) && unicode.IsDigit(rune(l.source[digits])) {
+			for l.pos < digits {
+				l.advance()
+			}
+			for l.pos < len(l.source) && unicode.IsDigit(rune(l.peek())) {
+				l.advance()
+			}
+		}
+	}
+
 	value := l.source[start:l.pos]
 
 	// Validate number
-	if strings.Contains(value, ".") {
+	if IsFloatLiteral(value) {
 		_, err := strconv.ParseFloat(value, 64)
 		if err != nil {
-			return fmt.Errorf("invalid float '%s' at %d:%d: %v", value, l.line, startCol, err)
+			return errorAt(l.line, startCol, "invalid float '%s' at %d:%d: %v", value, l.line, startCol, err)
 		}
 	} else {
 		_, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
-			return fmt.Errorf("invalid integer '%s' at %d:%d: %v", value, l.line, startCol, err)
+			return errorAt(l.line, startCol, "invalid integer '%s' at %d:%d: %v", value, l.line, startCol, err)
 		}
 	}
 
@@ -172,11 +297,14 @@ func (l *Lexer) scanIdentOrLabel() error {
 	start := l.pos
 	startCol := l.column
 
-	// Scan identifier characters
+	// Scan identifier characters. A dot followed by a letter or '_'
+	// continues a module-qualified name such as "math.square".
 	for l.pos < len(l.source) {
 		ch := l.peek()
 		if unicode.IsLetter(rune(ch)) || unicode.IsDigit(rune(ch)) || ch == '_' {
 			l.advance()
+		} else if next := l.peekAt(1); ch == '.' && (unicode.IsLetter(rune(next)) || next == '_') {
+			l.advance()
 		} else {
 			break
 		}
@@ -195,6 +323,77 @@ func (l *Lexer) scanIdentOrLabel() error {
 	return nil
 }
 
+func (l *Lexer) scanAddress() error {
+	startCol := l.column
+	l.advance() // consume '@'
+
+	start := l.pos
+	for l.pos < len(l.source) {
+		ch := l.peek()
+		if unicode.IsLetter(rune(ch)) || unicode.IsDigit(rune(ch)) || ch == '_' {
+			l.advance()
+		} else if next := l.peekAt(1); ch == '.' && l.pos > start && (unicode.IsLetter(rune(next)) || next == '_') {
+			l.advance()
+		} else {
+			break
+		}
+	}
+
+	if l.pos == start {
+		return errorAt(l.line, startCol, "expected label name after '@' at %d:%d", l.line, startCol)
+	}
+
+	l.emitTokenAt(TokenAddress, l.source[start:l.pos], l.line, startCol)
+	return nil
+}
+
+func (l *Lexer) scanDirective() error {
+	startCol := l.column
+	l.advance() // consume '.'
+
+	start := l.pos
+	for l.pos < len(l.source) && unicode.IsLetter(rune(l.peek())) {
+		l.advance()
+	}
+
+	if l.pos == start {
+		return errorAt(l.line, startCol, "expected directive name after '.' at %d:%d", l.line, startCol)
+	}
+
+	name := strings.ToLower(l.source[start:l.pos])
+	l.emitTokenAt(TokenDirective, name, l.line, startCol)
+	if isMetadataDirective(name) {
+		l.scanText()
+	}
+	return nil
+}
+
+// isMetadataDirective reports whether a directive (without the leading
+// '.') sets a program metadata field from the rest of its line.
+func isMetadataDirective(name string) bool {
+	switch name {
+	case "name", "version", "author", "description":
+		return true
+	}
+	return false
+}
+
+// scanText emits the rest of the line, trimmed of surrounding whitespace,
+// as a TokenText. Comment characters are part of the text.
+func (l *Lexer) scanText() {
+	for l.peek() == ' ' || l.peek() == '\t' {
+		l.advance()
+	}
+	start := l.pos
+	startCol := l.column
+	for l.pos < len(l.source) && l.peek() != '\n' {
+		l.advance()
+	}
+	if text := strings.TrimRight(l.source[start:l.pos], " \t\r"); text != "" {
+		l.emitTokenAt(TokenText, text, l.line, startCol)
+	}
+}
+
 func (l *Lexer) peek() byte {
 	if l.pos >= len(l.source) {
 		return 0
@@ -202,6 +401,18 @@ func (l *Lexer) peek() byte {
 	return l.source[l.pos]
 }
 
+// peekAt returns the byte n positions ahead, or 0 past the end.
+func (l *Lexer) peekAt(n int) byte {
+	if l.pos+n >= len(l.source) {
+		return 0
+	}
+	return l.source[l.pos+n]
+}
+
+func isIdentChar(ch byte) bool {
+	return unicode.IsLetter(rune(ch)) || unicode.IsDigit(rune(ch)) || ch == '_'
+}
+
 func (l *Lexer) advance() {
 	if l.pos < len(l.source) {
 		l.pos++
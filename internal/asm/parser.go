@@ -2,7 +2,9 @@ package asm
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
 // StatementType represents the type of a statement.
@@ -11,16 +13,24 @@ type StatementType int
 const (
 	StmtLabel StatementType = iota
 	StmtInstruction
+	StmtEntry  // .entry directive; Label names the entry point
+	StmtSub    // .sub directive; Label names the subroutine
+	StmtEndSub // .endsub directive
+	StmtMeta   // .name, .version, .author, or .description directive
+	StmtData   // .data directive; Label names the slot, Operand holds its value
+	StmtBss    // .bss directive; Label names the first slot, Operand holds the slot count
 )
 
 // Statement represents a parsed assembly statement.
 type Statement struct {
 	Type     StatementType
-	Label    string      // For StmtLabel
+	Label    string      // For StmtLabel, StmtEntry, and StmtSub; the directive name for StmtMeta
+	Text     string      // For StmtMeta
 	Opcode   string      // For StmtInstruction
-	Operand  *Operand    // For StmtInstruction (optional)
+	Operand  *Operand    // For StmtInstruction (optional), StmtData, and StmtBss
 	Line     int
 	Column   int
+	File     string      // Source file, for statements read by a ModuleLoader
 }
 
 // OperandType represents the type of an instruction operand.
@@ -29,21 +39,32 @@ type OperandType int
 const (
 	OperandNumber OperandType = iota
 	OperandLabel
+	OperandAddress // Address of a label (@label), resolved to an integer
+	OperandOffset  // PC-relative offset ($+n or $-n); Number holds the offset
 )
 
 // Operand represents an instruction operand.
 type Operand struct {
 	Type       OperandType
-	Number     int64   // For OperandNumber
+	Number     int64   // For OperandNumber and OperandOffset
 	FloatValue float64 // For OperandNumber (if float)
 	IsFloat    bool    // True if float, false if int
-	Label      string  // For OperandLabel
+	Label      string  // For OperandLabel and OperandAddress
 }
 
+// MaxReptStatements caps the statements a single .rept block may expand to,
+// so that a large count fails with an error instead of exhausting memory.
+const MaxReptStatements = 1 << 20
+
+// MaxDataSlots caps the data segment that .data and .bss may allocate.
+const MaxDataSlots = 1 << 20
+
 // Parser parses tokens into an AST.
 type Parser struct {
 	tokens  []Token
 	current int
+	defines map[string]int64 // constants from .define, .data, and .bss
+	data    int64            // next free .data or .bss address
 }
 
 // NewParser creates a new parser for the given tokens.
@@ -51,17 +72,52 @@ func NewParser(tokens []Token) *Parser {
 	return &Parser{
 		tokens:  tokens,
 		current: 0,
+		defines: make(map[string]int64),
 	}
 }
 
 // Parse converts tokens into a list of statements.
 func (p *Parser) Parse() ([]Statement, error) {
+	return p.parseBlock(nil)
+}
+
+// Defines returns the constants defined so far by .define, .data, and .bss,
+// mapped to their values. The map must not be modified.
+func (p *Parser) Defines() map[string]int64 {
+	return p.defines
+}
+
+// parseBlock parses statements until EOF, or until the .endr closing rept
+// when rept is non-nil.
+func (p *Parser) parseBlock(rept *Token) ([]Statement, error) {
 	statements := make([]Statement, 0)
 
 	// Skip initial newlines
 	p.skipNewlines()
 
 	for !p.isAtEnd() {
+		if token := p.peek(); token.Type == TokenDirective {
+			switch token.Value {
+			case "rept":
+				block, err := p.parseRept()
+				if err != nil {
+					return nil, err
+				}
+				statements = append(statements, block...)
+				p.skipNewlines()
+				continue
+			case "endr":
+				if rept == nil {
+					return nil, errorAt(token.Line, token.Column, ".endr without .rept at %d:%d", token.Line, token.Column)
+				}
+				p.advance()
+				if err := p.endDirective(); err != nil {
+					return nil, err
+				}
+				return statements, nil
+			}
+		}
+
 		stmt, err := p.parseStatement()
 		if err != nil {
 			return nil, err
@@ -75,6 +131,89 @@ func (p *Parser) Parse() ([]Statement, error) {
 		p.skipNewlines()
 	}
 
+	if rept != nil {
+		return nil, errorAt(rept.Line, rept.Column, ".rept at %d:%d is missing .endr", rept.Line, rept.Column)
+	}
+	return statements, nil
+}
+
+// parseRept parses a ".rept N ... .endr" block and returns its statements
+// repeated N times. Labels defined in the block get a "_<iteration>" suffix
+// (starting at 1), and references to them within the block are renamed to
+// match, so each copy has its own labels.
+func (p *Parser) parseRept() ([]Statement, error) {
+	token := p.advance()
+	count, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, errorAt(token.Line, token.Column, "negative .rept count %d at %d:%d", count, token.Line, token.Column)
+	}
+	if err := p.endDirective(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseBlock(&token)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, nil
+	}
+	if total := count * int64(len(body)); total > MaxReptStatements {
+		return nil, errorAt(token.Line, token.Column, ".rept at %d:%d expands to %d statements, more than the limit of %d",
+			token.Line, token.Column, total, MaxReptStatements)
+	}
+
+	// Directives that allocate or name something once cannot be copied:
+	// each copy would reuse the address or name the parser assigned.
+	for _, stmt := range body {
+		var name string
+		switch stmt.Type {
+		case StmtData:
+			name = ".data"
+		case StmtBss:
+			name = ".bss"
+		case StmtEntry:
+			name = ".entry"
+		case StmtSub:
+			name = ".sub"
+		case StmtEndSub:
+			name = ".endsub"
+		default:
+			continue
+		}
+		return nil, errorAt(stmt.Line, stmt.Column, "%s is not allowed inside .rept at %d:%d", name, stmt.Line, stmt.Column)
+	}
+
+	// Numeric local labels are resolved by position, so copies need no
+	// renaming.
+	local := make(map[string]bool)
+	for _, stmt := range body {
+		if stmt.Type == StmtLabel && !IsLocalLabel(stmt.Label) {
+			local[stmt.Label] = true
+		}
+	}
+
+	statements := make([]Statement, 0, int(count)*len(body))
+	for i := 1; i <= int(count); i++ {
+		rename := func(label string) string {
+			if local[label] {
+				return fmt.Sprintf("%s_%d", label, i)
+			}
+			return label
+		}
+		for _, stmt := range body {
+			stmt.Label = rename(stmt.Label)
+			if stmt.Operand != nil {
+				operand := *stmt.Operand
+				operand.Label = rename(operand.Label)
+				stmt.Operand = &operand
+			}
+			statements = append(statements, stmt)
+		}
+	}
 	return statements, nil
 }
 
@@ -86,13 +225,15 @@ func (p *Parser) parseStatement() (*Statement, error) {
 		return p.parseLabelDef()
 	case TokenIdent:
 		return p.parseInstruction()
+	case TokenDirective:
+		return p.parseDirective()
 	case TokenNewline:
 		p.advance()
 		return nil, nil
 	case TokenEOF:
 		return nil, nil
 	default:
-		return nil, fmt.Errorf("unexpected token %s at %d:%d", token.Type, token.Line, token.Column)
+		return nil, errorAt(token.Line, token.Column, "unexpected token %s at %d:%d", token.Type, token.Line, token.Column)
 	}
 }
 
@@ -147,9 +288,191 @@ func (p *Parser) parseInstruction() (*Statement, error) {
 	return stmt, nil
 }
 
+// parseDirective handles an assembler directive. .define records a named
+// constant for later operands and produces no statement; .data, .bss,
+// .entry, .sub, .endsub, and the metadata directives produce a statement of the
+// matching type. .rept blocks are handled by parseBlock.
+func (p *Parser) parseDirective() (*Statement, error) {
+	token := p.advance()
+	var stmt *Statement
+
+	switch token.Value {
+	case "define":
+		name := p.expect(TokenIdent)
+		if name == nil {
+			return nil, errorAt(token.Line, token.Column, "expected name after .define at %d:%d", token.Line, token.Column)
+		}
+		if _, exists := p.defines[name.Value]; exists {
+			return nil, errorAt(name.Line, name.Column, "constant '%s' redefined at %d:%d", name.Value, name.Line, name.Column)
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		p.defines[name.Value] = value
+	case "data":
+		// A data slot's name is a constant holding its memory address.
+		name := p.expect(TokenIdent)
+		if name == nil {
+			return nil, errorAt(token.Line, token.Column, "expected name after .data at %d:%d", token.Line, token.Column)
+		}
+		if _, exists := p.defines[name.Value]; exists {
+			return nil, errorAt(name.Line, name.Column, "constant '%s' redefined at %d:%d", name.Value, name.Line, name.Column)
+		}
+		if next := p.peek().Type; next == TokenNewline || next == TokenEOF {
+			return nil, errorAt(token.Line, token.Column, "expected a number after .data %s at %d:%d", name.Value, token.Line, token.Column)
+		}
+		value, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if value.Type != OperandNumber {
+			return nil, errorAt(token.Line, token.Column, "expected a number after .data %s at %d:%d", name.Value, token.Line, token.Column)
+		}
+		p.defines[name.Value] = p.data
+		p.data++
+		stmt = &Statement{
+			Type:    StmtData,
+			Label:   name.Value,
+			Operand: value,
+			Line:    token.Line,
+			Column:  token.Column,
+		}
+	case "bss":
+		// Like .data, but reserves count nil slots after the ones before it.
+		name := p.expect(TokenIdent)
+		if name == nil {
+			return nil, errorAt(token.Line, token.Column, "expected name after .bss at %d:%d", token.Line, token.Column)
+		}
+		if _, exists := p.defines[name.Value]; exists {
+			return nil, errorAt(name.Line, name.Column, "constant '%s' redefined at %d:%d", name.Value, name.Line, name.Column)
+		}
+		if next := p.peek().Type; next == TokenNewline || next == TokenEOF {
+			return nil, errorAt(token.Line, token.Column, "expected a count after .bss %s at %d:%d", name.Value, token.Line, token.Column)
+		}
+		count, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if count < 1 {
+			return nil, errorAt(token.Line, token.Column, ".bss %s count must be positive, got %d at %d:%d", name.Value, count, token.Line, token.Column)
+		}
+		if p.data+count > MaxDataSlots {
+			return nil, errorAt(token.Line, token.Column, ".bss %s at %d:%d makes the data segment %d slots, more than the limit of %d",
+				name.Value, token.Line, token.Column, p.data+count, MaxDataSlots)
+		}
+		p.defines[name.Value] = p.data
+		p.data += count
+		stmt = &Statement{
+			Type:    StmtBss,
+			Label:   name.Value,
+			Operand: &Operand{Type: OperandNumber, Number: count},
+			Line:    token.Line,
+			Column:  token.Column,
+		}
+	case "entry":
+		name := p.expect(TokenIdent)
+		if name == nil {
+			return nil, errorAt(token.Line, token.Column, "expected label after .entry at %d:%d", token.Line, token.Column)
+		}
+		stmt = &Statement{
+			Type:   StmtEntry,
+			Label:  name.Value,
+			Line:   token.Line,
+			Column: token.Column,
+		}
+	case "sub":
+		name := p.expect(TokenIdent)
+		if name == nil {
+			return nil, errorAt(token.Line, token.Column, "expected name after .sub at %d:%d", token.Line, token.Column)
+		}
+		stmt = &Statement{
+			Type:   StmtSub,
+			Label:  name.Value,
+			Line:   token.Line,
+			Column: token.Column,
+		}
+	case "endsub":
+		stmt = &Statement{
+			Type:   StmtEndSub,
+			Line:   token.Line,
+			Column: token.Column,
+		}
+	case "name", "version", "author", "description":
+		text := p.expect(TokenText)
+		if text == nil {
+			return nil, errorAt(token.Line, token.Column, "expected text after .%s at %d:%d", token.Value, token.Line, token.Column)
+		}
+		stmt = &Statement{
+			Type:   StmtMeta,
+			Label:  token.Value,
+			Text:   text.Value,
+			Line:   token.Line,
+			Column: token.Column,
+		}
+	default:
+		return nil, errorAt(token.Line, token.Column, "unknown directive '.%s' at %d:%d", token.Value, token.Line, token.Column)
+	}
+
+	if err := p.endDirective(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// endDirective consumes the newline ending a directive.
+func (p *Parser) endDirective() error {
+	if p.peek().Type == TokenNewline {
+		p.advance()
+	} else if !p.isAtEnd() {
+		next := p.peek()
+		return errorAt(next.Line, next.Column, "unexpected token %s at %d:%d", next.Type, next.Line, next.Column)
+	}
+	return nil
+}
+
 func (p *Parser) parseOperand() (*Operand, error) {
 	token := p.peek()
 
+	// $ is the instruction's own address; an optional expression gives
+	// the offset from it.
+	if token.Type == TokenOperator && token.Value == "$" {
+		p.advance()
+		offset := int64(0)
+		switch next := p.peek(); {
+		case next.Type == TokenNewline || next.Type == TokenEOF:
+		case next.Type == TokenOperator && next.Value == "+":
+			p.advance()
+			fallthrough
+		case next.Type == TokenOperator && next.Value == "-",
+			next.Type == TokenNumber && strings.HasPrefix(next.Value, "-"):
+			value, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			offset = value
+		default:
+			return nil, errorAt(next.Line, next.Column, "expected '+' or '-' after '$' at %d:%d", next.Line, next.Column)
+		}
+		return &Operand{
+			Type:   OperandOffset,
+			Number: offset,
+		}, nil
+	}
+
+	// Constant expressions and defined names evaluate to an integer.
+	_, defined := p.defines[token.Value]
+	if token.Type == TokenOperator || (token.Type == TokenIdent && defined) || p.operatorFollows() {
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &Operand{
+			Type:   OperandNumber,
+			Number: value,
+		}, nil
+	}
+
 	switch token.Type {
 	case TokenNumber:
 		p.advance()
@@ -164,7 +487,7 @@ func (p *Parser) parseOperand() (*Operand, error) {
 		// Parse as float
 		floatVal, err := strconv.ParseFloat(token.Value, 64)
 		if err != nil {
-			return nil, fmt.Errorf("invalid number '%s' at %d:%d: %v", token.Value, token.Line, token.Column, err)
+			return nil, errorAt(token.Line, token.Column, "invalid number '%s' at %d:%d: %v", token.Value, token.Line, token.Column, err)
 		}
 		return &Operand{
 			Type:       OperandNumber,
@@ -179,9 +502,142 @@ func (p *Parser) parseOperand() (*Operand, error) {
 			Label: token.Value,
 		}, nil
 
+	case TokenAddress:
+		p.advance()
+		return &Operand{
+			Type:  OperandAddress,
+			Label: token.Value,
+		}, nil
+
 	default:
-		return nil, fmt.Errorf("expected operand (number or label) at %d:%d, got %s", token.Line, token.Column, token.Type)
+		return nil, errorAt(token.Line, token.Column, "expected operand (number or label) at %d:%d, got %s", token.Line, token.Column, token.Type)
+	}
+}
+
+// operatorFollows reports whether the token after the current one continues
+// an expression. A negative number literal there is a subtraction.
+func (p *Parser) operatorFollows() bool {
+	if p.current+1 >= len(p.tokens) {
+		return false
+	}
+	next := p.tokens[p.current+1]
+	return next.Type == TokenOperator && next.Value != "(" ||
+		next.Type == TokenNumber && strings.HasPrefix(next.Value, "-")
+}
+
+// Constant expressions are evaluated at parse time over integers. The
+// grammar is:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { "*" factor }
+//	factor = number | name | "-" factor | "(" expr ")"
+//
+// Every intermediate result must fit in an int32 instruction operand.
+
+func (p *Parser) parseExpr() (int64, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		token := p.peek()
+		var right int64
+		switch {
+		case token.Type == TokenOperator && (token.Value == "+" || token.Value == "-"):
+			p.advance()
+			right, err = p.parseTerm()
+			if token.Value == "-" {
+				right = -right
+			}
+		case token.Type == TokenNumber && strings.HasPrefix(token.Value, "-"):
+			// The lexer reads "BASE-4" as BASE followed by -4.
+			right, err = p.parseTerm()
+		default:
+			return left, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if left, err = checkOperand(left+right, token); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (p *Parser) parseTerm() (int64, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		token := p.peek()
+		if token.Type != TokenOperator || (token.Value != "*" && token.Value != "/") {
+			return left, nil
+		}
+		if token.Value == "/" {
+			return 0, errorAt(token.Line, token.Column, "division is not supported in constant expressions at %d:%d", token.Line, token.Column)
+		}
+		p.advance()
+		right, err := p.parseFactor()
+		if err != nil {
+			return 0, err
+		}
+		if left, err = checkOperand(left*right, token); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (p *Parser) parseFactor() (int64, error) {
+	token := p.advance()
+
+	switch token.Type {
+	case TokenNumber:
+		value, err := strconv.ParseInt(token.Value, 10, 64)
+		if err != nil {
+			return 0, errorAt(token.Line, token.Column, "expected integer in constant expression at %d:%d, got '%s'", token.Line, token.Column, token.Value)
+		}
+		return checkOperand(value, token)
+
+	case TokenIdent:
+		value, exists := p.defines[token.Value]
+		if !exists {
+			return 0, errorAt(token.Line, token.Column, "undefined constant '%s' at %d:%d", token.Value, token.Line, token.Column)
+		}
+		return value, nil
+
+	case TokenOperator:
+		switch token.Value {
+		case "-":
+			value, err := p.parseFactor()
+			if err != nil {
+				return 0, err
+			}
+			return checkOperand(-value, token)
+		case "(":
+			value, err := p.parseExpr()
+			if err != nil {
+				return 0, err
+			}
+			if closing := p.peek(); closing.Type != TokenOperator || closing.Value != ")" {
+				return 0, errorAt(closing.Line, closing.Column, "expected ')' at %d:%d", closing.Line, closing.Column)
+			}
+			p.advance()
+			return value, nil
+		}
+	}
+
+	return 0, errorAt(token.Line, token.Column, "unexpected %s in constant expression at %d:%d", token.Type, token.Line, token.Column)
+}
+
+// checkOperand returns an overflow error if v does not fit in an operand.
+func checkOperand(v int64, at Token) (int64, error) {
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return 0, errorAt(at.Line, at.Column, "constant expression overflows operand range at %d:%d", at.Line, at.Column)
 	}
+	return v, nil
 }
 
 func (p *Parser) peek() Token {
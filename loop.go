@@ -0,0 +1,93 @@
+package stackvm
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+)
+
+// loopHistorySize bounds the number of recent states remembered by
+// ExecuteOptions.DetectLoops.
+const loopHistorySize = 4096
+
+// loopDetector remembers hashes of recent VM states for
+// ExecuteOptions.DetectLoops.
+type loopDetector struct {
+	seen    map[uint64]struct{}
+	history []uint64         // ring buffer of the hashes in seen
+	next    int              // oldest entry once history is full
+	addrs   map[int]struct{} // memory addresses accessed so far
+	sorted  []int            // scratch for stateHash
+}
+
+func newLoopDetector() *loopDetector {
+	return &loopDetector{
+		seen:  make(map[uint64]struct{}),
+		addrs: make(map[int]struct{}),
+	}
+}
+
+// visit records a state hash and reports whether it was already in the
+// history. The oldest hash is forgotten once the history is full.
+func (d *loopDetector) visit(h uint64) bool {
+	if _, ok := d.seen[h]; ok {
+		return true
+	}
+	if len(d.history) < loopHistorySize {
+		d.history = append(d.history, h)
+	} else {
+		delete(d.seen, d.history[d.next])
+		d.history[d.next] = h
+		d.next = (d.next + 1) % loopHistorySize
+	}
+	d.seen[h] = struct{}{}
+	return false
+}
+
+// reset forgets every recorded state and address, keeping the storage for
+// the next run.
+func (d *loopDetector) reset() {
+	clear(d.seen)
+	d.history = d.history[:0]
+	d.next = 0
+	clear(d.addrs)
+}
+
+// stateHash hashes the PC, the call stack, the data stack, the registers, and the memory slots the
+// program has accessed through LOAD, STORE, LOADD, STORED, and MEMCLR.
+func (e *executor) stateHash(memory Memory) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	write := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+
+	write(uint64(e.pc))
+	write(uint64(len(e.calls)))
+	for _, addr := range e.calls {
+		write(uint64(addr))
+	}
+	depth := e.stack.Depth()
+	write(uint64(depth))
+	for i := depth - 1; i >= 0; i-- {
+		v, _ := e.stack.Peek(i)
+		write(v.Hash())
+	}
+	for _, v := range e.registers {
+		write(v.Hash())
+	}
+	d := e.loops
+	d.sorted = d.sorted[:0]
+	for addr := range d.addrs {
+		d.sorted = append(d.sorted, addr)
+	}
+	sort.Ints(d.sorted)
+	for _, addr := range d.sorted {
+		if val, err := memory.Load(addr); err == nil {
+			write(uint64(addr))
+			write(val.Hash())
+		}
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,91 @@
+package stackvm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetectLoops(t *testing.T) {
+	t.Run("jump to self", func(t *testing.T) {
+		program := MustAssemble("LOOP: JMP LOOP")
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+			DetectLoops:     true,
+			MaxInstructions: 1000,
+		})
+		if !errors.Is(err, ErrInfiniteLoop) {
+			t.Fatalf("Execute() error = %v, want ErrInfiniteLoop", err)
+		}
+		if result.InstructionCount != 1 {
+			t.Errorf("InstructionCount = %d, want 1", result.InstructionCount)
+		}
+	})
+
+	t.Run("memory unchanged", func(t *testing.T) {
+		program := MustAssemble(`
+			LOOP:
+				LOAD 0
+				STORE 0
+				JMP LOOP
+		`)
+		_, err := New().Execute(program, NewSimpleMemory(1), ExecuteOptions{
+			DetectLoops:     true,
+			MaxInstructions: 1000,
+		})
+		if !errors.Is(err, ErrInfiniteLoop) {
+			t.Fatalf("Execute() error = %v, want ErrInfiniteLoop", err)
+		}
+	})
+
+	t.Run("counter makes progress", func(t *testing.T) {
+		// The state changes on every iteration, so only the instruction
+		// limit stops it.
+		program := MustAssemble(`
+				PUSHI 0
+				STORE 0
+			LOOP:
+				LOAD 0
+				INC
+				STORE 0
+				JMP LOOP
+		`)
+		_, err := New().Execute(program, NewSimpleMemory(1), ExecuteOptions{
+			DetectLoops:     true,
+			MaxInstructions: 1000,
+		})
+		if !errors.Is(err, ErrInstructionLimit) {
+			t.Fatalf("Execute() error = %v, want ErrInstructionLimit", err)
+		}
+	})
+
+	t.Run("terminating loop", func(t *testing.T) {
+		program := MustAssemble(`
+				PUSHI 5
+			LOOP:
+				DEC
+				DUP
+				JMPNZ LOOP
+				HALT
+		`)
+		if _, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{DetectLoops: true}); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	})
+}
+
+func TestLoopDetectorHistoryBound(t *testing.T) {
+	d := newLoopDetector()
+	for h := uint64(0); h < loopHistorySize+10; h++ {
+		if d.visit(h) {
+			t.Fatalf("visit(%d) reported a repeat", h)
+		}
+	}
+	if len(d.seen) != loopHistorySize {
+		t.Errorf("len(seen) = %d, want %d", len(d.seen), loopHistorySize)
+	}
+	if d.visit(0) {
+		t.Error("visit(0) should have been forgotten")
+	}
+	if !d.visit(loopHistorySize + 9) {
+		t.Error("visit of a recent hash should report a repeat")
+	}
+}
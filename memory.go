@@ -1,10 +1,14 @@
 package stackvm
 
+import "sync"
+
 // Memory provides an abstraction for VM storage.
 // Host systems can implement this interface to provide custom memory backends.
 type Memory interface {
 	// Load retrieves the value at the specified index.
 	// Returns ErrInvalidMemoryAddress if the index is out of bounds.
+	// Memory that streams input may return ErrEndOfInput once the input
+	// is exhausted, which stops the program with that error.
 	Load(index int) (Value, error)
 
 	// Store saves the value at the specified index.
@@ -23,8 +27,17 @@ type ReadOnlyMemory interface {
 	IsReadOnly() bool
 }
 
+// CloneableMemory extends Memory with the ability to produce an independent copy.
+type CloneableMemory interface {
+	Memory
+	// Clone returns a copy of the memory that shares no state with the original.
+	Clone() Memory
+}
+
 // SimpleMemory is a basic memory implementation using a slice.
 // It provides fixed-size, writable memory suitable for testing and simple use cases.
+// SimpleMemory is not synchronized; wrap it in a ConcurrentMemory to share
+// it between concurrent executions.
 type SimpleMemory struct {
 	data []Value
 }
@@ -84,9 +97,99 @@ func (m *SimpleMemory) SetValues(values []Value) {
 	copy(m.data, values)
 }
 
+// Clone returns an independent copy of the memory.
+func (m *SimpleMemory) Clone() Memory {
+	data := make([]Value, len(m.data))
+	copy(data, m.data)
+	return &SimpleMemory{data: data}
+}
+
 // Reset clears all memory values back to NilValue().
 func (m *SimpleMemory) Reset() {
 	for i := range m.data {
 		m.data[i] = NilValue()
 	}
 }
+
+// ConcurrentMemory makes a Memory safe for concurrent use by guarding it
+// with a read-write lock. Loads from different goroutines run in parallel;
+// a Store waits for them and blocks other access while it runs.
+//
+// Each Load and Store is atomic, but a sequence of them is not: two
+// programs incrementing the same address can still lose updates.
+type ConcurrentMemory struct {
+	mu  sync.RWMutex
+	mem Memory
+}
+
+// NewConcurrentMemory wraps mem. After wrapping, mem should only be
+// accessed through the returned ConcurrentMemory.
+func NewConcurrentMemory(mem Memory) *ConcurrentMemory {
+	return &ConcurrentMemory{mem: mem}
+}
+
+// Load retrieves the value at the specified index under a read lock.
+func (m *ConcurrentMemory) Load(index int) (Value, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mem.Load(index)
+}
+
+// Store saves the value at the specified index under a write lock.
+func (m *ConcurrentMemory) Store(index int, value Value) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mem.Store(index, value)
+}
+
+// Size returns the number of addressable memory locations.
+func (m *ConcurrentMemory) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mem.Size()
+}
+
+// ChannelMemory streams input from a Go channel through one memory
+// address. Each Load of the input address receives the next value from
+// the channel, blocking until one is available; once the channel is closed
+// and drained, Load returns ErrEndOfInput. Every other address is served
+// by the wrapped memory.
+type ChannelMemory struct {
+	mem   Memory
+	addr  int
+	input <-chan Value
+}
+
+// NewChannelMemory wraps mem so that loads from addr read from input.
+// Stores to addr fail with ErrReadOnlyMemory.
+func NewChannelMemory(mem Memory, addr int, input <-chan Value) *ChannelMemory {
+	return &ChannelMemory{mem: mem, addr: addr, input: input}
+}
+
+// Load receives the next input value if index is the input address, and
+// otherwise loads from the wrapped memory.
+func (m *ChannelMemory) Load(index int) (Value, error) {
+	if index != m.addr {
+		return m.mem.Load(index)
+	}
+	v, ok := <-m.input
+	if !ok {
+		return NilValue(), ErrEndOfInput
+	}
+	return v, nil
+}
+
+// Store saves the value at the specified index in the wrapped memory.
+// Returns ErrReadOnlyMemory for the input address.
+func (m *ChannelMemory) Store(index int, value Value) error {
+	if index == m.addr {
+		return ErrReadOnlyMemory
+	}
+	return m.mem.Store(index, value)
+}
+
+// Size returns the number of addressable memory locations of the wrapped
+// memory.
+func (m *ChannelMemory) Size() int {
+	return m.mem.Size()
+}
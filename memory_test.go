@@ -1,6 +1,10 @@
 package stackvm
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -340,3 +344,412 @@ func TestMemoryInterface(t *testing.T) {
 		t.Errorf("Load() through interface = %v, want FloatValue(3.14)", val)
 	}
 }
+
+// mapMemory is a permissive Memory that accepts any index, including negative ones.
+type mapMemory struct {
+	data map[int]Value
+}
+
+func (m *mapMemory) Load(index int) (Value, error) {
+	return m.data[index], nil
+}
+
+func (m *mapMemory) Store(index int, value Value) error {
+	m.data[index] = value
+	return nil
+}
+
+func (m *mapMemory) Size() int {
+	return len(m.data)
+}
+
+func TestNegativeAddress(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions []Instruction
+	}{
+		{
+			name: "LOAD",
+			instructions: []Instruction{
+				NewInstruction(OpLOAD, -1),
+				NewInstruction(OpHALT, 0),
+			},
+		},
+		{
+			name: "STORE",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, 7),
+				NewInstruction(OpSTORE, -1),
+				NewInstruction(OpHALT, 0),
+			},
+		},
+		{
+			name: "LOADD",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, -1),
+				NewInstruction(OpLOADD, 0),
+				NewInstruction(OpHALT, 0),
+			},
+		},
+		{
+			name: "STORED",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, -1),
+				NewInstruction(OpPUSHI, 7),
+				NewInstruction(OpSTORED, 0),
+				NewInstruction(OpHALT, 0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := &mapMemory{data: make(map[int]Value)}
+			_, err := New().Execute(NewProgram(tt.instructions), memory, ExecuteOptions{})
+			if !errors.Is(err, ErrInvalidMemoryAddress) {
+				t.Errorf("Execute() error = %v, want ErrInvalidMemoryAddress", err)
+			}
+			if _, exists := memory.data[-1]; exists {
+				t.Error("Memory backend should not be called with a negative address")
+			}
+		})
+	}
+}
+
+func TestSimpleMemoryClone(t *testing.T) {
+	mem := NewSimpleMemory(2)
+	mem.Store(0, IntValue(1))
+
+	clone := mem.Clone()
+	clone.Store(0, IntValue(2))
+
+	if val, _ := mem.Load(0); !val.Equal(IntValue(1)) {
+		t.Errorf("original memory[0] = %v, want 1", val)
+	}
+	if val, _ := clone.Load(0); !val.Equal(IntValue(2)) {
+		t.Errorf("clone memory[0] = %v, want 2", val)
+	}
+	if clone.Size() != 2 {
+		t.Errorf("clone Size() = %d, want 2", clone.Size())
+	}
+}
+
+func TestConcurrentMemory(t *testing.T) {
+	const workers = 8
+	mem := NewConcurrentMemory(NewSimpleMemory(workers))
+	pool := NewDefaultVMPool()
+
+	// Each worker repeatedly writes its own slot and reads every slot,
+	// both from a program and directly.
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		program := MustAssemble(fmt.Sprintf(`
+			PUSHI 100
+		loop:
+			PUSHI %d
+			STORE %d
+			LOAD 0
+			POP
+			LOAD %d
+			POP
+			DEC
+			DUP
+			JMPNZ loop
+			HALT
+		`, w+1, w, workers-1))
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if _, err := pool.Execute(program, mem, ExecuteOptions{}); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := mem.Load((w + 1) % workers); err != nil {
+					errs <- err
+					return
+				}
+				if err := mem.Store(w, IntValue(int64(w+1))); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent access failed: %v", err)
+	}
+
+	if mem.Size() != workers {
+		t.Errorf("Size() = %d, want %d", mem.Size(), workers)
+	}
+	for w := 0; w < workers; w++ {
+		if val, _ := mem.Load(w); !val.Equal(IntValue(int64(w + 1))) {
+			t.Errorf("memory[%d] = %v, want %d", w, val, w+1)
+		}
+	}
+	if err := mem.Store(workers, IntValue(1)); !errors.Is(err, ErrInvalidMemoryAddress) {
+		t.Errorf("Store() out of range = %v, want ErrInvalidMemoryAddress", err)
+	}
+}
+
+func TestChannelMemory(t *testing.T) {
+	// Sum the input stream into address 1 until it runs out.
+	program := MustAssemble(`
+	loop:
+		LOAD 0
+		LOAD 1
+		ADD
+		STORE 1
+		JMP loop
+	`)
+
+	input := make(chan Value)
+	go func() {
+		for i := int64(1); i <= 4; i++ {
+			input <- IntValue(i)
+		}
+		close(input)
+	}()
+	backing := NewSimpleMemory(2)
+	backing.Store(1, IntValue(0))
+	memory := NewChannelMemory(backing, 0, input)
+
+	result, err := New().Execute(program, memory, ExecuteOptions{})
+	if !errors.Is(err, ErrEndOfInput) {
+		t.Fatalf("Execute() error = %v, want ErrEndOfInput", err)
+	}
+	if errors.Is(err, ErrInvalidMemoryAddress) {
+		t.Errorf("Execute() error = %v, should not be ErrInvalidMemoryAddress", err)
+	}
+	var vmErr *VMError
+	if !errors.As(err, &vmErr) || vmErr.PC != 0 || vmErr.Opcode != OpLOAD {
+		t.Errorf("Execute() error = %v, want a VMError at the LOAD", err)
+	}
+	if result.InstructionCount != 21 {
+		t.Errorf("InstructionCount = %d, want 21", result.InstructionCount)
+	}
+	if sum, _ := backing.Load(1); !sum.Equal(FloatValue(10)) {
+		t.Errorf("sum = %v, want 10", sum)
+	}
+
+	if err := memory.Store(0, IntValue(1)); !errors.Is(err, ErrReadOnlyMemory) {
+		t.Errorf("Store() to input address error = %v, want ErrReadOnlyMemory", err)
+	}
+	if memory.Size() != 2 {
+		t.Errorf("Size() = %d, want 2", memory.Size())
+	}
+}
+
+func TestMemClr(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   int
+		length  int64
+		window  *MemoryWindow
+		wantErr error
+		cleared []int
+	}{
+		{"range", 2, 3, nil, nil, []int{2, 3, 4}},
+		{"to end", 5, 3, nil, nil, []int{5, 6, 7}},
+		{"empty", 3, 0, nil, nil, nil},
+		{"past end", 6, 3, nil, ErrInvalidMemoryAddress, nil},
+		{"negative start", -1, 2, nil, ErrInvalidMemoryAddress, nil},
+		{"negative length", 2, -1, nil, ErrInvalidOperand, nil},
+		{"outside window", 2, 3, &MemoryWindow{Min: 0, Max: 4}, ErrInvalidMemoryAddress, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewSimpleMemory(8)
+			for addr := 0; addr < memory.Size(); addr++ {
+				memory.Store(addr, IntValue(int64(addr)))
+			}
+			program := mustBuild(t, NewProgramBuilder().PushInt(tt.length).MemClr(tt.start).Halt())
+
+			_, err := New().Execute(program, memory, ExecuteOptions{MemoryWindow: tt.window})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			cleared := make(map[int]bool)
+			for _, addr := range tt.cleared {
+				cleared[addr] = true
+			}
+			for addr := 0; addr < memory.Size(); addr++ {
+				val, _ := memory.Load(addr)
+				if cleared[addr] != val.IsNil() {
+					t.Errorf("memory[%d] = %v, cleared = %v", addr, val, cleared[addr])
+				}
+			}
+		})
+	}
+}
+
+func TestRegisters(t *testing.T) {
+	// Sum 5..1 using registers for the counter and the total.
+	program := MustAssemble(`
+		PUSHI 5
+		RSTORE 0
+		PUSHI 0
+		RSTORE 1
+	loop:
+		RLOAD 1
+		RLOAD 0
+		ADD
+		RSTORE 1
+		RLOAD 0
+		DEC
+		DUP
+		RSTORE 0
+		JMPNZ loop
+		RLOAD 1
+		HALT
+	`)
+	vm := New()
+	result, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{DetectLoops: true})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result.Stack) != 1 || !result.Stack[0].Equal(FloatValue(15)) {
+		t.Errorf("stack = %v, want [15]", result.Stack)
+	}
+
+	// Registers do not carry over to the next run.
+	result, err = vm.Execute(MustAssemble("RLOAD 1\nHALT"), NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Stack[0].IsNil() {
+		t.Errorf("register 1 = %v after a new run, want nil", result.Stack[0])
+	}
+
+	vm = NewWithConfig(Config{NumRegisters: 2})
+	for _, source := range []string{"RLOAD 2", "PUSHI 1\nRSTORE 2"} {
+		if _, err := vm.Execute(MustAssemble(source), NewSimpleMemory(0), ExecuteOptions{}); !errors.Is(err, ErrInvalidOperand) {
+			t.Errorf("%q: Execute() error = %v, want ErrInvalidOperand", source, err)
+		}
+	}
+	program = mustBuild(t, NewProgramBuilder().PushInt(7).RStore(1).RLoad(1).Halt())
+	if result, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{}); err != nil || !result.Stack[0].Equal(IntValue(7)) {
+		t.Errorf("Execute() = %v, %v; want [7]", result.Stack, err)
+	}
+}
+
+func TestMemTrace(t *testing.T) {
+	// Reads memory[5] and memory[0] (twice), writes memory[1].
+	program := NewProgram([]Instruction{
+		NewInstruction(OpLOAD, 5),
+		NewInstruction(OpPUSHI, 0),
+		NewInstruction(OpLOADD, 0),
+		NewInstruction(OpLOAD, 0),
+		NewInstruction(OpPOP, 0),
+		NewInstruction(OpADD, 0),
+		NewInstruction(OpSTORE, 1),
+		NewInstruction(OpHALT, 0),
+	})
+	memory := NewSimpleMemory(8)
+	memory.Store(0, IntValue(1))
+	memory.Store(5, IntValue(2))
+
+	result, err := New().Execute(program, memory, ExecuteOptions{MemTrace: true})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(result.ReadAddrs, []int{0, 5}) {
+		t.Errorf("ReadAddrs = %v, want [0 5]", result.ReadAddrs)
+	}
+	if !reflect.DeepEqual(result.WriteAddrs, []int{1}) {
+		t.Errorf("WriteAddrs = %v, want [1]", result.WriteAddrs)
+	}
+
+	result, err = New().Execute(program, memory, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.ReadAddrs != nil || result.WriteAddrs != nil {
+		t.Error("Address sets should be nil when MemTrace is off")
+	}
+}
+
+func TestMemoryWindow(t *testing.T) {
+	window := &MemoryWindow{Min: 4, Max: 8}
+
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		wantErr      error
+	}{
+		{
+			name: "static in window",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, 1),
+				NewInstruction(OpSTORE, 4),
+				NewInstruction(OpLOAD, 7),
+			},
+		},
+		{
+			name: "static load below window",
+			instructions: []Instruction{
+				NewInstruction(OpLOAD, 3),
+			},
+			wantErr: ErrInvalidMemoryAddress,
+		},
+		{
+			name: "static store at max",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, 1),
+				NewInstruction(OpSTORE, 8),
+			},
+			wantErr: ErrInvalidMemoryAddress,
+		},
+		{
+			name: "dynamic in window",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, 5),
+				NewInstruction(OpPUSHI, 1),
+				NewInstruction(OpSTORED, 0),
+				NewInstruction(OpPUSHI, 5),
+				NewInstruction(OpLOADD, 0),
+			},
+		},
+		{
+			name: "dynamic load outside window",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, 0),
+				NewInstruction(OpLOADD, 0),
+			},
+			wantErr: ErrInvalidMemoryAddress,
+		},
+		{
+			name: "dynamic store outside window",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, 12),
+				NewInstruction(OpPUSHI, 1),
+				NewInstruction(OpSTORED, 0),
+			},
+			wantErr: ErrInvalidMemoryAddress,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The backing memory is larger than the window.
+			memory := NewSimpleMemory(16)
+			_, err := New().Execute(NewProgram(tt.instructions), memory, ExecuteOptions{MemoryWindow: window})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				for addr := 0; addr < memory.Size(); addr++ {
+					if val, _ := memory.Load(addr); !val.IsNil() {
+						t.Errorf("memory[%d] = %v, want nil", addr, val)
+					}
+				}
+			}
+		})
+	}
+}
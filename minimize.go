@@ -0,0 +1,96 @@
+package stackvm
+
+import "errors"
+
+// errorKinds are the errors Minimize distinguishes failures by. Errors
+// that match none of them are compared by the message of the innermost
+// wrapped error.
+var errorKinds = []error{
+	ErrStackOverflow, ErrStackUnderflow, ErrInvalidMemoryAddress, ErrReadOnlyMemory,
+	ErrInvalidInstruction, ErrInvalidOpcode, ErrInstructionLimit, ErrDivisionByZero,
+	ErrTypeMismatch, ErrTimeout, ErrInvalidOperand, ErrInvalidProgram, ErrUnknownTrap,
+	ErrUnexpectedType, ErrHandlerPanic, ErrMemoryLimit, ErrInfiniteLoop,
+	ErrCallStackOverflow, ErrCallStackUnderflow, ErrEndOfInput, ErrStackImbalance,
+}
+
+// Minimize shrinks a failing program to a smaller one that fails with
+// the same kind of error, such as ErrDivisionByZero, for use in bug
+// reports. It repeatedly removes runs of instructions, halving the run
+// length down to single instructions, and keeps each removal after which
+// the program still fails the same way. Jump and call targets, the entry
+// point, and the symbol table are updated as instructions are removed;
+// a jump to a removed instruction goes to the one after it.
+//
+// Each attempt runs on a VM with the default configuration. If memory is
+// a CloneableMemory, every attempt gets a fresh clone; otherwise memory is
+// shared by all attempts, which can change the outcome of later ones.
+// Unless opts sets an instruction limit or timeout, attempts are limited
+// to a few times the instructions the original program ran, so a removal
+// that creates an infinite loop is rejected rather than hanging.
+//
+// The program must pass ValidateProgram. Minimize returns an error if the
+// program does not fail.
+func Minimize(program Program, memory Memory, opts ExecuteOptions) (Program, error) {
+	if err := ValidateProgram(program); err != nil {
+		return nil, err
+	}
+
+	run := func(p Program) (*Result, error) {
+		mem := memory
+		if cm, ok := memory.(CloneableMemory); ok {
+			mem = cm.Clone()
+		}
+		return New().Execute(p, mem, opts)
+	}
+
+	result, err := run(program)
+	if err == nil {
+		return nil, errors.New("program runs without error; nothing to minimize")
+	}
+	if opts.MaxInstructions == 0 && opts.Timeout == 0 && result != nil {
+		opts.MaxInstructions = 4*result.InstructionCount + 1024
+	}
+	kind := errorKind(err)
+
+	for size := program.Len() / 2; size >= 1; size /= 2 {
+		for start := 0; start < program.Len(); {
+			end := min(start+size, program.Len())
+			o := newOptimizer(program, Config{})
+			o.live = make([]bool, len(o.insts))
+			for i := range o.live {
+				o.live[i] = i < start || i >= end
+			}
+			o.compact()
+			candidate := o.program(program)
+
+			if _, err := run(candidate); err != nil && sameErrorKind(errorKind(err), kind) {
+				program = candidate
+			} else {
+				start = end
+			}
+		}
+	}
+	return program, nil
+}
+
+// errorKind returns the entry of errorKinds that err matches, or the
+// innermost error err wraps.
+func errorKind(err error) error {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return err
+		}
+		err = inner
+	}
+}
+
+// sameErrorKind reports whether two results of errorKind are the same.
+func sameErrorKind(a, b error) bool {
+	return errors.Is(a, b) || a.Error() == b.Error()
+}
@@ -0,0 +1,74 @@
+package stackvm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	program := MustAssemble(`
+		NOP
+		PUSHI 1
+		NOP
+		STORE 0
+		NOP
+	loop:
+		NOP
+		LOAD 0
+		PUSHI 0
+		NOP
+		DIV
+		NOP
+		JMP loop
+		NOP
+		HALT
+	`)
+	memory := NewSimpleMemory(1)
+
+	minimized, err := Minimize(program, memory, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Minimize() failed: %v", err)
+	}
+	for _, inst := range minimized.Instructions() {
+		if inst.Opcode == OpNOP {
+			t.Errorf("minimized program still has a NOP: %v", minimized.Instructions())
+			break
+		}
+	}
+	// Only the two pushes and the DIV are needed to divide by zero.
+	if minimized.Len() != 3 {
+		t.Errorf("minimized program = %v, want 3 instructions", minimized.Instructions())
+	}
+	if _, err := New().Execute(minimized, NewSimpleMemory(1), ExecuteOptions{}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("minimized program error = %v, want ErrDivisionByZero", err)
+	}
+	if val, _ := memory.Load(0); !val.IsNil() {
+		t.Errorf("memory[0] = %v, want Minimize to run on clones", val)
+	}
+
+	// Removing the DEC makes the loop infinite; that attempt must give up
+	// rather than hang.
+	looping := MustAssemble(`
+		PUSHI 3
+		STORE 0
+	loop:
+		LOAD 0
+		DEC
+		DUP
+		STORE 0
+		JMPNZ loop
+		PUSHI 0
+		LOAD 1
+	`)
+	minimized, err = Minimize(looping, NewSimpleMemory(1), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Minimize() failed: %v", err)
+	}
+	if _, err := New().Execute(minimized, NewSimpleMemory(1), ExecuteOptions{}); !errors.Is(err, ErrInvalidMemoryAddress) {
+		t.Errorf("minimized program error = %v, want ErrInvalidMemoryAddress", err)
+	}
+
+	if _, err := Minimize(MustAssemble("PUSHI 1\nHALT"), memory, ExecuteOptions{}); err == nil {
+		t.Error("Minimize() should fail for a program that does not fail")
+	}
+}
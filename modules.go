@@ -0,0 +1,181 @@
+package stackvm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmuston/stackvm/internal/asm"
+)
+
+// ModuleLoader assembles programs from a directory of source modules.
+// Each file name.asm in the directory is a module called name. Code refers
+// to a label in another module by qualifying it with the module name, as
+// in CALL math.square. Unqualified labels inside a module are private to
+// it, so modules may reuse label names.
+//
+// Modules other than the main one may not use .entry, .data, or .bss, and
+// their metadata directives are ignored. Other modules cannot refer to
+// labels in the main module.
+type ModuleLoader struct {
+	dir       string
+	assembler *assembler
+}
+
+// NewModuleLoader creates a loader for the modules in dir, assembled with
+// the given options.
+func NewModuleLoader(dir string, opts AssemblerOptions) *ModuleLoader {
+	return &ModuleLoader{
+		dir:       dir,
+		assembler: NewAssemblerWithOptions(opts).(*assembler),
+	}
+}
+
+// Link assembles the module main together with every module it refers
+// to, directly or through other modules, into one program. The main
+// module's code comes first and runs from its start (or its .entry
+// label). The other modules follow in the order they are first referred
+// to, behind a jump that keeps the main module from running into them.
+// Labels from other modules appear in the symbol table qualified with
+// their module name.
+func (l *ModuleLoader) Link(main string) (Program, error) {
+	statements, err := l.parseModule(main)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := map[string]bool{main: true}
+	queue := moduleRefs(statements)
+	var linked []asm.Statement
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if loaded[name] {
+			continue
+		}
+		loaded[name] = true
+
+		module, err := l.parseModule(name)
+		if err != nil {
+			return nil, err
+		}
+		module, err = qualifyModule(module, name)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		queue = append(queue, moduleRefs(module)...)
+		linked = append(linked, module...)
+	}
+
+	if len(linked) > 0 {
+		end := generatedLabelPrefix + "modules_end"
+		statements = append(statements, asm.Statement{
+			Type:    asm.StmtInstruction,
+			Opcode:  "JMP",
+			Operand: &asm.Operand{Type: asm.OperandLabel, Label: end},
+		})
+		statements = append(statements, linked...)
+		statements = append(statements, asm.Statement{Type: asm.StmtLabel, Label: end})
+	}
+
+	out, err := l.assembler.generate(statements)
+	if err != nil {
+		return nil, fmt.Errorf("linking %s: %w", main, l.assembler.wrapError(err, ""))
+	}
+	return out.program, nil
+}
+
+// parseModule reads and parses the source of the named module.
+func (l *ModuleLoader) parseModule(name string) ([]asm.Statement, error) {
+	path := l.path(name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &AssemblerError{
+			Message: fmt.Sprintf("failed to read module %s: %v", name, err),
+			File:    path,
+			Err:     err,
+		}
+	}
+
+	tokens, err := asm.NewLexer(string(data)).Tokenize()
+	if err != nil {
+		return nil, l.moduleError(name, err)
+	}
+	statements, err := asm.NewParser(tokens).Parse()
+	if err != nil {
+		return nil, l.moduleError(name, err)
+	}
+	for i := range statements {
+		statements[i].File = path
+	}
+	return statements, nil
+}
+
+// path returns the source file of the named module.
+func (l *ModuleLoader) path(name string) string {
+	return filepath.Join(l.dir, name+".asm")
+}
+
+// moduleError reports a lexing or parsing error in the named module.
+func (l *ModuleLoader) moduleError(name string, err error) error {
+	asmErr := l.assembler.wrapError(err, "").(*AssemblerError)
+	asmErr.File = l.path(name)
+	return fmt.Errorf("module %s (%s): %w", name, asmErr.File, asmErr)
+}
+
+// qualifyModule prefixes the module's own labels, and its references to
+// them, with "name.". Qualified references to other modules, local labels,
+// and generated labels are left alone.
+func qualifyModule(statements []asm.Statement, name string) ([]asm.Statement, error) {
+	qualify := func(label string) string {
+		if strings.Contains(label, ".") || asm.IsLocalLabel(label) || asm.IsLocalRef(label) ||
+			strings.HasPrefix(label, generatedLabelPrefix) {
+			return label
+		}
+		return name + "." + label
+	}
+
+	out := make([]asm.Statement, 0, len(statements))
+	for _, stmt := range statements {
+		switch stmt.Type {
+		case asm.StmtEntry:
+			return nil, stmtError(stmt, ".entry is only allowed in the main module")
+		case asm.StmtData:
+			return nil, stmtError(stmt, ".data is only allowed in the main module")
+		case asm.StmtBss:
+			return nil, stmtError(stmt, ".bss is only allowed in the main module")
+		case asm.StmtMeta:
+			continue
+		case asm.StmtLabel, asm.StmtSub:
+			stmt.Label = qualify(stmt.Label)
+		case asm.StmtInstruction:
+			if op := stmt.Operand; op != nil && (op.Type == asm.OperandLabel || op.Type == asm.OperandAddress) {
+				qualified := *op
+				qualified.Label = qualify(op.Label)
+				stmt.Operand = &qualified
+			}
+		}
+		out = append(out, stmt)
+	}
+	return out, nil
+}
+
+// moduleRefs returns the module names of the qualified labels referred to
+// by statements, in order of first reference.
+func moduleRefs(statements []asm.Statement) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	for _, stmt := range statements {
+		op := stmt.Operand
+		if stmt.Type != asm.StmtInstruction || op == nil ||
+			(op.Type != asm.OperandLabel && op.Type != asm.OperandAddress) {
+			continue
+		}
+		if i := strings.Index(op.Label, "."); i > 0 && !seen[op.Label[:i]] {
+			seen[op.Label[:i]] = true
+			refs = append(refs, op.Label[:i])
+		}
+	}
+	return refs
+}
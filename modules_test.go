@@ -0,0 +1,81 @@
+package stackvm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModuleLoaderLink(t *testing.T) {
+	program, err := NewModuleLoader("testdata/modules", AssemblerOptions{}).Link("main")
+	if err != nil {
+		t.Fatalf("Link() failed: %v", err)
+	}
+
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+	if len(result.Stack) != 1 {
+		t.Fatalf("Stack = %v, want one value", result.Stack)
+	}
+	if got, _ := result.Stack[0].AsFloat(); got != 25 {
+		t.Errorf("result = %v, want 25", result.Stack[0])
+	}
+
+	found := make(map[string]bool)
+	for _, name := range program.SymbolTable() {
+		found[name] = true
+	}
+	for _, name := range []string{"mathlib.square", "mathlib.mul"} {
+		if !found[name] {
+			t.Errorf("symbol table %v missing %s", program.SymbolTable(), name)
+		}
+	}
+	if name := program.Metadata().Name; name != "" {
+		t.Errorf("Metadata().Name = %q, want module metadata ignored", name)
+	}
+}
+
+func TestModuleLoaderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		modules  map[string]string
+		want     string
+		wantFile string // module whose file the error names
+		wantLine int
+	}{
+		{"missing module", map[string]string{"main": "CALL nolib.f\nHALT"}, "failed to read module nolib", "nolib", 0},
+		{"missing label", map[string]string{"main": "CALL lib.g\nHALT", "lib": "f: RET"}, "lib.g", "main", 1},
+		{"private label", map[string]string{"main": "CALL lib.f\nCALL g\nHALT", "lib": "f: RET\ng: RET"}, "unresolved label: g", "main", 2},
+		{"missing label in module", map[string]string{"main": "CALL lib.f\nHALT", "lib": "f: NOP\nJMP nowhere"}, "unresolved label: lib.nowhere", "lib", 2},
+		{"entry in module", map[string]string{"main": "CALL lib.f\nHALT", "lib": ".entry f\nf: RET"}, ".entry is only allowed in the main module", "lib", 1},
+		{"syntax error in module", map[string]string{"main": "CALL lib.f\nHALT", "lib": "f: RET `"}, "module lib", "lib", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, source := range tt.modules {
+				if err := os.WriteFile(filepath.Join(dir, name+".asm"), []byte(source), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := NewModuleLoader(dir, AssemblerOptions{}).Link("main")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Link() error = %v, want %q", err, tt.want)
+			}
+			var asmErr *AssemblerError
+			if !errors.As(err, &asmErr) {
+				t.Fatalf("Link() error %T is not an *AssemblerError", err)
+			}
+			if want := filepath.Join(dir, tt.wantFile+".asm"); asmErr.File != want {
+				t.Errorf("File = %q, want %q", asmErr.File, want)
+			}
+			if asmErr.Line != tt.wantLine {
+				t.Errorf("Line = %d, want %d (%v)", asmErr.Line, tt.wantLine, err)
+			}
+		})
+	}
+}
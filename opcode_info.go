@@ -0,0 +1,146 @@
+package stackvm
+
+// OperandKind describes how an instruction's operand is interpreted.
+type OperandKind uint8
+
+const (
+	OperandNone     OperandKind = iota // Operand is unused
+	OperandNumber                      // Immediate value or static memory address
+	OperandLabel                       // Instruction address (jump or call target)
+	OperandConstant                    // Constant pool index
+	OperandOffset                      // Signed offset from the instruction's address
+)
+
+// String returns the operand kind name.
+func (k OperandKind) String() string {
+	switch k {
+	case OperandNone:
+		return "none"
+	case OperandNumber:
+		return "number"
+	case OperandLabel:
+		return "label"
+	case OperandConstant:
+		return "constant"
+	case OperandOffset:
+		return "offset"
+	default:
+		return "unknown"
+	}
+}
+
+// OpcodeInfo describes a standard opcode: its mnemonic, operand kind, and
+// the number of values it pops from and pushes onto the stack. DUPN pushes
+// as many values as its operand, and SWAPN reaches as deep as its operand,
+// which Pops and Pushes cannot express, so they are 0.
+type OpcodeInfo struct {
+	Name    string
+	Operand OperandKind
+	Pops    int
+	Pushes  int
+}
+
+// opcodeTable is the single source of truth for standard opcode shapes.
+var opcodeTable = map[Opcode]OpcodeInfo{
+	// Stack operations
+	OpPUSH:    {"PUSH", OperandNumber, 0, 1},
+	OpPUSHI:   {"PUSHI", OperandNumber, 0, 1},
+	OpPOP:     {"POP", OperandNone, 1, 0},
+	OpDUP:     {"DUP", OperandNone, 1, 2},
+	OpSWAP:    {"SWAP", OperandNone, 2, 2},
+	OpOVER:    {"OVER", OperandNone, 2, 3},
+	OpROT:     {"ROT", OperandNone, 3, 3},
+	OpPUSHC:   {"PUSHC", OperandConstant, 0, 1},
+	OpTYPECHK: {"TYPECHK", OperandNumber, 1, 1},
+	OpDUPN:    {"DUPN", OperandNumber, 0, 0},
+	OpSWAPN:   {"SWAPN", OperandNumber, 0, 0},
+	OpEMPTY:   {"EMPTY", OperandNone, 0, 1},
+
+	// Arithmetic
+	OpADD: {"ADD", OperandNone, 2, 1},
+	OpSUB: {"SUB", OperandNone, 2, 1},
+	OpMUL: {"MUL", OperandNone, 2, 1},
+	OpDIV: {"DIV", OperandNone, 2, 1},
+	OpMOD: {"MOD", OperandNone, 2, 1},
+	OpNEG: {"NEG", OperandNone, 1, 1},
+	OpABS: {"ABS", OperandNone, 1, 1},
+	OpINC: {"INC", OperandNone, 1, 1},
+	OpDEC: {"DEC", OperandNone, 1, 1},
+	OpTOF: {"TOF", OperandNone, 1, 1},
+	OpTOI: {"TOI", OperandNone, 1, 1},
+
+	// Logic
+	OpAND:  {"AND", OperandNone, 2, 1},
+	OpOR:   {"OR", OperandNone, 2, 1},
+	OpNOT:  {"NOT", OperandNone, 1, 1},
+	OpXOR:  {"XOR", OperandNone, 2, 1},
+	OpSHR:  {"SHR", OperandNone, 2, 1},
+	OpUSHR: {"USHR", OperandNone, 2, 1},
+
+	// Comparison
+	OpEQ: {"EQ", OperandNone, 2, 1},
+	OpNE: {"NE", OperandNone, 2, 1},
+	OpGT: {"GT", OperandNone, 2, 1},
+	OpLT: {"LT", OperandNone, 2, 1},
+	OpGE: {"GE", OperandNone, 2, 1},
+	OpLE: {"LE", OperandNone, 2, 1},
+
+	// Memory
+	OpLOAD:   {"LOAD", OperandNumber, 0, 1},
+	OpSTORE:  {"STORE", OperandNumber, 1, 0},
+	OpLOADD:  {"LOADD", OperandNone, 1, 1},
+	OpSTORED: {"STORED", OperandNone, 2, 0},
+	OpMEMCLR: {"MEMCLR", OperandNumber, 1, 0},
+	OpRLOAD:  {"RLOAD", OperandNumber, 0, 1},
+	OpRSTORE: {"RSTORE", OperandNumber, 1, 0},
+
+	// Control flow
+	OpJMP:   {"JMP", OperandLabel, 0, 0},
+	OpJMPZ:  {"JMPZ", OperandLabel, 1, 0},
+	OpJMPNZ: {"JMPNZ", OperandLabel, 1, 0},
+	OpCALL:  {"CALL", OperandLabel, 0, 0},
+	OpRET:   {"RET", OperandNone, 0, 0},
+	OpHALT:  {"HALT", OperandNone, 0, 0},
+	OpNOP:   {"NOP", OperandNone, 0, 0},
+	OpEXIT:  {"EXIT", OperandNone, 1, 0},
+
+	// Relative control flow
+	OpJMPR:   {"JMPR", OperandOffset, 0, 0},
+	OpJMPZR:  {"JMPZR", OperandOffset, 1, 0},
+	OpJMPNZR: {"JMPNZR", OperandOffset, 1, 0},
+
+	// Math functions
+	OpSQRT:  {"SQRT", OperandNone, 1, 1},
+	OpSIN:   {"SIN", OperandNone, 1, 1},
+	OpCOS:   {"COS", OperandNone, 1, 1},
+	OpTAN:   {"TAN", OperandNone, 1, 1},
+	OpASIN:  {"ASIN", OperandNone, 1, 1},
+	OpACOS:  {"ACOS", OperandNone, 1, 1},
+	OpATAN:  {"ATAN", OperandNone, 1, 1},
+	OpATAN2: {"ATAN2", OperandNone, 2, 1},
+	OpLOG:   {"LOG", OperandNone, 1, 1},
+	OpLOG10: {"LOG10", OperandNone, 1, 1},
+	OpEXP:   {"EXP", OperandNone, 1, 1},
+	OpPOW:   {"POW", OperandNone, 2, 1},
+	OpMIN:   {"MIN", OperandNone, 2, 1},
+	OpMAX:   {"MAX", OperandNone, 2, 1},
+	OpFLOOR: {"FLOOR", OperandNone, 1, 1},
+	OpCEIL:  {"CEIL", OperandNone, 1, 1},
+	OpROUND: {"ROUND", OperandNone, 1, 1},
+	OpTRUNC: {"TRUNC", OperandNone, 1, 1},
+
+	// System operations; a trap's stack effect depends on its handler
+	OpTRAP:  {"TRAP", OperandNumber, 0, 0},
+	OpPRINT: {"PRINT", OperandNone, 1, 0},
+	OpFUEL:  {"FUEL", OperandNone, 0, 1},
+
+	// String operations
+	OpCONCAT: {"CONCAT", OperandNone, 2, 1},
+}
+
+// Info returns the description of a standard opcode.
+// Returns false for custom and undefined opcodes.
+func (op Opcode) Info() (OpcodeInfo, bool) {
+	info, ok := opcodeTable[op]
+	return info, ok
+}
@@ -0,0 +1,48 @@
+package stackvm
+
+import "testing"
+
+func TestOpcodeInfo(t *testing.T) {
+	tests := []struct {
+		opcode Opcode
+		want   OpcodeInfo
+	}{
+		{OpADD, OpcodeInfo{Name: "ADD", Operand: OperandNone, Pops: 2, Pushes: 1}},
+		{OpPUSH, OpcodeInfo{Name: "PUSH", Operand: OperandNumber, Pops: 0, Pushes: 1}},
+		{OpPUSHC, OpcodeInfo{Name: "PUSHC", Operand: OperandConstant, Pops: 0, Pushes: 1}},
+		{OpJMPZ, OpcodeInfo{Name: "JMPZ", Operand: OperandLabel, Pops: 1, Pushes: 0}},
+		{OpSTORED, OpcodeInfo{Name: "STORED", Operand: OperandNone, Pops: 2, Pushes: 0}},
+		{OpJMPZR, OpcodeInfo{Name: "JMPZR", Operand: OperandOffset, Pops: 1, Pushes: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want.Name, func(t *testing.T) {
+			got, ok := tt.opcode.Info()
+			if !ok {
+				t.Fatalf("Info() ok = false")
+			}
+			if got != tt.want {
+				t.Errorf("Info() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpcodeInfoUndefined(t *testing.T) {
+	for _, op := range []Opcode{Opcode(12), Opcode(100), Opcode(200)} {
+		if _, ok := op.Info(); ok {
+			t.Errorf("Info(%d) ok = true, want false", op)
+		}
+	}
+}
+
+func TestOpcodeInfoMatchesString(t *testing.T) {
+	for op, info := range opcodeTable {
+		if info.Name != op.String() {
+			t.Errorf("opcode %d: Info().Name = %q, String() = %q", op, info.Name, op.String())
+		}
+		if op.Category() == CategoryUnknown {
+			t.Errorf("opcode %s has an info entry but no category", info.Name)
+		}
+	}
+}
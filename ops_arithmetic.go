@@ -1,7 +1,9 @@
 package stackvm
 
+import "fmt"
+
 // opAdd pops two values, adds them, and pushes the result.
-func opAdd(stack []Value) ([]Value, error) {
+func (e *executor) opAdd(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
@@ -9,7 +11,7 @@ func opAdd(stack []Value) ([]Value, error) {
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
 
-	result, err := numericOp(a, b, func(x, y float64) float64 { return x + y })
+	result, err := e.numericOp(a, b, func(x, y float64) float64 { return x + y })
 	if err != nil {
 		return stack, err
 	}
@@ -18,7 +20,7 @@ func opAdd(stack []Value) ([]Value, error) {
 }
 
 // opSub pops two values, subtracts them, and pushes the result.
-func opSub(stack []Value) ([]Value, error) {
+func (e *executor) opSub(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
@@ -26,7 +28,7 @@ func opSub(stack []Value) ([]Value, error) {
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
 
-	result, err := numericOp(a, b, func(x, y float64) float64 { return x - y })
+	result, err := e.numericOp(a, b, func(x, y float64) float64 { return x - y })
 	if err != nil {
 		return stack, err
 	}
@@ -35,7 +37,7 @@ func opSub(stack []Value) ([]Value, error) {
 }
 
 // opMul pops two values, multiplies them, and pushes the result.
-func opMul(stack []Value) ([]Value, error) {
+func (e *executor) opMul(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
@@ -43,7 +45,7 @@ func opMul(stack []Value) ([]Value, error) {
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
 
-	result, err := numericOp(a, b, func(x, y float64) float64 { return x * y })
+	result, err := e.numericOp(a, b, func(x, y float64) float64 { return x * y })
 	if err != nil {
 		return stack, err
 	}
@@ -52,7 +54,7 @@ func opMul(stack []Value) ([]Value, error) {
 }
 
 // opDiv pops two values, divides them, and pushes the result.
-func opDiv(stack []Value) ([]Value, error) {
+func (e *executor) opDiv(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
@@ -60,15 +62,25 @@ func opDiv(stack []Value) ([]Value, error) {
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
 
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return stack, err
 	}
 	if bVal == 0 {
-		return stack, ErrDivisionByZero
-	}
-
-	result, err := numericOp(a, b, func(x, y float64) float64 { return x / y })
+		switch e.config.DivByZero {
+		case DivByZeroZero:
+			if _, err := e.toFloat64(a); err != nil {
+				return stack, err
+			}
+			return append(stack, FloatValue(0)), nil
+		case DivByZeroIEEE:
+			// Fall through to the float division, which yields ±Inf or NaN.
+		default:
+			return stack, ErrDivisionByZero
+		}
+	}
+
+	result, err := e.numericOp(a, b, func(x, y float64) float64 { return x / y })
 	if err != nil {
 		return stack, err
 	}
@@ -77,7 +89,7 @@ func opDiv(stack []Value) ([]Value, error) {
 }
 
 // opMod pops two values, computes modulo, and pushes the result.
-func opMod(stack []Value) ([]Value, error) {
+func (e *executor) opMod(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
@@ -85,15 +97,20 @@ func opMod(stack []Value) ([]Value, error) {
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
 
-	aVal, err := toInt64(a)
+	aVal, err := e.toInt64(a)
 	if err != nil {
 		return stack, err
 	}
-	bVal, err := toInt64(b)
+	bVal, err := e.toInt64(b)
 	if err != nil {
 		return stack, err
 	}
 	if bVal == 0 {
+		// Integer modulo by zero has no IEEE result, so only the Zero
+		// policy suppresses the error.
+		if e.config.DivByZero == DivByZeroZero {
+			return append(stack, IntValue(0)), nil
+		}
 		return stack, ErrDivisionByZero
 	}
 
@@ -102,14 +119,14 @@ func opMod(stack []Value) ([]Value, error) {
 }
 
 // opNeg pops a value, negates it, and pushes the result.
-func opNeg(stack []Value) ([]Value, error) {
+func (e *executor) opNeg(stack []Value) ([]Value, error) {
 	if len(stack) < 1 {
 		return stack, ErrStackUnderflow
 	}
 	a := stack[len(stack)-1]
 	stack = stack[:len(stack)-1]
 
-	result, err := unaryOp(a, func(x float64) float64 { return -x })
+	result, err := e.unaryOp(a, func(x float64) float64 { return -x })
 	if err != nil {
 		return stack, err
 	}
@@ -118,14 +135,14 @@ func opNeg(stack []Value) ([]Value, error) {
 }
 
 // opAbs pops a value, computes absolute value, and pushes the result.
-func opAbs(stack []Value) ([]Value, error) {
+func (e *executor) opAbs(stack []Value) ([]Value, error) {
 	if len(stack) < 1 {
 		return stack, ErrStackUnderflow
 	}
 	a := stack[len(stack)-1]
 	stack = stack[:len(stack)-1]
 
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
@@ -139,14 +156,14 @@ func opAbs(stack []Value) ([]Value, error) {
 }
 
 // opInc pops a value, increments it, and pushes the result.
-func opInc(stack []Value) ([]Value, error) {
+func (e *executor) opInc(stack []Value) ([]Value, error) {
 	if len(stack) < 1 {
 		return stack, ErrStackUnderflow
 	}
 	a := stack[len(stack)-1]
 	stack = stack[:len(stack)-1]
 
-	result, err := unaryOp(a, func(x float64) float64 { return x + 1 })
+	result, err := e.unaryOp(a, func(x float64) float64 { return x + 1 })
 	if err != nil {
 		return stack, err
 	}
@@ -155,14 +172,14 @@ func opInc(stack []Value) ([]Value, error) {
 }
 
 // opDec pops a value, decrements it, and pushes the result.
-func opDec(stack []Value) ([]Value, error) {
+func (e *executor) opDec(stack []Value) ([]Value, error) {
 	if len(stack) < 1 {
 		return stack, ErrStackUnderflow
 	}
 	a := stack[len(stack)-1]
 	stack = stack[:len(stack)-1]
 
-	result, err := unaryOp(a, func(x float64) float64 { return x - 1 })
+	result, err := e.unaryOp(a, func(x float64) float64 { return x - 1 })
 	if err != nil {
 		return stack, err
 	}
@@ -170,9 +187,49 @@ func opDec(stack []Value) ([]Value, error) {
 	return append(stack, result), nil
 }
 
+// opToFloat pops a value, converts it to a float, and pushes the result.
+func (e *executor) opToFloat(stack []Value) ([]Value, error) {
+	if len(stack) < 1 {
+		return stack, ErrStackUnderflow
+	}
+	a := stack[len(stack)-1]
+	stack = stack[:len(stack)-1]
+
+	f, err := e.toFloat64(a)
+	if err != nil {
+		return stack, err
+	}
+
+	return append(stack, FloatValue(f)), nil
+}
+
+// opToInt pops a value, converts it to an int (truncating toward zero),
+// and pushes the result.
+func (e *executor) opToInt(stack []Value) ([]Value, error) {
+	if len(stack) < 1 {
+		return stack, ErrStackUnderflow
+	}
+	a := stack[len(stack)-1]
+	stack = stack[:len(stack)-1]
+
+	// Go leaves converting NaN, ±Inf, or an out-of-range float undefined.
+	if a.Type == TypeFloat {
+		if f, _ := a.AsFloat(); !(f >= -(1<<63) && f < 1<<63) {
+			return stack, fmt.Errorf("%w: TOI of %v is outside the int range", ErrInvalidOperand, a)
+		}
+	}
+
+	i, err := e.toInt64(a)
+	if err != nil {
+		return stack, err
+	}
+
+	return append(stack, IntValue(i)), nil
+}
+
 // Helper function for unary operations
-func unaryOp(v Value, op func(float64) float64) (Value, error) {
-	val, err := toFloat64(v)
+func (e *executor) unaryOp(v Value, op func(float64) float64) (Value, error) {
+	val, err := e.toFloat64(v)
 	if err != nil {
 		return NilValue(), err
 	}
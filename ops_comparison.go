@@ -1,105 +1,135 @@
 package stackvm
 
 // opEq pops two values, compares for equality, and pushes the result.
-func opEq(stack []Value) ([]Value, error) {
+func (e *executor) opEq(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	result := a.Equal(b)
-	return append(stack, BoolValue(result)), nil
+	result := valuesEqual(a, b)
+	return append(stack, e.comparisonValue(result)), nil
 }
 
 // opNe pops two values, compares for inequality, and pushes the result.
-func opNe(stack []Value) ([]Value, error) {
+func (e *executor) opNe(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	result := !a.Equal(b)
-	return append(stack, BoolValue(result)), nil
+	result := !valuesEqual(a, b)
+	return append(stack, e.comparisonValue(result)), nil
 }
 
 // opGt pops two values, checks if first > second, and pushes the result.
-func opGt(stack []Value) ([]Value, error) {
+func (e *executor) opGt(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return stack, err
 	}
 	result := aVal > bVal
-	return append(stack, BoolValue(result)), nil
+	return append(stack, e.comparisonValue(result)), nil
 }
 
 // opLt pops two values, checks if first < second, and pushes the result.
-func opLt(stack []Value) ([]Value, error) {
+func (e *executor) opLt(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return stack, err
 	}
 	result := aVal < bVal
-	return append(stack, BoolValue(result)), nil
+	return append(stack, e.comparisonValue(result)), nil
 }
 
 // opGe pops two values, checks if first >= second, and pushes the result.
-func opGe(stack []Value) ([]Value, error) {
+func (e *executor) opGe(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return stack, err
 	}
 	result := aVal >= bVal
-	return append(stack, BoolValue(result)), nil
+	return append(stack, e.comparisonValue(result)), nil
 }
 
 // opLe pops two values, checks if first <= second, and pushes the result.
-func opLe(stack []Value) ([]Value, error) {
+func (e *executor) opLe(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return stack, err
 	}
 	result := aVal <= bVal
-	return append(stack, BoolValue(result)), nil
+	return append(stack, e.comparisonValue(result)), nil
+}
+
+// comparisonValue converts a comparison outcome to the configured result type.
+func (e *executor) comparisonValue(result bool) Value {
+	if e.config.ComparisonResult == ComparisonInt {
+		if result {
+			return IntValue(1)
+		}
+		return IntValue(0)
+	}
+	return BoolValue(result)
+}
+
+// valuesEqual compares two values for EQ and NE. An Int and a Float are
+// compared numerically, so PUSH 5 equals PUSHI 5; otherwise values of
+// different types are never equal. The comparison is exact: converting
+// the Int to a float could round it onto a nearby Float.
+func valuesEqual(a, b Value) bool {
+	if a.Type == TypeInt && b.Type == TypeFloat {
+		a, b = b, a
+	}
+	if a.Type == TypeFloat && b.Type == TypeInt {
+		f, _ := a.AsFloat()
+		i, _ := b.AsInt()
+		if !(f >= -(1<<63) && f < 1<<63) {
+			return false // out of int64 range, or NaN
+		}
+		return float64(int64(f)) == f && int64(f) == i
+	}
+	return a.Equal(b)
 }
@@ -1,6 +1,8 @@
 package stackvm
 
 import (
+	"errors"
+	"math"
 	"testing"
 )
 
@@ -173,3 +175,604 @@ func TestStackOperations(t *testing.T) {
 		t.Errorf("StackDepth = %d, want 4", result.StackDepth)
 	}
 }
+
+func TestDivByZeroPolicy(t *testing.T) {
+	divide := func(policy DivByZeroPolicy, a int32, op Opcode) (Value, error) {
+		vm := NewWithConfig(Config{StackSize: 256, DivByZero: policy})
+		memory := NewSimpleMemory(1)
+		program := NewProgram([]Instruction{
+			NewInstruction(OpPUSHI, a),
+			NewInstruction(OpPUSHI, 0),
+			NewInstruction(op, 0),
+			NewInstruction(OpSTORE, 0),
+			NewInstruction(OpHALT, 0),
+		})
+		_, err := vm.Execute(program, memory, ExecuteOptions{})
+		val, _ := memory.Load(0)
+		return val, err
+	}
+
+	tests := []struct {
+		name    string
+		policy  DivByZeroPolicy
+		a       int32
+		op      Opcode
+		check   func(Value) bool
+		wantErr bool
+	}{
+		{"Error 10/0", DivByZeroError, 10, OpDIV, nil, true},
+		{"Error 0/0", DivByZeroError, 0, OpDIV, nil, true},
+		{"IEEE 10/0", DivByZeroIEEE, 10, OpDIV, func(v Value) bool {
+			f, _ := v.AsFloat()
+			return math.IsInf(f, 1)
+		}, false},
+		{"IEEE 0/0", DivByZeroIEEE, 0, OpDIV, func(v Value) bool {
+			f, _ := v.AsFloat()
+			return math.IsNaN(f)
+		}, false},
+		{"IEEE 10%0", DivByZeroIEEE, 10, OpMOD, nil, true},
+		{"Zero 10/0", DivByZeroZero, 10, OpDIV, func(v Value) bool { return v.Equal(FloatValue(0)) }, false},
+		{"Zero 0/0", DivByZeroZero, 0, OpDIV, func(v Value) bool { return v.Equal(FloatValue(0)) }, false},
+		{"Zero 10%0", DivByZeroZero, 10, OpMOD, func(v Value) bool { return v.Equal(IntValue(0)) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := divide(tt.policy, tt.a, tt.op)
+			if tt.wantErr {
+				if !errors.Is(err, ErrDivisionByZero) {
+					t.Errorf("error = %v, want ErrDivisionByZero", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !tt.check(val) {
+				t.Errorf("result = %v", val)
+			}
+		})
+	}
+}
+
+func TestComparisonResultMode(t *testing.T) {
+	run := func(mode ComparisonResultMode, instructions ...Instruction) (Value, error) {
+		vm := NewWithConfig(Config{StackSize: 256, ComparisonResult: mode})
+		memory := NewSimpleMemory(1)
+		program := NewProgram(append(instructions,
+			NewInstruction(OpSTORE, 0),
+			NewInstruction(OpHALT, 0),
+		))
+		_, err := vm.Execute(program, memory, ExecuteOptions{})
+		val, _ := memory.Load(0)
+		return val, err
+	}
+	gt := []Instruction{
+		NewInstruction(OpPUSHI, 5),
+		NewInstruction(OpPUSHI, 3),
+		NewInstruction(OpGT, 0),
+	}
+
+	tests := []struct {
+		name string
+		mode ComparisonResultMode
+		want Value
+	}{
+		{"Bool", ComparisonBool, BoolValue(true)},
+		{"Int", ComparisonInt, IntValue(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := run(tt.mode, gt...)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if val.Type != tt.want.Type || !val.Equal(tt.want) {
+				t.Errorf("5 > 3 = %v (type %d), want %v", val, val.Type, tt.want)
+			}
+		})
+	}
+
+	t.Run("Int result feeds ADD", func(t *testing.T) {
+		val, err := run(ComparisonInt, append(gt,
+			NewInstruction(OpPUSHI, 10),
+			NewInstruction(OpADD, 0),
+		)...)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if f, _ := val.AsFloat(); f != 11 {
+			t.Errorf("(5 > 3) + 10 = %v, want 11", val)
+		}
+	})
+}
+
+func TestBitwiseIntLogic(t *testing.T) {
+	run := func(bitwise bool, a, b Value, op Opcode) Value {
+		vm := NewWithConfig(Config{StackSize: 256, BitwiseIntLogic: bitwise})
+		program := NewProgram([]Instruction{
+			NewInstruction(OpPUSHC, 0),
+			NewInstruction(OpPUSHC, 1),
+			NewInstruction(op, 0),
+			NewInstruction(OpSTORE, 0),
+			NewInstruction(OpHALT, 0),
+		})
+		program.SetConstants([]Value{a, b})
+		memory := NewSimpleMemory(1)
+		if _, err := vm.Execute(program, memory, ExecuteOptions{}); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		val, _ := memory.Load(0)
+		return val
+	}
+
+	tests := []struct {
+		name    string
+		bitwise bool
+		a, b    Value
+		op      Opcode
+		want    Value
+	}{
+		{"flag off 6 AND 3", false, IntValue(6), IntValue(3), OpAND, BoolValue(true)},
+		{"6 AND 3", true, IntValue(6), IntValue(3), OpAND, IntValue(2)},
+		{"6 OR 3", true, IntValue(6), IntValue(3), OpOR, IntValue(7)},
+		{"6 XOR 3", true, IntValue(6), IntValue(3), OpXOR, IntValue(5)},
+		{"true AND false", true, BoolValue(true), BoolValue(false), OpAND, BoolValue(false)},
+		{"true OR false", true, BoolValue(true), BoolValue(false), OpOR, BoolValue(true)},
+		{"true XOR true", true, BoolValue(true), BoolValue(true), OpXOR, BoolValue(false)},
+		{"mixed int and float", true, IntValue(6), FloatValue(3), OpAND, BoolValue(true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := run(tt.bitwise, tt.a, tt.b, tt.op)
+			if got.Type != tt.want.Type || !got.Equal(tt.want) {
+				t.Errorf("result = %v (type %d), want %v", got, got.Type, tt.want)
+			}
+		})
+	}
+}
+
+func TestShiftRight(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    int64
+		wantErr error
+	}{
+		{"SHR negative", "PUSHI -8\nPUSHI 1\nSHR\nHALT", -4, nil},
+		{"USHR negative", "PUSHI -8\nPUSHI 1\nUSHR\nHALT", 1<<63 - 4, nil},
+		{"SHR positive", "PUSHI 40\nPUSHI 3\nSHR\nHALT", 5, nil},
+		{"USHR positive", "PUSHI 40\nPUSHI 3\nUSHR\nHALT", 5, nil},
+		{"SHR odd negative rounds down", "PUSHI -7\nPUSHI 1\nSHR\nHALT", -4, nil},
+		{"SHR all bits", "PUSHI -1\nPUSHI 64\nSHR\nHALT", -1, nil},
+		{"USHR all bits", "PUSHI -1\nPUSHI 64\nUSHR\nHALT", 0, nil},
+		{"USHR to top bit", "PUSHI -1\nPUSHI 63\nUSHR\nHALT", 1, nil},
+		{"float truncated", "PUSH -8.5\nPUSH 1.9\nSHR\nHALT", -4, nil},
+		{"negative count", "PUSHI 8\nPUSHI -1\nSHR\nHALT", 0, ErrInvalidOperand},
+		{"underflow", "PUSHI 8\nUSHR\nHALT", 0, ErrStackUnderflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New().Execute(MustAssemble(tt.source), NewSimpleMemory(0), ExecuteOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(result.Stack) != 1 || !result.Stack[0].Equal(IntValue(tt.want)) {
+				t.Errorf("stack = %v, want [%d]", result.Stack, tt.want)
+			}
+		})
+	}
+
+	t.Run("builder", func(t *testing.T) {
+		program := mustBuild(t, NewProgramBuilder().PushInt(-16).PushInt(2).Shr().PushInt(60).Ushr().Halt())
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !result.Stack[0].Equal(IntValue(15)) {
+			t.Errorf("stack = %v, want [15]", result.Stack)
+		}
+	})
+}
+
+func TestTypeCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr bool
+	}{
+		{"int matches", "PUSHI 5\nTYPECHK 2\nHALT", false},
+		{"float matches", "PUSH 2.5\nTYPECHK 1\nHALT", false},
+		{"float is not int", "PUSH 2.5\nTYPECHK 2\nHALT", true},
+		{"int is not bool", "PUSHI 1\nTYPECHK 3\nHALT", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := NewAssembler().Assemble(tt.source)
+			if err != nil {
+				t.Fatalf("Assemble() failed: %v", err)
+			}
+			result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Execute() error = %v", err)
+				}
+				if len(result.Stack) != 1 {
+					t.Errorf("stack = %v, want value left in place", result.Stack)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrUnexpectedType) {
+				t.Fatalf("Execute() error = %v, want ErrUnexpectedType", err)
+			}
+			var vmErr *VMError
+			if !errors.As(err, &vmErr) {
+				t.Fatalf("error %T is not a *VMError", err)
+			}
+			if vmErr.PC != 1 || vmErr.Opcode != OpTYPECHK {
+				t.Errorf("PC = %d, Opcode = %s, want 1, TYPECHK", vmErr.PC, vmErr.Opcode)
+			}
+		})
+	}
+
+	t.Run("empty stack", func(t *testing.T) {
+		program := mustBuild(t, NewProgramBuilder().TypeCheck(TypeInt).Halt())
+		_, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if !errors.Is(err, ErrStackUnderflow) {
+			t.Errorf("Execute() error = %v, want ErrStackUnderflow", err)
+		}
+	})
+}
+
+func TestDupN(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []int64
+		wantErr error
+	}{
+		{"two of three", "PUSHI 1\nPUSHI 2\nPUSHI 3\nDUPN 2\nHALT", []int64{1, 2, 3, 2, 3}, nil},
+		{"whole stack", "PUSHI 1\nPUSHI 2\nDUPN 2\nHALT", []int64{1, 2, 1, 2}, nil},
+		{"zero", "PUSHI 1\nDUPN 0\nHALT", []int64{1}, nil},
+		{"underflow", "PUSHI 1\nDUPN 2\nHALT", nil, ErrStackUnderflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := MustAssemble(tt.source)
+			result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+				}
+				if result.StackDepth != 1 {
+					t.Errorf("StackDepth = %d, want stack left unchanged", result.StackDepth)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if len(result.Stack) != len(tt.want) {
+				t.Fatalf("stack = %v, want %v", result.Stack, tt.want)
+			}
+			for i, want := range tt.want {
+				if !result.Stack[i].Equal(IntValue(want)) {
+					t.Errorf("stack[%d] = %v, want %d", i, result.Stack[i], want)
+				}
+			}
+		})
+	}
+
+	t.Run("overflow", func(t *testing.T) {
+		program := mustBuild(t, NewProgramBuilder().PushInt(1).PushInt(2).DupN(2).Halt())
+		_, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{MaxStackDepth: 3})
+		var overflow *StackOverflowError
+		if !errors.As(err, &overflow) || overflow.Attempted != 4 {
+			t.Errorf("Execute() error = %v, want overflow attempting depth 4", err)
+		}
+	})
+}
+
+func TestSwapN(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []int64
+		wantErr error
+	}{
+		{"third from top", "PUSHI 1\nPUSHI 2\nPUSHI 3\nPUSHI 4\nSWAPN 2\nHALT", []int64{1, 4, 3, 2}, nil},
+		{"same as SWAP", "PUSHI 1\nPUSHI 2\nSWAPN 1\nHALT", []int64{2, 1}, nil},
+		{"zero", "PUSHI 1\nPUSHI 2\nSWAPN 0\nHALT", []int64{1, 2}, nil},
+		{"underflow", "PUSHI 1\nPUSHI 2\nSWAPN 2\nHALT", []int64{1, 2}, ErrStackUnderflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := MustAssemble(tt.source)
+			result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(result.Stack) != len(tt.want) {
+				t.Fatalf("stack = %v, want %v", result.Stack, tt.want)
+			}
+			for i, want := range tt.want {
+				if !result.Stack[i].Equal(IntValue(want)) {
+					t.Errorf("stack[%d] = %v, want %d", i, result.Stack[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestConcat(t *testing.T) {
+	program := mustBuild(t, NewProgramBuilder().
+		PushConst(StringValue("stack")).
+		PushConst(StringValue("vm")).
+		Concat().
+		Halt())
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result.Stack) != 1 {
+		t.Fatalf("stack = %v, want one value", result.Stack)
+	}
+	if s, err := result.Stack[0].AsString(); err != nil || s != "stackvm" {
+		t.Errorf("AsString() = %q, %v; want \"stackvm\"", s, err)
+	}
+
+	tests := []struct {
+		name    string
+		builder *ProgramBuilder
+		opts    ExecuteOptions
+		wantErr error
+	}{
+		{"int operand", NewProgramBuilder().PushConst(StringValue("a")).PushInt(1).Concat(), ExecuteOptions{}, ErrTypeMismatch},
+		{"underflow", NewProgramBuilder().PushConst(StringValue("a")).Concat(), ExecuteOptions{}, ErrStackUnderflow},
+		{"memory limit", NewProgramBuilder().PushConst(StringValue("abc")).Dup().Concat(), ExecuteOptions{MaxMemoryBytes: 8}, ErrMemoryLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := mustBuild(t, tt.builder)
+			if _, err := New().Execute(program, NewSimpleMemory(0), tt.opts); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	// Sum the stack into memory[0] until it is empty.
+	program := MustAssemble(`
+		PUSHI 1
+		PUSHI 2
+		PUSHI 3
+	drain:
+		EMPTY
+		JMPNZ done
+		LOAD 0
+		ADD
+		STORE 0
+		JMP drain
+	done:
+		HALT
+	`)
+	memory := NewSimpleMemory(1)
+	memory.Store(0, IntValue(0))
+	result, err := New().Execute(program, memory, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.StackDepth != 0 {
+		t.Errorf("StackDepth = %d, want 0", result.StackDepth)
+	}
+	if sum, _ := memory.Load(0); !sum.Equal(FloatValue(6)) {
+		t.Errorf("sum = %v, want 6", sum)
+	}
+
+	program = mustBuild(t, NewProgramBuilder().Empty().Empty().Halt())
+	result, err = New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	want := []Value{BoolValue(true), BoolValue(false)}
+	for i := range want {
+		if !result.Stack[i].Equal(want[i]) {
+			t.Errorf("stack[%d] = %v, want %v", i, result.Stack[i], want[i])
+		}
+	}
+
+	program = mustBuild(t, NewProgramBuilder().PushInt(1).Empty())
+	if _, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{MaxStackDepth: 1}); !errors.Is(err, ErrStackOverflow) {
+		t.Errorf("Execute() error = %v, want ErrStackOverflow", err)
+	}
+}
+
+func TestFuel(t *testing.T) {
+	// Count loop iterations in memory[0], stopping while enough fuel is
+	// left to halt cleanly.
+	program := MustAssemble(`
+	loop:
+		FUEL
+		PUSHI 16
+		LT
+		JMPNZ done
+		LOAD 0
+		INC
+		STORE 0
+		JMP loop
+	done:
+		HALT
+	`)
+	memory := NewSimpleMemory(1)
+	memory.Store(0, IntValue(0))
+	result, err := New().Execute(program, memory, ExecuteOptions{MaxInstructions: 100})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Halted || result.InstructionCount != 93 {
+		t.Errorf("Halted = %v, InstructionCount = %d, want a HALT after 93", result.Halted, result.InstructionCount)
+	}
+	// The twelfth FUEL sees 100-89 = 11 instructions left.
+	if count, _ := memory.Load(0); count.String() != "11" {
+		t.Errorf("iterations = %v, want 11", count)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		opts   ExecuteOptions
+		want   int64
+	}{
+		{"limit", Config{}, ExecuteOptions{MaxInstructions: 10}, 7},
+		{"default limit", Config{DefaultInstrLimit: 20}, ExecuteOptions{}, 17},
+		{"unlimited", Config{}, ExecuteOptions{}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := mustBuild(t, NewProgramBuilder().Nop().Nop().Fuel().Halt())
+			result, err := NewWithConfig(tt.config).Execute(program, NewSimpleMemory(0), tt.opts)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if len(result.Stack) != 1 || !result.Stack[0].Equal(IntValue(tt.want)) {
+				t.Errorf("Stack = %v, want [%d]", result.Stack, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypeConversion(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   Value
+	}{
+		{"int to float", "PUSHI 7\nTOF\nHALT", FloatValue(7)},
+		{"float to int truncates", "PUSH -2.75\nTOI\nHALT", IntValue(-2)},
+		{"int round trip", "PUSHI 42\nTOF\nTOI\nHALT", IntValue(42)},
+		{"float to float", "PUSH 1.5\nTOF\nHALT", FloatValue(1.5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := NewAssembler().Assemble(tt.source)
+			if err != nil {
+				t.Fatalf("Assemble() failed: %v", err)
+			}
+			result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if len(result.Stack) != 1 {
+				t.Fatalf("stack = %v, want one value", result.Stack)
+			}
+			if got := result.Stack[0]; got.Type != tt.want.Type || !got.Equal(tt.want) {
+				t.Errorf("result = %v (type %d), want %v (type %d)", got, got.Type, tt.want, tt.want.Type)
+			}
+		})
+	}
+
+	t.Run("builder and errors", func(t *testing.T) {
+		program := mustBuild(t, NewProgramBuilder().PushConst(StringValue("x")).ToInt().Halt())
+		if _, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{}); !errors.Is(err, ErrTypeMismatch) {
+			t.Errorf("TOI on a string: error = %v, want ErrTypeMismatch", err)
+		}
+		for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1 << 63, -1 << 64} {
+			program := mustBuild(t, NewProgramBuilder().Push(f).ToInt().Halt())
+			if _, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{}); !errors.Is(err, ErrInvalidOperand) {
+				t.Errorf("TOI of %v: error = %v, want ErrInvalidOperand", f, err)
+			}
+		}
+		program = mustBuild(t, NewProgramBuilder().Push(-1 << 63).ToInt().Halt())
+		if result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{}); err != nil || !result.Stack[0].Equal(IntValue(math.MinInt64)) {
+			t.Errorf("TOI of -2^63 = %v, %v; want %d", result.Stack, err, int64(math.MinInt64))
+		}
+		program = mustBuild(t, NewProgramBuilder().ToFloat())
+		if _, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{}); !errors.Is(err, ErrStackUnderflow) {
+			t.Errorf("TOF on empty stack: error = %v, want ErrStackUnderflow", err)
+		}
+	})
+}
+
+func TestMixedNumericEquality(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Instruction
+		op   Opcode
+		want bool
+	}{
+		{"PUSH 5 EQ PUSHI 5", NewInstruction(OpPUSH, 5), NewInstruction(OpPUSHI, 5), OpEQ, true},
+		{"PUSHI 5 EQ PUSH 5", NewInstruction(OpPUSHI, 5), NewInstruction(OpPUSH, 5), OpEQ, true},
+		{"PUSH 5 EQ PUSHI 6", NewInstruction(OpPUSH, 5), NewInstruction(OpPUSHI, 6), OpEQ, false},
+		{"PUSH 5 NE PUSHI 5", NewInstruction(OpPUSH, 5), NewInstruction(OpPUSHI, 5), OpNE, false},
+		{"PUSHI 5 GE PUSH 5", NewInstruction(OpPUSHI, 5), NewInstruction(OpPUSH, 5), OpGE, true},
+		{"PUSH 5 LT PUSHI 6", NewInstruction(OpPUSH, 5), NewInstruction(OpPUSHI, 6), OpLT, true},
+		{"PUSHI 5 GT PUSH 5", NewInstruction(OpPUSHI, 5), NewInstruction(OpPUSH, 5), OpGT, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := NewProgram([]Instruction{tt.a, tt.b, NewInstruction(tt.op, 0), NewInstruction(OpHALT, 0)})
+			result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := result.Stack[0]; !got.Equal(BoolValue(tt.want)) {
+				t.Errorf("result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Large Ints are not rounded to the nearest Float.
+	large := []struct {
+		i    int64
+		f    float64
+		want bool
+	}{
+		{1<<53 + 1, 1 << 53, false},
+		{1 << 53, 1 << 53, true},
+		{math.MaxInt64, 1 << 63, false},
+		{math.MinInt64, -(1 << 63), true},
+	}
+	for _, tt := range large {
+		program := NewProgram([]Instruction{
+			NewInstruction(OpPUSHC, 0),
+			NewInstruction(OpPUSHC, 1),
+			NewInstruction(OpEQ, 0),
+			NewInstruction(OpHALT, 0),
+		})
+		program.SetConstants([]Value{IntValue(tt.i), FloatValue(tt.f)})
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if got := result.Stack[0]; !got.Equal(BoolValue(tt.want)) {
+			t.Errorf("%d == %v = %v, want %v", tt.i, tt.f, got, tt.want)
+		}
+	}
+
+	// Other type mismatches are still unequal.
+	result, err := New().Execute(NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 1),
+		NewInstruction(OpPUSHI, 1),
+		NewInstruction(OpPUSHI, 1),
+		NewInstruction(OpEQ, 0), // Bool true
+		NewInstruction(OpEQ, 0), // Int 1 == Bool true
+		NewInstruction(OpHALT, 0),
+	}), NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Stack[0].Equal(BoolValue(false)) {
+		t.Errorf("1 == true = %v, want false", result.Stack[0])
+	}
+}
@@ -1,50 +1,125 @@
 package stackvm
 
+import "fmt"
+
 // opAnd pops two values, performs logical AND, and pushes the result.
-func opAnd(stack []Value) ([]Value, error) {
+// See Config.BitwiseIntLogic for the integer form.
+func (e *executor) opAnd(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	result := a.IsTruthy() && b.IsTruthy()
+	if x, y, ok := e.bitwiseOperands(a, b); ok {
+		return append(stack, IntValue(x&y)), nil
+	}
+	result := e.toBool(a) && e.toBool(b)
 	return append(stack, BoolValue(result)), nil
 }
 
 // opOr pops two values, performs logical OR, and pushes the result.
-func opOr(stack []Value) ([]Value, error) {
+// See Config.BitwiseIntLogic for the integer form.
+func (e *executor) opOr(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	result := a.IsTruthy() || b.IsTruthy()
+	if x, y, ok := e.bitwiseOperands(a, b); ok {
+		return append(stack, IntValue(x|y)), nil
+	}
+	result := e.toBool(a) || e.toBool(b)
 	return append(stack, BoolValue(result)), nil
 }
 
 // opNot pops a value, performs logical NOT, and pushes the result.
-func opNot(stack []Value) ([]Value, error) {
+func (e *executor) opNot(stack []Value) ([]Value, error) {
 	if len(stack) < 1 {
 		return stack, ErrStackUnderflow
 	}
 	a := stack[len(stack)-1]
 	stack = stack[:len(stack)-1]
-	result := !a.IsTruthy()
+	result := !e.toBool(a)
 	return append(stack, BoolValue(result)), nil
 }
 
 // opXor pops two values, performs logical XOR, and pushes the result.
-func opXor(stack []Value) ([]Value, error) {
+// See Config.BitwiseIntLogic for the integer form.
+func (e *executor) opXor(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	aTruthy := a.IsTruthy()
-	bTruthy := b.IsTruthy()
+	if x, y, ok := e.bitwiseOperands(a, b); ok {
+		return append(stack, IntValue(x^y)), nil
+	}
+	aTruthy := e.toBool(a)
+	bTruthy := e.toBool(b)
 	result := (aTruthy || bTruthy) && !(aTruthy && bTruthy)
 	return append(stack, BoolValue(result)), nil
 }
+
+// opShr pops a shift count and a value, shifts the value right keeping
+// its sign, and pushes the result.
+func (e *executor) opShr(stack []Value) ([]Value, error) {
+	if len(stack) < 2 {
+		return stack, ErrStackUnderflow
+	}
+	b := stack[len(stack)-1]
+	a := stack[len(stack)-2]
+	stack = stack[:len(stack)-2]
+	x, n, err := e.shiftOperands(a, b)
+	if err != nil {
+		return stack, err
+	}
+	return append(stack, IntValue(x>>n)), nil
+}
+
+// opUshr pops a shift count and a value, shifts the value right as an
+// unsigned 64-bit integer, filling with zeros, and pushes the result.
+func (e *executor) opUshr(stack []Value) ([]Value, error) {
+	if len(stack) < 2 {
+		return stack, ErrStackUnderflow
+	}
+	b := stack[len(stack)-1]
+	a := stack[len(stack)-2]
+	stack = stack[:len(stack)-2]
+	x, n, err := e.shiftOperands(a, b)
+	if err != nil {
+		return stack, err
+	}
+	return append(stack, IntValue(int64(uint64(x)>>n))), nil
+}
+
+// shiftOperands converts the value and shift count of SHR or USHR to
+// integers. Counts of 64 or more shift every bit out; negative counts are
+// rejected.
+func (e *executor) shiftOperands(a, b Value) (int64, uint64, error) {
+	x, err := e.toInt64(a)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := e.toInt64(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	if n < 0 {
+		return 0, 0, fmt.Errorf("%w: negative shift count %d", ErrInvalidOperand, n)
+	}
+	return x, uint64(n), nil
+}
+
+// bitwiseOperands returns the integer operands of AND, OR, or XOR when
+// Config.BitwiseIntLogic is set and both values are TypeInt.
+func (e *executor) bitwiseOperands(a, b Value) (int64, int64, bool) {
+	if !e.config.BitwiseIntLogic || a.Type != TypeInt || b.Type != TypeInt {
+		return 0, 0, false
+	}
+	x, _ := a.AsInt()
+	y, _ := b.AsInt()
+	return x, y, true
+}
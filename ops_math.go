@@ -4,46 +4,46 @@ import "math"
 
 // Math operations
 
-func opSqrt(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Sqrt)
+func (e *executor) opSqrt(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Sqrt)
 }
 
-func opSin(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Sin)
+func (e *executor) opSin(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Sin)
 }
 
-func opCos(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Cos)
+func (e *executor) opCos(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Cos)
 }
 
-func opTan(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Tan)
+func (e *executor) opTan(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Tan)
 }
 
-func opAsin(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Asin)
+func (e *executor) opAsin(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Asin)
 }
 
-func opAcos(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Acos)
+func (e *executor) opAcos(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Acos)
 }
 
-func opAtan(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Atan)
+func (e *executor) opAtan(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Atan)
 }
 
-func opAtan2(stack []Value) ([]Value, error) {
+func (e *executor) opAtan2(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	x := stack[len(stack)-1]
 	y := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	yVal, err := toFloat64(y)
+	yVal, err := e.toFloat64(y)
 	if err != nil {
 		return stack, err
 	}
-	xVal, err := toFloat64(x)
+	xVal, err := e.toFloat64(x)
 	if err != nil {
 		return stack, err
 	}
@@ -51,30 +51,30 @@ func opAtan2(stack []Value) ([]Value, error) {
 	return append(stack, FloatValue(result)), nil
 }
 
-func opLog(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Log)
+func (e *executor) opLog(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Log)
 }
 
-func opLog10(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Log10)
+func (e *executor) opLog10(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Log10)
 }
 
-func opExp(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Exp)
+func (e *executor) opExp(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Exp)
 }
 
-func opPow(stack []Value) ([]Value, error) {
+func (e *executor) opPow(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return stack, err
 	}
@@ -82,18 +82,18 @@ func opPow(stack []Value) ([]Value, error) {
 	return append(stack, FloatValue(result)), nil
 }
 
-func opMin(stack []Value) ([]Value, error) {
+func (e *executor) opMin(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return stack, err
 	}
@@ -101,18 +101,18 @@ func opMin(stack []Value) ([]Value, error) {
 	return append(stack, FloatValue(result)), nil
 }
 
-func opMax(stack []Value) ([]Value, error) {
+func (e *executor) opMax(stack []Value) ([]Value, error) {
 	if len(stack) < 2 {
 		return stack, ErrStackUnderflow
 	}
 	b := stack[len(stack)-1]
 	a := stack[len(stack)-2]
 	stack = stack[:len(stack)-2]
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
-	bVal, err := toFloat64(b)
+	bVal, err := e.toFloat64(b)
 	if err != nil {
 		return stack, err
 	}
@@ -120,29 +120,29 @@ func opMax(stack []Value) ([]Value, error) {
 	return append(stack, FloatValue(result)), nil
 }
 
-func opFloor(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Floor)
+func (e *executor) opFloor(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Floor)
 }
 
-func opCeil(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Ceil)
+func (e *executor) opCeil(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Ceil)
 }
 
-func opRound(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Round)
+func (e *executor) opRound(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Round)
 }
 
-func opTrunc(stack []Value) ([]Value, error) {
-	return mathUnaryOp(stack, math.Trunc)
+func (e *executor) opTrunc(stack []Value) ([]Value, error) {
+	return e.mathUnaryOp(stack, math.Trunc)
 }
 
-func mathUnaryOp(stack []Value, op func(float64) float64) ([]Value, error) {
+func (e *executor) mathUnaryOp(stack []Value, op func(float64) float64) ([]Value, error) {
 	if len(stack) < 1 {
 		return stack, ErrStackUnderflow
 	}
 	a := stack[len(stack)-1]
 	stack = stack[:len(stack)-1]
-	aVal, err := toFloat64(a)
+	aVal, err := e.toFloat64(a)
 	if err != nil {
 		return stack, err
 	}
@@ -0,0 +1,21 @@
+package stackvm
+
+import "fmt"
+
+// opConcat pops two strings and pushes their concatenation, the second
+// from the top first.
+func (e *executor) opConcat(stack []Value) ([]Value, error) {
+	if len(stack) < 2 {
+		return stack, ErrStackUnderflow
+	}
+	b := stack[len(stack)-1]
+	a := stack[len(stack)-2]
+	stack = stack[:len(stack)-2]
+
+	x, errA := a.AsString()
+	y, errB := b.AsString()
+	if errA != nil || errB != nil {
+		return stack, fmt.Errorf("%w: CONCAT requires two strings", ErrTypeMismatch)
+	}
+	return append(stack, StringValue(x+y)), nil
+}
@@ -0,0 +1,441 @@
+package stackvm
+
+import (
+	"math"
+	"sort"
+)
+
+// OptimizeOptions selects the passes run by Optimize.
+type OptimizeOptions struct {
+	// FoldConstants replaces an arithmetic, logic, comparison, or math
+	// instruction whose operands are all pushed constants with a push of
+	// its result, so PUSHI 2, PUSHI 3, ADD becomes PUSH 5.
+	FoldConstants bool
+
+	// RemoveDeadCode removes instructions that cannot be reached from the
+	// entry point.
+	RemoveDeadCode bool
+
+	// Peephole removes NOPs, constants that are pushed and immediately
+	// popped, pairs of SWAPs, and jumps to the next instruction.
+	Peephole bool
+
+	// Config is the configuration the program will run under. Constant
+	// folding evaluates instructions with it, so folded results match what
+	// the VM would have computed.
+	Config Config
+}
+
+// Optimize returns a copy of program rewritten by the passes selected in
+// opts, which are repeated until none of them changes the program. Jump
+// and call targets, the entry point, and the symbol table are updated to
+// the new addresses. Instructions that a label or jump refers to are never
+// merged with the ones before them.
+//
+// Optimization renumbers instructions. Code addresses pushed as values,
+// such as @label operands, are not updated, so programs whose custom
+// instructions or trap handlers jump to computed addresses should not be
+// optimized. The program must pass ValidateProgram.
+func Optimize(program Program, opts OptimizeOptions) (Program, error) {
+	if err := ValidateProgram(program); err != nil {
+		return nil, err
+	}
+
+	o := newOptimizer(program, opts.Config)
+	for changed := true; changed; {
+		changed = false
+		if opts.FoldConstants && o.apply(o.fold) {
+			changed = true
+		}
+		if opts.Peephole && o.apply(o.peephole) {
+			changed = true
+		}
+		if opts.RemoveDeadCode && o.apply(o.removeDeadCode) {
+			changed = true
+		}
+	}
+
+	return o.program(program), nil
+}
+
+// program returns the rewritten program, with the metadata and data
+// segment of src.
+func (o *optimizer) program(src Program) Program {
+	out := NewProgramWithMetadata(o.insts, src.Metadata())
+	out.SetConstants(o.constants)
+	if ds, ok := src.(DataSegment); ok {
+		out.SetData(append([]Value(nil), ds.Data()...))
+	}
+	out.SetEntryPoint(o.entry)
+	out.SetSymbolTable(o.symbols)
+	return out
+}
+
+// CompileOptions configures Compile.
+type CompileOptions struct {
+	// Assembler configures assembly of the source.
+	Assembler AssemblerOptions
+
+	// Optimize selects the optimization passes run before encoding.
+	Optimize OptimizeOptions
+}
+
+// Compile assembles source, optimizes it with the passes selected in
+// opts, and encodes the result in the binary format read by
+// DecodeProgram. The encoding keeps the entry point, so a program with
+// .entry starts in the same place after decoding. It also returns the
+// assembler's warnings.
+func Compile(source string, opts CompileOptions) ([]byte, []Diagnostic, error) {
+	program, diagnostics, err := NewAssemblerWithOptions(opts.Assembler).AssembleWithDiagnostics(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	program, err = Optimize(program, opts.Optimize)
+	if err != nil {
+		return nil, diagnostics, err
+	}
+	data, err := EncodeProgram(program)
+	if err != nil {
+		return nil, diagnostics, err
+	}
+	return data, diagnostics, nil
+}
+
+// optimizer holds a program being rewritten. Each pass marks the
+// instructions it keeps in live and may replace kept instructions; apply
+// then drops the rest and renumbers the program.
+type optimizer struct {
+	insts     []Instruction
+	constants []Value
+	symbols   map[int]string
+	entry     int
+	vm        VM
+
+	live    []bool
+	targets []bool // addresses that a jump, label, or the entry point refers to
+}
+
+func newOptimizer(program Program, config Config) *optimizer {
+	o := &optimizer{
+		insts:   append([]Instruction(nil), program.Instructions()...),
+		symbols: make(map[int]string),
+	}
+	if pool, ok := program.(ConstantPool); ok {
+		o.constants = append([]Value(nil), pool.Constants()...)
+	}
+	for addr, name := range program.SymbolTable() {
+		if addr >= 0 && addr <= len(o.insts) {
+			o.symbols[addr] = name
+		}
+	}
+	if ep, ok := program.(EntryPointer); ok {
+		o.entry = ep.EntryPoint()
+	}
+
+	// Folding runs single instructions, so hooks that observe or replace
+	// execution are left out.
+	config.Middleware = nil
+	config.TrapHandlers = nil
+	config.InstructionRegistry = nil
+	o.vm = NewWithConfig(config)
+	return o
+}
+
+// apply runs pass over the program and compacts it. It reports whether
+// the pass changed anything.
+func (o *optimizer) apply(pass func() bool) bool {
+	n := len(o.insts)
+	o.live = make([]bool, n)
+	for i := range o.live {
+		o.live[i] = true
+	}
+	o.targets = make([]bool, n+1)
+	for pc, inst := range o.insts {
+		if target, ok := jumpTarget(inst, pc); ok {
+			o.targets[target] = true
+		}
+	}
+	for addr := range o.symbols {
+		o.targets[addr] = true
+	}
+	o.targets[o.entry] = true
+
+	if !pass() {
+		return false
+	}
+	o.compact()
+	return true
+}
+
+// compact drops the instructions that are not live. A reference to a
+// dropped instruction moves to the next live one.
+func (o *optimizer) compact() {
+	n := len(o.insts)
+	newAddr := make([]int, n+1)
+	k := 0
+	for addr := 0; addr < n; addr++ {
+		newAddr[addr] = k
+		if o.live[addr] {
+			k++
+		}
+	}
+	newAddr[n] = k
+
+	insts := make([]Instruction, 0, k)
+	for pc, inst := range o.insts {
+		if !o.live[pc] {
+			continue
+		}
+		if target, ok := jumpTarget(inst, pc); ok {
+			if isRelativeJump(inst.Opcode) {
+				inst.Operand = int32(newAddr[target] - newAddr[pc])
+			} else {
+				inst.Operand = int32(newAddr[target])
+			}
+		}
+		insts = append(insts, inst)
+	}
+
+	// Labels that end up at the same address keep the first one.
+	addrs := make([]int, 0, len(o.symbols))
+	for addr := range o.symbols {
+		addrs = append(addrs, addr)
+	}
+	sort.Ints(addrs)
+	symbols := make(map[int]string, len(o.symbols))
+	for _, addr := range addrs {
+		if _, exists := symbols[newAddr[addr]]; !exists {
+			symbols[newAddr[addr]] = o.symbols[addr]
+		}
+	}
+
+	o.insts = insts
+	o.symbols = symbols
+	o.entry = newAddr[o.entry]
+}
+
+// fold replaces pure instructions whose operands are constants with a push
+// of the result.
+func (o *optimizer) fold() bool {
+	changed := false
+	for pc, inst := range o.insts {
+		info, ok := inst.Opcode.Info()
+		if !ok || !isFoldable(inst.Opcode) || info.Pops < 1 || info.Pops > 2 || info.Pushes != 1 {
+			continue
+		}
+
+		args := make([]Value, info.Pops)
+		first := pc
+		for i := len(args) - 1; i >= 0 && first >= 0; i-- {
+			if first = o.prevLive(first); first >= 0 {
+				args[i], ok = o.constant(o.insts[first])
+				if !ok {
+					first = -1
+				}
+			}
+		}
+		if first < 0 || o.targeted(first, pc) {
+			continue
+		}
+
+		result, err := o.vm.Execute(NewProgram([]Instruction{inst}), NewSimpleMemory(0),
+			ExecuteOptions{InitialStack: args})
+		if err != nil || len(result.Stack) != 1 || !isFoldableType(result.Stack[0].Type) {
+			continue
+		}
+
+		for addr := first; addr < pc; addr++ {
+			o.live[addr] = false
+		}
+		o.insts[pc] = o.push(result.Stack[0])
+		changed = true
+	}
+	return changed
+}
+
+// peephole removes instructions and pairs of instructions that have no
+// effect.
+func (o *optimizer) peephole() bool {
+	changed := false
+	for pc, inst := range o.insts {
+		if !o.live[pc] {
+			continue
+		}
+		switch inst.Opcode {
+		case OpNOP:
+			o.live[pc] = false
+			changed = true
+		case OpJMP, OpJMPR:
+			target, _ := jumpTarget(inst, pc)
+			if o.nextLive(target) == o.nextLive(pc+1) {
+				o.live[pc] = false
+				changed = true
+			}
+		case OpPUSH, OpPUSHI, OpPUSHC, OpSWAP:
+			next := o.nextLive(pc + 1)
+			if next == len(o.insts) || o.targeted(pc, next) {
+				continue
+			}
+			pair := OpPOP
+			if inst.Opcode == OpSWAP {
+				pair = OpSWAP
+			}
+			if o.insts[next].Opcode == pair {
+				o.live[pc], o.live[next] = false, false
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// removeDeadCode drops the instructions that cannot be reached.
+func (o *optimizer) removeDeadCode() bool {
+	reach := reachable(o.insts, o.entry)
+	changed := false
+	for pc := range o.insts {
+		if !reach[pc] {
+			o.live[pc] = false
+			changed = true
+		}
+	}
+	return changed
+}
+
+// prevLive returns the address of the last live instruction before addr,
+// or -1.
+func (o *optimizer) prevLive(addr int) int {
+	for addr--; addr >= 0 && !o.live[addr]; addr-- {
+	}
+	return addr
+}
+
+// nextLive returns the address of the first live instruction at or after
+// addr, or the program length.
+func (o *optimizer) nextLive(addr int) int {
+	for addr < len(o.insts) && !o.live[addr] {
+		addr++
+	}
+	return addr
+}
+
+// targeted reports whether anything refers to an address in (from, to].
+func (o *optimizer) targeted(from, to int) bool {
+	for addr := from + 1; addr <= to; addr++ {
+		if o.targets[addr] {
+			return true
+		}
+	}
+	return false
+}
+
+// constant returns the value a push instruction pushes, if it is a
+// foldable constant.
+func (o *optimizer) constant(inst Instruction) (Value, bool) {
+	switch inst.Opcode {
+	case OpPUSH:
+		return FloatValue(float64(inst.Operand)), true
+	case OpPUSHI:
+		return IntValue(int64(inst.Operand)), true
+	case OpPUSHC:
+		if v := o.constants[inst.Operand]; isFoldableType(v.Type) {
+			return v, true
+		}
+	}
+	return Value{}, false
+}
+
+// push returns an instruction that pushes v, adding it to the constant
+// pool if no immediate form can hold it.
+func (o *optimizer) push(v Value) Instruction {
+	switch v.Type {
+	case TypeInt:
+		if n, _ := v.AsInt(); n >= math.MinInt32 && n <= math.MaxInt32 {
+			return NewInstruction(OpPUSHI, int32(n))
+		}
+	case TypeFloat:
+		f, _ := v.AsFloat()
+		if f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32 && !(f == 0 && math.Signbit(f)) {
+			return NewInstruction(OpPUSH, int32(f))
+		}
+	}
+	for i, c := range o.constants {
+		if identicalConstant(c, v) {
+			return NewInstruction(OpPUSHC, int32(i))
+		}
+	}
+	o.constants = append(o.constants, v)
+	return NewInstruction(OpPUSHC, int32(len(o.constants)-1))
+}
+
+// isFoldable reports whether an opcode computes its result from its
+// operands alone.
+func isFoldable(op Opcode) bool {
+	switch op.Category() {
+	case CategoryArithmetic, CategoryLogic, CategoryComparison, CategoryMath:
+		info, _ := op.Info()
+		return info.Operand == OperandNone
+	}
+	return false
+}
+
+// isFoldableType reports whether values of type t are folded.
+func isFoldableType(t ValueType) bool {
+	return t == TypeInt || t == TypeFloat || t == TypeBool
+}
+
+// jumpTarget returns the address a jump or call instruction at pc
+// transfers control to.
+func jumpTarget(inst Instruction, pc int) (int, bool) {
+	switch inst.Opcode {
+	case OpJMP, OpJMPZ, OpJMPNZ, OpCALL:
+		return int(inst.Operand), true
+	case OpJMPR, OpJMPZR, OpJMPNZR:
+		return pc + int(inst.Operand), true
+	}
+	return 0, false
+}
+
+// isRelativeJump reports whether op's operand is an offset from itself.
+func isRelativeJump(op Opcode) bool {
+	return op == OpJMPR || op == OpJMPZR || op == OpJMPNZR
+}
+
+// successors returns the addresses control can pass to after the
+// instruction at pc. Custom instructions and TRAPs are assumed to fall
+// through, and RET to return after some CALL.
+func successors(insts []Instruction, pc int) []int {
+	inst := insts[pc]
+	target, isJump := jumpTarget(inst, pc)
+	switch inst.Opcode {
+	case OpHALT, OpEXIT, OpRET:
+		return nil
+	case OpJMP, OpJMPR:
+		return []int{target}
+	}
+	if isJump {
+		return []int{pc + 1, target}
+	}
+	return []int{pc + 1}
+}
+
+// reachable marks the instructions that control can reach from entry.
+func reachable(insts []Instruction, entry int) []bool {
+	reach := make([]bool, len(insts))
+	if entry < 0 || entry >= len(insts) {
+		return reach
+	}
+	reach[entry] = true
+	work := []int{entry}
+	for len(work) > 0 {
+		pc := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, next := range successors(insts, pc) {
+			if next >= 0 && next < len(insts) && !reach[next] {
+				reach[next] = true
+				work = append(work, next)
+			}
+		}
+	}
+	return reach
+}
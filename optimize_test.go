@@ -0,0 +1,233 @@
+package stackvm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	all := OptimizeOptions{FoldConstants: true, RemoveDeadCode: true, Peephole: true}
+
+	tests := []struct {
+		name   string
+		source string
+		opts   OptimizeOptions
+		want   []Opcode
+	}{
+		{
+			"fold chain",
+			"PUSHI 2\nPUSHI 3\nADD\nPUSHI 4\nMUL\nHALT",
+			OptimizeOptions{FoldConstants: true},
+			[]Opcode{OpPUSH, OpHALT},
+		},
+		{
+			"fold stops at label",
+			"PUSHI 2\nx: PUSHI 3\nADD\nJMPZ x\nHALT",
+			OptimizeOptions{FoldConstants: true},
+			[]Opcode{OpPUSHI, OpPUSHI, OpADD, OpJMPZ, OpHALT},
+		},
+		{
+			"no fold of runtime value",
+			"LOAD 0\nPUSHI 1\nADD\nHALT",
+			OptimizeOptions{FoldConstants: true},
+			[]Opcode{OpLOAD, OpPUSHI, OpADD, OpHALT},
+		},
+		{
+			"no fold of division by zero",
+			"PUSHI 1\nPUSHI 0\nDIV\nHALT",
+			OptimizeOptions{FoldConstants: true},
+			[]Opcode{OpPUSHI, OpPUSHI, OpDIV, OpHALT},
+		},
+		{
+			"dead code",
+			"JMP end\nPUSHI 1\nPUSHI 2\nend: HALT\nPUSHI 3",
+			OptimizeOptions{RemoveDeadCode: true},
+			[]Opcode{OpJMP, OpHALT},
+		},
+		{
+			"peephole",
+			"NOP\nPUSHI 1\nPOP\nSWAP\nSWAP\nJMP next\nnext: HALT",
+			OptimizeOptions{Peephole: true},
+			[]Opcode{OpHALT},
+		},
+		{
+			"passes combine",
+			"PUSHI 1\nPUSHI 1\nEQ\nPOP\nJMP end\nNOP\nend: HALT",
+			all,
+			[]Opcode{OpHALT},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := MustAssemble(tt.source)
+			optimized, err := Optimize(program, tt.opts)
+			if err != nil {
+				t.Fatalf("Optimize() failed: %v", err)
+			}
+			insts := optimized.Instructions()
+			if len(insts) != len(tt.want) {
+				t.Fatalf("instructions = %v, want opcodes %v", insts, tt.want)
+			}
+			for i, op := range tt.want {
+				if insts[i].Opcode != op {
+					t.Errorf("instruction %d = %s, want %s", i, insts[i].Opcode, op)
+				}
+			}
+			if err := ValidateProgram(optimized); err != nil {
+				t.Errorf("optimized program is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestOptimizePreservesBehavior(t *testing.T) {
+	// A loop with foldable constants, relative jumps, a subroutine, and
+	// dead code, so every pass rewrites jump targets.
+	program := MustAssemble(`
+		.entry main
+		PUSHI 99        ; unreachable before the entry point
+	main:
+		PUSHI 0
+		STORE 0
+	loop:
+		NOP
+		CALL step
+		LOAD 0
+		PUSHI 4
+		PUSHI 5
+		MUL
+		LT
+		JMPNZ loop
+		LOAD 0
+		PUSHI 2
+		PUSHI 8
+		SUB
+		ADD
+		JMPR $+2
+		PUSHI 7
+		HALT
+	step:
+		LOAD 0
+		PUSHI 1
+		PUSHI 2
+		ADD
+		ADD
+		STORE 0
+		RET
+	`)
+
+	run := func(p Program) *Result {
+		result, err := New().Execute(p, NewSimpleMemory(1), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		return result
+	}
+
+	want := run(program)
+	optimized, err := Optimize(program, OptimizeOptions{FoldConstants: true, RemoveDeadCode: true, Peephole: true})
+	if err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+	got := run(optimized)
+
+	if len(got.Stack) != 1 || !got.Stack[0].Equal(want.Stack[0]) {
+		t.Errorf("Stack = %v, want %v", got.Stack, want.Stack)
+	}
+	if optimized.Len() >= program.Len() || got.InstructionCount >= want.InstructionCount {
+		t.Errorf("optimized program has %d instructions (%d run), want fewer than %d (%d run)",
+			optimized.Len(), got.InstructionCount, program.Len(), want.InstructionCount)
+	}
+	if symbols := optimized.SymbolTable(); symbols[optimized.(EntryPointer).EntryPoint()] != "main" {
+		t.Errorf("SymbolTable() = %v, want main at the entry point", symbols)
+	}
+}
+
+func TestOptimizeUsesConfig(t *testing.T) {
+	program := MustAssemble("PUSHI 1\nPUSHI 2\nLT\nHALT")
+	config := Config{ComparisonResult: ComparisonInt}
+	optimized, err := Optimize(program, OptimizeOptions{FoldConstants: true, Config: config})
+	if err != nil {
+		t.Fatalf("Optimize() failed: %v", err)
+	}
+	if inst, _ := optimized.InstructionAt(0); inst.Opcode != OpPUSHI || inst.Operand != 1 {
+		t.Errorf("folded LT = %s, want PUSHI 1", inst)
+	}
+}
+
+func TestOptimizeRejectsInvalidProgram(t *testing.T) {
+	program := NewProgram([]Instruction{NewInstruction(OpJMP, 5)})
+	if _, err := Optimize(program, OptimizeOptions{Peephole: true}); !errors.Is(err, ErrInvalidProgram) {
+		t.Errorf("Optimize() error = %v, want ErrInvalidProgram", err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	source := `
+		PUSHI 6
+		PUSHI 7
+		MUL
+		PUSHI 2
+		SUB
+		NOP
+		HALT
+	`
+	plain, _, err := Compile(source, CompileOptions{})
+	if err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+	optimized, diagnostics, err := Compile(source, CompileOptions{
+		Optimize: OptimizeOptions{FoldConstants: true, RemoveDeadCode: true, Peephole: true},
+	})
+	if err != nil {
+		t.Fatalf("Compile() with optimization failed: %v", err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("diagnostics = %v, want none", diagnostics)
+	}
+	if len(optimized) >= len(plain) {
+		t.Errorf("optimized encoding is %d bytes, want fewer than %d", len(optimized), len(plain))
+	}
+
+	var results []*Result
+	for _, data := range [][]byte{plain, optimized} {
+		program, err := DecodeProgram(data)
+		if err != nil {
+			t.Fatalf("DecodeProgram() failed: %v", err)
+		}
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		results = append(results, result)
+	}
+	if len(results[1].Stack) != 1 || !results[1].Stack[0].Equal(results[0].Stack[0]) {
+		t.Errorf("optimized Stack = %v, want %v", results[1].Stack, results[0].Stack)
+	}
+
+	if _, _, err := Compile("BOGUS", CompileOptions{}); err == nil {
+		t.Error("Compile() should fail for invalid source")
+	}
+}
+
+func TestCompileEntryPoint(t *testing.T) {
+	source := ".entry main\nsub: PUSHI 9\nHALT\nmain: PUSHI 1\nHALT"
+	for _, opts := range []OptimizeOptions{{}, {FoldConstants: true, RemoveDeadCode: true, Peephole: true}} {
+		data, _, err := Compile(source, CompileOptions{Optimize: opts})
+		if err != nil {
+			t.Fatalf("Compile(%+v) failed: %v", opts, err)
+		}
+		program, err := DecodeProgram(data)
+		if err != nil {
+			t.Fatalf("DecodeProgram() failed: %v", err)
+		}
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		if len(result.Stack) != 1 || !result.Stack[0].Equal(IntValue(1)) {
+			t.Errorf("Compile(%+v) Stack = %v, want [1]", opts, result.Stack)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package stackvm
 
 import (
+	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
 // VMPool manages a pool of reusable VM instances.
@@ -10,19 +13,13 @@ import (
 type VMPool struct {
 	pool   sync.Pool
 	config Config
+	idle   atomic.Int64 // VMs Put and not yet taken back; sync.Pool may drop some
 }
 
 // NewVMPool creates a new VM pool with the given configuration.
 // All VMs in the pool will be created with this configuration.
 func NewVMPool(config Config) *VMPool {
-	return &VMPool{
-		config: config,
-		pool: sync.Pool{
-			New: func() interface{} {
-				return NewWithConfig(config)
-			},
-		},
-	}
+	return &VMPool{config: config}
 }
 
 // NewDefaultVMPool creates a VM pool with default configuration.
@@ -36,11 +33,23 @@ func NewDefaultVMPool() *VMPool {
 // The VM is reset before being returned.
 // The caller must call Put() when done with the VM.
 func (p *VMPool) Get() VM {
-	vm := p.pool.Get().(VM)
+	vm := p.take()
+	if vm == nil {
+		vm = NewWithConfig(p.config)
+	}
 	vm.Reset()
 	return vm
 }
 
+// take removes an idle VM from the pool, or returns nil if it has none.
+func (p *VMPool) take() VM {
+	vm, _ := p.pool.Get().(VM)
+	if vm != nil {
+		p.idle.Add(-1)
+	}
+	return vm
+}
+
 // Put returns a VM to the pool.
 // The VM is reset before being added back to the pool.
 func (p *VMPool) Put(vm VM) {
@@ -48,18 +57,45 @@ func (p *VMPool) Put(vm VM) {
 		return
 	}
 	vm.Reset()
+	p.idle.Add(1)
 	p.pool.Put(vm)
 }
 
+// Drain resets and drops the idle VMs held by the pool so their memory can
+// be reclaimed. VMs that are checked out are unaffected and may still be
+// Put back. The pool remains usable afterwards.
+func (p *VMPool) Drain() {
+	// Take at most the VMs idle when Drain started, so goroutines that
+	// keep Putting VMs can't keep it running.
+	for n := p.idle.Load(); n > 0; n-- {
+		vm := p.take()
+		if vm == nil {
+			return
+		}
+		vm.Reset()
+	}
+}
+
 // Execute is a convenience method that gets a VM from the pool,
 // executes the program, and returns the VM to the pool.
 // This is safe for concurrent use.
+// Only the VM is reset; memory is used as-is, so state written by one
+// execution is visible to the next one given the same Memory.
 func (p *VMPool) Execute(program Program, memory Memory, opts ExecuteOptions) (*Result, error) {
 	vm := p.Get()
 	defer p.Put(vm)
 	return vm.Execute(program, memory, opts)
 }
 
+// ExecuteFresh is like Execute but runs the program against a newly
+// allocated SimpleMemory of memorySize slots, so no state is shared with
+// other executions. The memory is returned for inspection.
+func (p *VMPool) ExecuteFresh(program Program, memorySize int, opts ExecuteOptions) (*Result, *SimpleMemory, error) {
+	memory := NewSimpleMemory(memorySize)
+	result, err := p.Execute(program, memory, opts)
+	return result, memory, err
+}
+
 // ExecuteFunc executes a function with a VM from the pool.
 // The VM is automatically returned to the pool when the function completes.
 // This is useful for more complex execution scenarios.
@@ -68,3 +104,143 @@ func (p *VMPool) ExecuteFunc(fn func(VM) error) error {
 	defer p.Put(vm)
 	return fn(vm)
 }
+
+// ExecuteBatch executes many programs against copies of the same memory.
+// Work is spread across GOMAXPROCS pooled VMs. Each program runs with its own
+// clone of memory, so executions do not interfere with each other or with the
+// original. Results and errors are returned in program order.
+// The memory must implement CloneableMemory.
+func (p *VMPool) ExecuteBatch(programs []Program, memory Memory, opts ExecuteOptions) ([]*Result, []error) {
+	results := make([]*Result, len(programs))
+	errs := make([]error, len(programs))
+
+	cloneable, ok := memory.(CloneableMemory)
+	if !ok {
+		err := fmt.Errorf("ExecuteBatch: memory of type %T does not implement CloneableMemory", memory)
+		for i := range errs {
+			errs[i] = err
+		}
+		return results, errs
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(programs) {
+		workers = len(programs)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vm := p.Get()
+			defer p.Put(vm)
+			for i := range jobs {
+				results[i], errs[i] = vm.Execute(programs[i], cloneable.Clone(), opts)
+			}
+		}()
+	}
+
+	for i := range programs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, errs
+}
+
+// BoundedVMPool is a pool holding a fixed number of VMs. Get blocks while
+// all of them are in use, which caps the number of concurrent executions.
+type BoundedVMPool struct {
+	vms    chan VM
+	done   chan struct{}
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewBoundedVMPool creates a pool of size VMs with the given configuration.
+// A size below 1 is treated as 1.
+func NewBoundedVMPool(config Config, size int) *BoundedVMPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &BoundedVMPool{
+		vms:  make(chan VM, size),
+		done: make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		p.vms <- NewWithConfig(config)
+	}
+	return p
+}
+
+// Get retrieves a VM from the pool, waiting until one is free.
+// The VM is reset before being returned. The caller must call Put() when
+// done with the VM. Returns ErrPoolClosed once the pool has been closed.
+func (p *BoundedVMPool) Get() (VM, error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case vm := <-p.vms:
+		select {
+		case <-p.done:
+			// Close won the race; drop the VM.
+			return nil, ErrPoolClosed
+		default:
+		}
+		vm.Reset()
+		return vm, nil
+	}
+}
+
+// Put returns a VM to the pool. After Close the VM is discarded.
+func (p *BoundedVMPool) Put(vm VM) {
+	if vm == nil {
+		return
+	}
+	vm.Reset()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.vms <- vm:
+	default:
+		// The pool is full; vm did not come from it.
+	}
+}
+
+// Execute gets a VM from the pool, executes the program, and returns the
+// VM to the pool. Returns ErrPoolClosed once the pool has been closed.
+func (p *BoundedVMPool) Execute(program Program, memory Memory, opts ExecuteOptions) (*Result, error) {
+	vm, err := p.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer p.Put(vm)
+	return vm.Execute(program, memory, opts)
+}
+
+// Close shuts the pool down. Waiting and later calls to Get return
+// ErrPoolClosed, idle VMs are released, and VMs still in use are discarded
+// when they are Put back. Close is safe to call more than once.
+func (p *BoundedVMPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.done)
+	for {
+		select {
+		case <-p.vms:
+		default:
+			return
+		}
+	}
+}
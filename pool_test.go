@@ -1,8 +1,10 @@
 package stackvm
 
 import (
+	"errors"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewVMPool(t *testing.T) {
@@ -228,6 +230,67 @@ func TestVMPoolPutNil(t *testing.T) {
 	pool.Put(nil)
 }
 
+func TestVMPoolExecuteBatch(t *testing.T) {
+	pool := NewDefaultVMPool()
+
+	// Each program checks that memory[0] still holds its preloaded value,
+	// overwrites it, and leaves i+1 values on the stack. If executions shared
+	// memory, later programs would see another program's write and bail out
+	// with an empty stack.
+	const count = 50
+	programs := make([]Program, count)
+	for i := 0; i < count; i++ {
+		b := NewProgramBuilder().
+			Load(0).
+			PushInt(5).
+			Eq().
+			JmpZ("fail").
+			PushInt(int64(100 + i)).
+			Store(0)
+		for j := 0; j <= i; j++ {
+			b.PushInt(int64(j))
+		}
+		program, err := b.Halt().Label("fail").Halt().Build()
+		if err != nil {
+			t.Fatalf("Build() failed: %v", err)
+		}
+		programs[i] = program
+	}
+
+	memory := NewSimpleMemory(1)
+	memory.Store(0, IntValue(5))
+
+	results, errs := pool.ExecuteBatch(programs, memory, ExecuteOptions{})
+	if len(results) != count || len(errs) != count {
+		t.Fatalf("got %d results and %d errors, want %d each", len(results), len(errs), count)
+	}
+
+	for i := 0; i < count; i++ {
+		if errs[i] != nil {
+			t.Errorf("program %d: error = %v", i, errs[i])
+			continue
+		}
+		if results[i].StackDepth != i+1 {
+			t.Errorf("program %d: StackDepth = %d, want %d", i, results[i].StackDepth, i+1)
+		}
+	}
+
+	val, _ := memory.Load(0)
+	if !val.Equal(IntValue(5)) {
+		t.Errorf("original memory[0] = %v, want 5", val)
+	}
+}
+
+func TestVMPoolExecuteBatchRequiresCloneableMemory(t *testing.T) {
+	pool := NewDefaultVMPool()
+	programs := []Program{NewProgram([]Instruction{NewInstruction(OpHALT, 0)})}
+
+	_, errs := pool.ExecuteBatch(programs, &mapMemory{data: make(map[int]Value)}, ExecuteOptions{})
+	if errs[0] == nil {
+		t.Error("ExecuteBatch() should fail for memory without Clone")
+	}
+}
+
 func BenchmarkVMPoolGet(b *testing.B) {
 	pool := NewDefaultVMPool()
 
@@ -290,3 +353,215 @@ func BenchmarkVMPoolParallel(b *testing.B) {
 		}
 	})
 }
+
+func TestVMPoolExecuteFresh(t *testing.T) {
+	pool := NewDefaultVMPool()
+
+	// writer leaves a value behind; reader pushes whatever is in slot 0.
+	writer := mustBuild(t, NewProgramBuilder().PushInt(99).Store(0).Halt())
+	reader := mustBuild(t, NewProgramBuilder().Load(0).Halt())
+
+	t.Run("shared memory leaks", func(t *testing.T) {
+		memory := NewSimpleMemory(1)
+		if _, err := pool.Execute(writer, memory, ExecuteOptions{}); err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		result, err := pool.Execute(reader, memory, ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		if !result.Stack[0].Equal(IntValue(99)) {
+			t.Errorf("reader saw %v, want 99 from the writer", result.Stack[0])
+		}
+	})
+
+	t.Run("fresh memory is isolated", func(t *testing.T) {
+		_, memory, err := pool.ExecuteFresh(writer, 1, ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("ExecuteFresh() failed: %v", err)
+		}
+		if val, _ := memory.Load(0); !val.Equal(IntValue(99)) {
+			t.Errorf("writer memory[0] = %v, want 99", val)
+		}
+		result, _, err := pool.ExecuteFresh(reader, 1, ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("ExecuteFresh() failed: %v", err)
+		}
+		if !result.Stack[0].IsNil() {
+			t.Errorf("reader saw %v, want nil", result.Stack[0])
+		}
+	})
+}
+
+func TestVMPoolDrain(t *testing.T) {
+	pool := NewDefaultVMPool()
+	for i := 0; i < 4; i++ {
+		pool.Put(NewWithConfig(pool.config))
+	}
+
+	pool.Drain()
+	if vm := pool.take(); vm != nil {
+		t.Error("pool still holds a VM after Drain()")
+	}
+
+	// The pool still works after draining.
+	program, _ := NewProgramBuilder().PushInt(1).Halt().Build()
+	if _, err := pool.Execute(program, NewSimpleMemory(0), ExecuteOptions{}); err != nil {
+		t.Fatalf("Execute() after Drain() failed: %v", err)
+	}
+
+	// Drain returns even while other goroutines keep returning VMs.
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				pool.Put(pool.Get())
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		pool.Drain()
+	}
+	close(stop)
+	<-done
+}
+
+func TestBoundedVMPool(t *testing.T) {
+	pool := NewBoundedVMPool(Config{StackSize: 16}, 2)
+	defer pool.Close()
+
+	a, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	b, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+
+	// A third Get waits until a VM is returned.
+	got := make(chan VM)
+	go func() {
+		vm, _ := pool.Get()
+		got <- vm
+	}()
+	select {
+	case <-got:
+		t.Fatal("Get() returned while all VMs were in use")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	pool.Put(a)
+	select {
+	case vm := <-got:
+		if vm == nil {
+			t.Error("Get() returned nil after Put()")
+		}
+		pool.Put(vm)
+	case <-time.After(time.Second):
+		t.Fatal("Get() did not return after Put()")
+	}
+	pool.Put(b)
+
+	program, _ := NewProgramBuilder().PushInt(1).Halt().Build()
+	if _, err := pool.Execute(program, NewSimpleMemory(0), ExecuteOptions{}); err != nil {
+		t.Errorf("Execute() failed: %v", err)
+	}
+}
+
+func TestBoundedVMPoolClose(t *testing.T) {
+	pool := NewBoundedVMPool(Config{StackSize: 16}, 1)
+
+	inUse, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+
+	// A Get waiting for a VM is released by Close.
+	waiting := make(chan error)
+	go func() {
+		_, err := pool.Get()
+		waiting <- err
+	}()
+
+	pool.Close()
+	pool.Close() // closing twice is harmless
+
+	select {
+	case err := <-waiting:
+		if !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("waiting Get() error = %v, want ErrPoolClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting Get() was not released by Close()")
+	}
+
+	// In-flight VMs can still be returned; they are discarded.
+	pool.Put(inUse)
+
+	if vm, err := pool.Get(); !errors.Is(err, ErrPoolClosed) || vm != nil {
+		t.Errorf("Get() after Close() = %v, %v, want nil, ErrPoolClosed", vm, err)
+	}
+	program, _ := NewProgramBuilder().Halt().Build()
+	if _, err := pool.Execute(program, NewSimpleMemory(0), ExecuteOptions{}); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Execute() after Close() error = %v, want ErrPoolClosed", err)
+	}
+}
+
+// memTraceProgram stores to n distinct addresses.
+func memTraceProgram(t testing.TB, n int) Program {
+	builder := NewProgramBuilder()
+	for i := 0; i < n; i++ {
+		builder.PushInt(int64(i)).PushInt(1).StoreD()
+	}
+	program, err := builder.Halt().Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	return program
+}
+
+func TestVMPoolReusesMemTraceStorage(t *testing.T) {
+	// Pooled VMs are reused VMs, so measure one directly: the race
+	// detector makes sync.Pool drop returned items at random, which
+	// would hand the pool fresh VMs.
+	vm := New()
+	opts := ExecuteOptions{MemTrace: true}
+
+	allocs := func(n int) float64 {
+		program := memTraceProgram(t, n)
+		memory := NewSimpleMemory(n)
+		return testing.AllocsPerRun(20, func() {
+			if _, err := vm.Execute(program, memory, opts); err != nil {
+				t.Fatalf("Execute() failed: %v", err)
+			}
+		})
+	}
+
+	// Once the VM's trace maps have grown, tracing more addresses costs
+	// only the larger result slices.
+	small, large := allocs(1), allocs(200)
+	if large > small {
+		t.Errorf("allocs per run = %v tracing 200 addresses, %v tracing 1", large, small)
+	}
+}
+
+func BenchmarkVMPoolExecuteMemTrace(b *testing.B) {
+	pool := NewDefaultVMPool()
+	program := memTraceProgram(b, 200)
+	memory := NewSimpleMemory(200)
+	opts := ExecuteOptions{MemTrace: true}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pool.Execute(program, memory, opts); err != nil {
+			b.Fatalf("Execute() failed: %v", err)
+		}
+	}
+}
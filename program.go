@@ -5,8 +5,17 @@ import "time"
 // Program represents a sequence of instructions that can be executed by the VM.
 type Program interface {
 	// Instructions returns the instruction sequence.
+	// Callers must not modify the returned slice; implementations may
+	// return a copy.
 	Instructions() []Instruction
 
+	// InstructionAt returns the instruction at index i.
+	// Returns false if i is out of range.
+	InstructionAt(i int) (Instruction, bool)
+
+	// Len returns the number of instructions.
+	Len() int
+
 	// SymbolTable returns the address to label mapping for debugging.
 	// May return nil if no debug information is available.
 	SymbolTable() map[int]string
@@ -15,6 +24,29 @@ type Program interface {
 	Metadata() ProgramMetadata
 }
 
+// ConstantPool is implemented by programs that carry a constant pool.
+// PUSHC instructions push the pool entry selected by their operand.
+type ConstantPool interface {
+	// Constants returns the program's constant pool.
+	Constants() []Value
+}
+
+// DataSegment is implemented by programs that carry initial memory
+// contents. Before the first instruction runs, the VM stores the nth value
+// at memory address n.
+type DataSegment interface {
+	// Data returns the initial values of memory addresses 0 through
+	// len(Data())-1.
+	Data() []Value
+}
+
+// EntryPointer is implemented by programs that begin execution somewhere
+// other than instruction 0.
+type EntryPointer interface {
+	// EntryPoint returns the address of the first instruction to execute.
+	EntryPoint() int
+}
+
 // ProgramMetadata contains information about a program.
 type ProgramMetadata struct {
 	Name        string
@@ -28,30 +60,54 @@ type ProgramMetadata struct {
 type SimpleProgram struct {
 	instructions []Instruction
 	symbols      map[int]string
+	constants    []Value
+	data         []Value
+	entry        int
 	metadata     ProgramMetadata
 }
 
 // NewProgram creates a new SimpleProgram with the given instructions.
+// The instruction slice is copied, so later changes to it by the caller do
+// not affect the program.
 func NewProgram(instructions []Instruction) *SimpleProgram {
 	return &SimpleProgram{
-		instructions: instructions,
+		instructions: copyInstructions(instructions),
 		symbols:      nil,
 		metadata:     ProgramMetadata{},
 	}
 }
 
 // NewProgramWithMetadata creates a new SimpleProgram with instructions and metadata.
+// The instruction slice is copied, as with NewProgram. A zero Created time
+// defaults to the current time.
 func NewProgramWithMetadata(instructions []Instruction, metadata ProgramMetadata) *SimpleProgram {
+	if metadata.Created.IsZero() {
+		metadata.Created = time.Now()
+	}
 	return &SimpleProgram{
-		instructions: instructions,
+		instructions: copyInstructions(instructions),
 		symbols:      nil,
 		metadata:     metadata,
 	}
 }
 
-// Instructions returns the instruction sequence.
+// Instructions returns a copy of the instruction sequence.
+// Modifying the returned slice does not affect the program.
 func (p *SimpleProgram) Instructions() []Instruction {
-	return p.instructions
+	return copyInstructions(p.instructions)
+}
+
+// InstructionAt returns the instruction at index i.
+func (p *SimpleProgram) InstructionAt(i int) (Instruction, bool) {
+	if i < 0 || i >= len(p.instructions) {
+		return Instruction{}, false
+	}
+	return p.instructions[i], true
+}
+
+// Len returns the number of instructions.
+func (p *SimpleProgram) Len() int {
+	return len(p.instructions)
 }
 
 // SymbolTable returns the address to label mapping.
@@ -64,6 +120,42 @@ func (p *SimpleProgram) Metadata() ProgramMetadata {
 	return p.metadata
 }
 
+// Constants returns the constant pool.
+func (p *SimpleProgram) Constants() []Value {
+	return p.constants
+}
+
+// SetConstants sets the constant pool for the program.
+func (p *SimpleProgram) SetConstants(constants []Value) {
+	p.constants = constants
+}
+
+// AddConstant appends a value to the constant pool and returns its index.
+func (p *SimpleProgram) AddConstant(value Value) int {
+	p.constants = append(p.constants, value)
+	return len(p.constants) - 1
+}
+
+// Data returns the data segment.
+func (p *SimpleProgram) Data() []Value {
+	return p.data
+}
+
+// SetData sets the data segment for the program.
+func (p *SimpleProgram) SetData(data []Value) {
+	p.data = data
+}
+
+// EntryPoint returns the address execution starts at (0 by default).
+func (p *SimpleProgram) EntryPoint() int {
+	return p.entry
+}
+
+// SetEntryPoint sets the address execution starts at.
+func (p *SimpleProgram) SetEntryPoint(addr int) {
+	p.entry = addr
+}
+
 // SetSymbolTable sets the symbol table for the program.
 func (p *SimpleProgram) SetSymbolTable(symbols map[int]string) {
 	p.symbols = symbols
@@ -76,3 +168,10 @@ func (p *SimpleProgram) AddSymbol(address int, label string) {
 	}
 	p.symbols[address] = label
 }
+
+// copyInstructions returns a copy of the instruction slice.
+func copyInstructions(instructions []Instruction) []Instruction {
+	result := make([]Instruction, len(instructions))
+	copy(result, instructions)
+	return result
+}
@@ -0,0 +1,130 @@
+package stackvm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProgramsEqual reports whether two programs have the same instructions,
+// symbol tables, constant pools, data segments, entry points, and metadata. The Created
+// timestamp is ignored, since it records when a program was built.
+func ProgramsEqual(a, b Program) bool {
+	return ProgramDiff(a, b) == ""
+}
+
+// ProgramDiff returns a readable description of how two programs differ,
+// or an empty string if they are equal. Differing instructions are listed
+// by address, with "-" lines from a and "+" lines from b.
+func ProgramDiff(a, b Program) string {
+	var sb strings.Builder
+
+	// Instructions
+	n := a.Len()
+	if b.Len() > n {
+		n = b.Len()
+	}
+	for i := 0; i < n; i++ {
+		instA, okA := a.InstructionAt(i)
+		instB, okB := b.InstructionAt(i)
+		if okA && okB && instA == instB {
+			continue
+		}
+		if okA {
+			fmt.Fprintf(&sb, "-%04d: %s\n", i, instA)
+		}
+		if okB {
+			fmt.Fprintf(&sb, "+%04d: %s\n", i, instB)
+		}
+	}
+
+	// Symbols
+	symA, symB := a.SymbolTable(), b.SymbolTable()
+	for _, addr := range symbolAddrs(symA, symB) {
+		nameA, okA := symA[addr]
+		nameB, okB := symB[addr]
+		if okA == okB && nameA == nameB {
+			continue
+		}
+		if okA {
+			fmt.Fprintf(&sb, "-symbol %04d: %s\n", addr, nameA)
+		}
+		if okB {
+			fmt.Fprintf(&sb, "+symbol %04d: %s\n", addr, nameB)
+		}
+	}
+
+	// Constants and data
+	diffValues(&sb, "constant", programConstants(a), programConstants(b))
+	diffValues(&sb, "data", programData(a), programData(b))
+
+	// Entry point
+	if entryA, entryB := programEntry(a), programEntry(b); entryA != entryB {
+		fmt.Fprintf(&sb, "-entry: %d\n+entry: %d\n", entryA, entryB)
+	}
+
+	// Metadata
+	metaA, metaB := a.Metadata(), b.Metadata()
+	metaA.Created, metaB.Created = time.Time{}, time.Time{}
+	if metaA != metaB {
+		fmt.Fprintf(&sb, "-metadata: %+v\n+metadata: %+v\n", metaA, metaB)
+	}
+
+	return sb.String()
+}
+
+// diffValues writes the entries that differ between two value lists, such
+// as constant pools, labelling each line with kind and its index.
+func diffValues(sb *strings.Builder, kind string, a, b []Value) {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if i < len(a) && i < len(b) && a[i].Type == b[i].Type && a[i].Equal(b[i]) {
+			continue
+		}
+		if i < len(a) {
+			fmt.Fprintf(sb, "-%s %d: %s\n", kind, i, a[i])
+		}
+		if i < len(b) {
+			fmt.Fprintf(sb, "+%s %d: %s\n", kind, i, b[i])
+		}
+	}
+}
+
+// symbolAddrs returns the union of addresses in two symbol tables, sorted.
+func symbolAddrs(a, b map[int]string) []int {
+	seen := make(map[int]struct{}, len(a)+len(b))
+	for addr := range a {
+		seen[addr] = struct{}{}
+	}
+	for addr := range b {
+		seen[addr] = struct{}{}
+	}
+	return sortedAddrs(seen)
+}
+
+// programConstants returns a program's constant pool, if it has one.
+func programConstants(p Program) []Value {
+	if pool, ok := p.(ConstantPool); ok {
+		return pool.Constants()
+	}
+	return nil
+}
+
+// programData returns a program's data segment, if it has one.
+func programData(p Program) []Value {
+	if seg, ok := p.(DataSegment); ok {
+		return seg.Data()
+	}
+	return nil
+}
+
+// programEntry returns a program's entry point (0 if it has none).
+func programEntry(p Program) int {
+	if ep, ok := p.(EntryPointer); ok {
+		return ep.EntryPoint()
+	}
+	return 0
+}
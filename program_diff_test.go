@@ -0,0 +1,61 @@
+package stackvm
+
+import "testing"
+
+func TestProgramsEqual(t *testing.T) {
+	build := func(operand int64) Program {
+		program, err := NewProgramBuilder().
+			Label("start").
+			PushInt(operand).
+			Push(1.5).
+			Halt().
+			Build()
+		if err != nil {
+			t.Fatalf("Build() failed: %v", err)
+		}
+		return program
+	}
+
+	t.Run("equal programs", func(t *testing.T) {
+		a, b := build(1), build(1)
+		if !ProgramsEqual(a, b) {
+			t.Errorf("ProgramsEqual() = false, diff:\n%s", ProgramDiff(a, b))
+		}
+		if diff := ProgramDiff(a, b); diff != "" {
+			t.Errorf("ProgramDiff() = %q, want empty", diff)
+		}
+	})
+
+	t.Run("differing operand", func(t *testing.T) {
+		a, b := build(1), build(2)
+		if ProgramsEqual(a, b) {
+			t.Error("ProgramsEqual() = true, want false")
+		}
+		want := "-0000: PUSHI 1\n+0000: PUSHI 2\n"
+		if diff := ProgramDiff(a, b); diff != want {
+			t.Errorf("ProgramDiff() = %q, want %q", diff, want)
+		}
+	})
+
+	t.Run("differing symbols", func(t *testing.T) {
+		a, b := build(1), build(1)
+		b.(*SimpleProgram).AddSymbol(2, "end")
+		b.(*SimpleProgram).AddSymbol(0, "begin")
+		if ProgramsEqual(a, b) {
+			t.Error("ProgramsEqual() = true, want false")
+		}
+		want := "-symbol 0000: start\n+symbol 0000: begin\n+symbol 0002: end\n"
+		if diff := ProgramDiff(a, b); diff != want {
+			t.Errorf("ProgramDiff() = %q, want %q", diff, want)
+		}
+	})
+
+	t.Run("differing length", func(t *testing.T) {
+		a := NewProgram([]Instruction{NewInstruction(OpHALT, 0)})
+		b := NewProgram(nil)
+		want := "-0000: HALT\n"
+		if diff := ProgramDiff(a, b); diff != want {
+			t.Errorf("ProgramDiff() = %q, want %q", diff, want)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package stackvm
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -36,6 +37,29 @@ func (r *instructionRegistry) Register(opcode Opcode, handler InstructionHandler
 	return nil
 }
 
+// RegisterMany adds the same handler for each of opcodes. Every opcode is
+// checked before any is registered, so on error the registry is unchanged.
+func (r *instructionRegistry) RegisterMany(opcodes []Opcode, handler InstructionHandler) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[Opcode]bool, len(opcodes))
+	for _, opcode := range opcodes {
+		if opcode < 128 {
+			return fmt.Errorf("cannot register standard opcode %d: reserved for built-in instructions", opcode)
+		}
+		if _, exists := r.handlers[opcode]; exists || seen[opcode] {
+			return fmt.Errorf("opcode %d already registered", opcode)
+		}
+		seen[opcode] = true
+	}
+
+	for _, opcode := range opcodes {
+		r.handlers[opcode] = handler
+	}
+	return nil
+}
+
 // Unregister removes a handler for an opcode.
 // Returns an error if the opcode is not registered.
 func (r *instructionRegistry) Unregister(opcode Opcode) error {
@@ -60,7 +84,7 @@ func (r *instructionRegistry) Get(opcode Opcode) (InstructionHandler, bool) {
 	return handler, exists
 }
 
-// List returns all registered custom opcodes.
+// List returns all registered custom opcodes in ascending order.
 func (r *instructionRegistry) List() []Opcode {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -69,6 +93,7 @@ func (r *instructionRegistry) List() []Opcode {
 	for opcode := range r.handlers {
 		opcodes = append(opcodes, opcode)
 	}
+	sort.Slice(opcodes, func(i, j int) bool { return opcodes[i] < opcodes[j] })
 	return opcodes
 }
 
@@ -103,42 +128,36 @@ func newExecutionContext(vm *executor, memory Memory) *executionContextImpl {
 func (ctx *executionContextImpl) Push(value Value) error {
 	// Get the max stack depth from the VM config
 	maxDepth := ctx.vm.config.StackSize
-	if len(ctx.vm.stack) >= maxDepth {
-		return ErrStackOverflow
+	if depth := ctx.vm.stack.Depth(); depth >= maxDepth {
+		return &StackOverflowError{Limit: maxDepth, Attempted: depth + 1}
 	}
-	ctx.vm.stack = append(ctx.vm.stack, value)
-	return nil
+	if err := ctx.vm.account(value); err != nil {
+		return err
+	}
+	if err := ctx.vm.holdBytes(value); err != nil {
+		return err
+	}
+	return ctx.vm.stack.Push(value)
 }
 
 // Pop removes and returns the value from the top of the stack.
 func (ctx *executionContextImpl) Pop() (Value, error) {
-	if len(ctx.vm.stack) == 0 {
-		return NilValue(), ErrStackUnderflow
-	}
-	val := ctx.vm.stack[len(ctx.vm.stack)-1]
-	ctx.vm.stack = ctx.vm.stack[:len(ctx.vm.stack)-1]
-	return val, nil
+	return ctx.vm.pop()
 }
 
 // Peek returns the value at the top of the stack without removing it.
 func (ctx *executionContextImpl) Peek() (Value, error) {
-	if len(ctx.vm.stack) == 0 {
-		return NilValue(), ErrStackUnderflow
-	}
-	return ctx.vm.stack[len(ctx.vm.stack)-1], nil
+	return ctx.vm.stack.Peek(0)
 }
 
 // PeekN returns the nth value from the top of the stack (0 = top).
 func (ctx *executionContextImpl) PeekN(n int) (Value, error) {
-	if n < 0 || n >= len(ctx.vm.stack) {
-		return NilValue(), ErrStackUnderflow
-	}
-	return ctx.vm.stack[len(ctx.vm.stack)-1-n], nil
+	return ctx.vm.stack.Peek(n)
 }
 
 // StackDepth returns the current number of values on the stack.
 func (ctx *executionContextImpl) StackDepth() int {
-	return len(ctx.vm.stack)
+	return ctx.vm.stack.Depth()
 }
 
 // PC returns the current program counter value.
@@ -156,6 +175,21 @@ func (ctx *executionContextImpl) Jump(offset int) {
 	ctx.vm.pc = offset
 }
 
+// CallDepth returns the number of return addresses on the call stack.
+func (ctx *executionContextImpl) CallDepth() int {
+	return len(ctx.vm.calls)
+}
+
+// PushCall pushes a return address onto the call stack.
+func (ctx *executionContextImpl) PushCall(addr int) error {
+	return ctx.vm.pushCall(addr)
+}
+
+// PopCall removes and returns the most recent return address.
+func (ctx *executionContextImpl) PopCall() (int, error) {
+	return ctx.vm.popCall()
+}
+
 // Memory returns the memory provider associated with this execution.
 func (ctx *executionContextImpl) Memory() Memory {
 	return ctx.memory
@@ -1,6 +1,9 @@
 package stackvm
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -97,6 +100,69 @@ func TestRegisterDuplicateOpcode(t *testing.T) {
 	}
 }
 
+func TestRegisterMany(t *testing.T) {
+	registry := NewInstructionRegistry()
+	handler := &mockHandler{
+		name: "PUSHOP",
+		fn: func(ctx ExecutionContext, operand int32) error {
+			return ctx.Push(IntValue(int64(operand)))
+		},
+	}
+
+	if err := registry.RegisterMany([]Opcode{128, 129, 130}, handler); err != nil {
+		t.Fatalf("RegisterMany failed: %v", err)
+	}
+
+	vm := NewWithConfig(Config{StackSize: 256, InstructionRegistry: registry})
+	program := NewProgram([]Instruction{
+		NewInstruction(128, 1),
+		NewInstruction(129, 2),
+		NewInstruction(130, 3),
+		NewInstruction(OpHALT, 0),
+	})
+	result, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	for i, want := range []int64{1, 2, 3} {
+		if !result.Stack[i].Equal(IntValue(want)) {
+			t.Errorf("Stack[%d] = %v, want %d", i, result.Stack[i], want)
+		}
+	}
+}
+
+func TestRegisterManyRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		opcodes []Opcode
+	}{
+		{"already registered", []Opcode{140, 141, 150}},
+		{"standard opcode", []Opcode{140, 141, 10}},
+		{"repeated in list", []Opcode{140, 141, 140}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewInstructionRegistry()
+			existing := &mockHandler{name: "EXISTING"}
+			if err := registry.Register(150, existing); err != nil {
+				t.Fatalf("Register failed: %v", err)
+			}
+
+			if err := registry.RegisterMany(tt.opcodes, &mockHandler{name: "NEW"}); err == nil {
+				t.Fatal("RegisterMany should fail")
+			}
+
+			if list := registry.List(); len(list) != 1 || list[0] != 150 {
+				t.Errorf("List() = %v, want only 150", list)
+			}
+			if h, _ := registry.Get(150); h != existing {
+				t.Error("existing handler for 150 was replaced")
+			}
+		})
+	}
+}
+
 func TestUnregister(t *testing.T) {
 	registry := NewInstructionRegistry()
 	handler := &mockHandler{name: "TEMP"}
@@ -151,6 +217,46 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListSorted(t *testing.T) {
+	registry := NewInstructionRegistry()
+	for _, op := range []Opcode{200, 131, 255, 128, 170} {
+		if err := registry.Register(op, &mockHandler{name: fmt.Sprintf("OP%d", op)}); err != nil {
+			t.Fatalf("Register(%d) failed: %v", op, err)
+		}
+	}
+
+	want := []Opcode{128, 131, 170, 200, 255}
+	for run := 0; run < 10; run++ {
+		got := registry.List()
+		if len(got) != len(want) {
+			t.Fatalf("List() = %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("List() = %v, want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestAssembleDuplicateCustomNames(t *testing.T) {
+	registry := NewInstructionRegistry()
+	registry.Register(130, &mockHandler{name: "DUPX"})
+	registry.Register(135, &mockHandler{name: "DUPX"})
+
+	asm := NewAssembler()
+	asm.SetRegistry(registry)
+	for run := 0; run < 10; run++ {
+		program, err := asm.Assemble("DUPX 1")
+		if err != nil {
+			t.Fatalf("Assemble() failed: %v", err)
+		}
+		if inst, _ := program.InstructionAt(0); inst.Opcode != 130 {
+			t.Fatalf("DUPX assembled to opcode %d, want 130 (the lower opcode)", inst.Opcode)
+		}
+	}
+}
+
 func TestNames(t *testing.T) {
 	registry := NewInstructionRegistry()
 
@@ -264,6 +370,77 @@ func TestCustomInstructionPushPop(t *testing.T) {
 	}
 }
 
+func TestOnCustomError(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	newVM := func() VM {
+		registry := NewInstructionRegistry()
+		calls := 0
+		registry.Register(128, &mockHandler{
+			name: "FLAKY",
+			fn: func(ctx ExecutionContext, operand int32) error {
+				calls++
+				if calls == 1 {
+					return errFlaky
+				}
+				return ctx.Push(IntValue(int64(operand)))
+			},
+		})
+		return NewWithConfig(Config{StackSize: 256, InstructionRegistry: registry})
+	}
+
+	program := NewProgram([]Instruction{
+		NewInstruction(128, 1), // fails
+		NewInstruction(128, 2), // succeeds
+		NewInstruction(OpHALT, 0),
+	})
+
+	t.Run("swallowed", func(t *testing.T) {
+		var seen []int
+		result, err := newVM().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+			OnCustomError: func(err error, pc int) error {
+				seen = append(seen, pc)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if !result.Halted || result.StackDepth != 1 {
+			t.Errorf("Halted = %v, StackDepth = %d, want true, 1", result.Halted, result.StackDepth)
+		}
+		if len(seen) != 1 || seen[0] != 0 {
+			t.Errorf("callback PCs = %v, want [0]", seen)
+		}
+	})
+
+	t.Run("propagated", func(t *testing.T) {
+		_, err := newVM().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+			OnCustomError: func(err error, pc int) error {
+				return err
+			},
+		})
+		if !errors.Is(err, errFlaky) {
+			t.Errorf("Execute() error = %v, want %v", err, errFlaky)
+		}
+	})
+
+	t.Run("built-in errors bypass callback", func(t *testing.T) {
+		called := false
+		_, err := newVM().Execute(NewProgram([]Instruction{NewInstruction(OpPOP, 0)}), NewSimpleMemory(0), ExecuteOptions{
+			OnCustomError: func(err error, pc int) error {
+				called = true
+				return nil
+			},
+		})
+		if !errors.Is(err, ErrStackUnderflow) {
+			t.Errorf("Execute() error = %v, want ErrStackUnderflow", err)
+		}
+		if called {
+			t.Error("OnCustomError should not be called for built-in instructions")
+		}
+	})
+}
+
 func TestRegistryConcurrency(t *testing.T) {
 	registry := NewInstructionRegistry()
 
@@ -288,3 +465,96 @@ func TestRegistryConcurrency(t *testing.T) {
 		t.Errorf("List() returned %d opcodes, want 10", len(opcodes))
 	}
 }
+
+func TestTrapHandlers(t *testing.T) {
+	hostValue := IntValue(42)
+	vm := NewWithConfig(Config{
+		StackSize: 16,
+		TrapHandlers: map[int32]func(ctx ExecutionContext) error{
+			1: func(ctx ExecutionContext) error {
+				return ctx.Push(hostValue)
+			},
+		},
+	})
+
+	program, err := NewAssembler().Assemble("TRAP 1\nSTORE 0\nHALT")
+	if err != nil {
+		t.Fatalf("Assemble() failed: %v", err)
+	}
+
+	memory := NewSimpleMemory(1)
+	if _, err := vm.Execute(program, memory, ExecuteOptions{}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if val, _ := memory.Load(0); !val.Equal(hostValue) {
+		t.Errorf("memory[0] = %v, want %v", val, hostValue)
+	}
+
+	unknown := NewProgramBuilder().Trap(2).Halt()
+	program, _ = unknown.Build()
+	if _, err := vm.Execute(program, memory, ExecuteOptions{}); !errors.Is(err, ErrUnknownTrap) {
+		t.Errorf("Execute() error = %v, want ErrUnknownTrap", err)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	program := MustAssemble("PUSH 42\nPRINT\nPUSHI 7\nPRINT\nHALT")
+
+	var out bytes.Buffer
+	vm := NewWithConfig(Config{Output: &out})
+	result, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got, want := out.String(), "42\n7\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if result.StackDepth != 0 {
+		t.Errorf("StackDepth = %d, want 0", result.StackDepth)
+	}
+
+	// Without an Output the value is still popped.
+	if result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{}); err != nil || result.StackDepth != 0 {
+		t.Errorf("Execute() without Output = %v, %v; want empty stack", result, err)
+	}
+	if _, err := New().Execute(MustAssemble("PRINT"), NewSimpleMemory(0), ExecuteOptions{}); !errors.Is(err, ErrStackUnderflow) {
+		t.Errorf("PRINT on empty stack: error = %v, want ErrStackUnderflow", err)
+	}
+}
+
+func TestCustomHandlerPanic(t *testing.T) {
+	registry := NewInstructionRegistry()
+	handler := &mockHandler{
+		name: "BOOM",
+		fn: func(ctx ExecutionContext, operand int32) error {
+			panic("handler bug")
+		},
+	}
+	if err := registry.Register(200, handler); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 1),
+		NewInstruction(200, 0),
+		NewInstruction(OpHALT, 0),
+	})
+	vm := NewWithConfig(Config{InstructionRegistry: registry})
+
+	_, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if !errors.Is(err, ErrHandlerPanic) {
+		t.Fatalf("Execute() error = %v, want ErrHandlerPanic", err)
+	}
+	var vmErr *VMError
+	if !errors.As(err, &vmErr) {
+		t.Fatalf("error %T is not a *VMError", err)
+	}
+	if vmErr.PC != 1 || vmErr.Message != "handler bug" {
+		t.Errorf("PC = %d, Message = %q, want 1, %q", vmErr.PC, vmErr.Message, "handler bug")
+	}
+
+	// The VM is still usable afterwards.
+	if _, err := vm.Execute(NewProgram([]Instruction{NewInstruction(OpHALT, 0)}), NewSimpleMemory(0), ExecuteOptions{}); err != nil {
+		t.Errorf("Execute() after panic failed: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package stackvm
+
+// Runner executes a program in slices of a fixed number of instructions,
+// for cooperative scheduling. The VM state (PC, data stack, call stack,
+// and instruction count) persists between slices, so running a program
+// in slices produces the same final state as a single Execute.
+//
+// Limits in the ExecuteOptions apply to the whole run: MaxInstructions
+// counts instructions across all slices, and Timeout is measured from
+// NewRunner, including time spent paused. A Runner is not safe for
+// concurrent use.
+type Runner struct {
+	e        *executor
+	run      *execRun
+	finished bool
+	err      error
+}
+
+// NewRunner prepares program for execution on a new VM with the given
+// configuration. No instruction runs until RunFor is called. It returns an
+// error if opts.InitialStack cannot be pushed.
+func NewRunner(config Config, program Program, memory Memory, opts ExecuteOptions) (*Runner, error) {
+	e := newExecutor(config)
+	run, err := e.start(program, memory, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{e: e, run: run}, nil
+}
+
+// RunFor executes at most budget instructions (0 = run to completion) and
+// reports whether the program finished and how many instructions ran. A
+// program that fails has finished, and the error is returned by this and
+// every later call. Calling RunFor after the program finished runs nothing.
+func (r *Runner) RunFor(budget uint32) (finished bool, ran uint32, err error) {
+	if r.finished {
+		return true, 0, r.err
+	}
+	ran, r.finished, r.err = r.e.loop(r.run, budget)
+	return r.finished, ran, r.err
+}
+
+// Finished reports whether the program has finished.
+func (r *Runner) Finished() bool {
+	return r.finished
+}
+
+// Result returns the execution result so far. While the program is
+// paused its TerminationReason is TerminationYielded; once it has finished
+// it matches the Result Execute would have returned.
+func (r *Runner) Result() *Result {
+	return r.e.result(r.run.startTime, r.err)
+}
@@ -0,0 +1,109 @@
+package stackvm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunnerMatchesExecute(t *testing.T) {
+	program := MustAssemble(`
+		PUSHI 0
+		STORE 0
+	loop:
+		CALL step
+		LOAD 0
+		PUSHI 500
+		LT
+		JMPNZ loop
+		LOAD 0
+		HALT
+	step:
+		LOAD 0
+		INC
+		STORE 0
+		RET
+	`)
+
+	wantMem := NewSimpleMemory(1)
+	want, err := New().Execute(program, wantMem, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+
+	mem := NewSimpleMemory(1)
+	runner, err := NewRunner(Config{}, program, mem, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("NewRunner() failed: %v", err)
+	}
+	var total uint32
+	slices := 0
+	for {
+		finished, ran, err := runner.RunFor(7)
+		if err != nil {
+			t.Fatalf("RunFor() failed: %v", err)
+		}
+		if ran > 7 {
+			t.Fatalf("RunFor(7) ran %d instructions", ran)
+		}
+		total += ran
+		slices++
+		if finished {
+			break
+		}
+		if runner.Finished() {
+			t.Fatal("Finished() = true while paused")
+		}
+		if r := runner.Result(); r.TerminationReason != TerminationYielded || r.Halted {
+			t.Fatalf("paused Result = %v, want yielded and not halted", r)
+		}
+	}
+
+	got := runner.Result()
+	if slices < 2 {
+		t.Errorf("program finished in %d slice, want several", slices)
+	}
+	if total != want.InstructionCount || got.InstructionCount != want.InstructionCount {
+		t.Errorf("instructions = %d (Result %d), want %d", total, got.InstructionCount, want.InstructionCount)
+	}
+	if got.HaltPC != want.HaltPC || got.Halted != want.Halted || got.TerminationReason != want.TerminationReason {
+		t.Errorf("Result = %v, want %v", got, want)
+	}
+	if got.TerminationReason != TerminationExplicitHalt {
+		t.Errorf("TerminationReason = %v, want %v", got.TerminationReason, TerminationExplicitHalt)
+	}
+	if len(got.Stack) != 1 || !got.Stack[0].Equal(want.Stack[0]) {
+		t.Errorf("Stack = %v, want %v", got.Stack, want.Stack)
+	}
+	gotVal, _ := mem.Load(0)
+	wantVal, _ := wantMem.Load(0)
+	if !gotVal.Equal(wantVal) {
+		t.Errorf("memory[0] = %v, want %v", gotVal, wantVal)
+	}
+
+	// Running a finished program does nothing.
+	if finished, ran, err := runner.RunFor(7); !finished || ran != 0 || err != nil {
+		t.Errorf("RunFor() after finish = %v, %d, %v; want true, 0, nil", finished, ran, err)
+	}
+}
+
+func TestRunnerLimitsSpanSlices(t *testing.T) {
+	program := MustAssemble("loop: JMP loop")
+	runner, err := NewRunner(Config{}, program, NewSimpleMemory(0), ExecuteOptions{MaxInstructions: 10})
+	if err != nil {
+		t.Fatalf("NewRunner() failed: %v", err)
+	}
+
+	if finished, ran, err := runner.RunFor(6); finished || ran != 6 || err != nil {
+		t.Fatalf("RunFor(6) = %v, %d, %v; want false, 6, nil", finished, ran, err)
+	}
+	finished, ran, err := runner.RunFor(6)
+	if !finished || ran != 4 || !errors.Is(err, ErrInstructionLimit) {
+		t.Fatalf("RunFor(6) = %v, %d, %v; want true, 4, ErrInstructionLimit", finished, ran, err)
+	}
+	if _, _, again := runner.RunFor(6); !errors.Is(again, ErrInstructionLimit) {
+		t.Errorf("RunFor() after failure = %v, want ErrInstructionLimit", again)
+	}
+	if result := runner.Result(); result.TerminationReason != TerminationError {
+		t.Errorf("TerminationReason = %v, want TerminationError", result.TerminationReason)
+	}
+}
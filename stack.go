@@ -0,0 +1,78 @@
+package stackvm
+
+// StackBackend stores a VM's data stack. The default keeps values in a Go
+// slice; a custom backend can keep them elsewhere, such as in pages spilled
+// to disk, for workloads that need very deep stacks. The VM checks the
+// stack depth limit before calling Push, so backends need not. A backend is
+// used by one VM at a time.
+type StackBackend interface {
+	// Push adds a value to the top of the stack.
+	Push(v Value) error
+
+	// Pop removes and returns the top value.
+	// Returns ErrStackUnderflow if the stack is empty.
+	Pop() (Value, error)
+
+	// Peek returns the value n positions below the top (0 = top) without
+	// removing it. Returns ErrStackUnderflow if there is no such value.
+	Peek(n int) (Value, error)
+
+	// Depth returns the number of values on the stack.
+	Depth() int
+
+	// Reset removes all values.
+	Reset()
+}
+
+// sliceStack is the default StackBackend, backed by a Go slice.
+type sliceStack struct {
+	values []Value
+}
+
+// newSliceStack creates a slice-backed stack with the given initial capacity.
+func newSliceStack(capacity int) *sliceStack {
+	return &sliceStack{values: make([]Value, 0, capacity)}
+}
+
+func (s *sliceStack) Push(v Value) error {
+	s.values = append(s.values, v)
+	return nil
+}
+
+func (s *sliceStack) Pop() (Value, error) {
+	if len(s.values) == 0 {
+		return NilValue(), ErrStackUnderflow
+	}
+	v := s.values[len(s.values)-1]
+	s.values = s.values[:len(s.values)-1]
+	return v, nil
+}
+
+func (s *sliceStack) Peek(n int) (Value, error) {
+	if n < 0 || n >= len(s.values) {
+		return NilValue(), ErrStackUnderflow
+	}
+	return s.values[len(s.values)-1-n], nil
+}
+
+func (s *sliceStack) Depth() int {
+	return len(s.values)
+}
+
+func (s *sliceStack) Reset() {
+	s.values = s.values[:0]
+}
+
+// stackValues returns a copy of a stack's contents, bottom first, or nil if
+// it is empty.
+func stackValues(stack StackBackend) []Value {
+	depth := stack.Depth()
+	if depth == 0 {
+		return nil
+	}
+	values := make([]Value, depth)
+	for i := range values {
+		values[i], _ = stack.Peek(depth - 1 - i)
+	}
+	return values
+}
@@ -0,0 +1,176 @@
+package stackvm
+
+import (
+	"errors"
+	"testing"
+)
+
+// listStack is a StackBackend built on a linked list, standing in for a
+// custom backend such as a paged stack.
+type listStack struct {
+	top    *listNode
+	depth  int
+	pushes int
+}
+
+type listNode struct {
+	value Value
+	next  *listNode
+}
+
+func (s *listStack) Push(v Value) error {
+	s.top = &listNode{value: v, next: s.top}
+	s.depth++
+	s.pushes++
+	return nil
+}
+
+func (s *listStack) Pop() (Value, error) {
+	if s.top == nil {
+		return NilValue(), ErrStackUnderflow
+	}
+	v := s.top.value
+	s.top = s.top.next
+	s.depth--
+	return v, nil
+}
+
+func (s *listStack) Peek(n int) (Value, error) {
+	node := s.top
+	for ; node != nil && n > 0; n-- {
+		node = node.next
+	}
+	if node == nil || n < 0 {
+		return NilValue(), ErrStackUnderflow
+	}
+	return node.value, nil
+}
+
+func (s *listStack) Depth() int {
+	return s.depth
+}
+
+func (s *listStack) Reset() {
+	s.top = nil
+	s.depth = 0
+}
+
+func TestStackBackend(t *testing.T) {
+	programs := map[string]string{
+		"arithmetic": `
+			PUSHI 6
+			PUSHI 7
+			MUL
+			PUSH 2.5
+			SUB
+			HALT
+		`,
+		"stack shuffles": `
+			PUSHI 1
+			PUSHI 2
+			PUSHI 3
+			ROT
+			SWAP
+			OVER
+			DUP
+			HALT
+		`,
+		"loop": `
+				PUSHI 5
+				STORE 0
+				PUSHI 1
+			LOOP:
+				LOAD 0
+				MUL
+				LOAD 0
+				DEC
+				DUP
+				STORE 0
+				JMPNZ LOOP
+				HALT
+		`,
+		"underflow": `
+			PUSHI 1
+			ADD
+		`,
+	}
+
+	for name, source := range programs {
+		t.Run(name, func(t *testing.T) {
+			program := MustAssemble(source)
+
+			want, wantErr := New().Execute(program, NewSimpleMemory(1), ExecuteOptions{})
+
+			backend := &listStack{}
+			vm := NewWithConfig(Config{
+				StackSize:    256,
+				StackBackend: func() StackBackend { return backend },
+			})
+			got, gotErr := vm.Execute(program, NewSimpleMemory(1), ExecuteOptions{})
+
+			if (gotErr == nil) != (wantErr == nil) || gotErr != nil && !errors.Is(gotErr, ErrStackUnderflow) {
+				t.Fatalf("error = %v, want %v", gotErr, wantErr)
+			}
+			if got.InstructionCount != want.InstructionCount {
+				t.Errorf("InstructionCount = %d, want %d", got.InstructionCount, want.InstructionCount)
+			}
+			if len(got.Stack) != len(want.Stack) {
+				t.Fatalf("Stack = %v, want %v", got.Stack, want.Stack)
+			}
+			for i := range want.Stack {
+				if got.Stack[i].Type != want.Stack[i].Type || !got.Stack[i].Equal(want.Stack[i]) {
+					t.Errorf("Stack[%d] = %v, want %v", i, got.Stack[i], want.Stack[i])
+				}
+			}
+			if backend.pushes == 0 {
+				t.Error("custom backend was not used")
+			}
+		})
+	}
+}
+
+func TestStackBackendCustomInstruction(t *testing.T) {
+	registry := NewInstructionRegistry()
+	err := registry.Register(128, &mockHandler{
+		name: "SUM2",
+		fn: func(ctx ExecutionContext, operand int32) error {
+			b, err := ctx.Pop()
+			if err != nil {
+				return err
+			}
+			a, err := ctx.Pop()
+			if err != nil {
+				return err
+			}
+			x, _ := a.AsInt()
+			y, _ := b.AsInt()
+			return ctx.Push(IntValue(x + y))
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	backend := &listStack{}
+	vm := NewWithConfig(Config{
+		StackSize:           256,
+		InstructionRegistry: registry,
+		StackBackend:        func() StackBackend { return backend },
+	})
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 2),
+		NewInstruction(OpPUSHI, 3),
+		NewInstruction(128, 0),
+		NewInstruction(OpHALT, 0),
+	})
+	result, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if len(result.Stack) != 1 || !result.Stack[0].Equal(IntValue(5)) {
+		t.Errorf("Stack = %v, want [5]", result.Stack)
+	}
+	if backend.Depth() != 1 {
+		t.Errorf("backend depth = %d, want 1", backend.Depth())
+	}
+}
@@ -0,0 +1,7 @@
+; Computes 3^2 + 4^2 using routines from the mathlib module
+    PUSHI 3
+    CALL mathlib.square
+    PUSHI 4
+    CALL mathlib.square
+    ADD
+    HALT
@@ -0,0 +1,12 @@
+; Arithmetic helpers linked by main.asm. Labels here are private unless
+; qualified, so mul is mathlib.mul to other modules.
+.name mathlib
+
+square:
+    DUP
+    CALL mul
+    RET
+
+mul:
+    MUL
+    RET
@@ -2,6 +2,8 @@ package stackvm
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -139,6 +141,38 @@ func (tr *TestRunner) ExpectMemoryInt(index int, expectedValue int64) {
 	}
 }
 
+// ExpectMemory verifies several memory slots at once, comparing each with
+// Value.Equal. All mismatches are reported together.
+func (tr *TestRunner) ExpectMemory(expected map[int]Value) {
+	tr.t.Helper()
+
+	if mismatches := memoryMismatches(tr.memory, expected); len(mismatches) > 0 {
+		tr.t.Errorf("Memory mismatch:\n%s", strings.Join(mismatches, "\n"))
+	}
+}
+
+// memoryMismatches describes each slot in expected that differs from memory,
+// in address order.
+func memoryMismatches(memory Memory, expected map[int]Value) []string {
+	indices := make([]int, 0, len(expected))
+	for index := range expected {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	var mismatches []string
+	for _, index := range indices {
+		want := expected[index]
+		val, err := memory.Load(index)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("Memory[%d]: %v", index, err))
+		} else if !val.Equal(want) {
+			mismatches = append(mismatches, fmt.Sprintf("Memory[%d] = %v, want %v", index, val, want))
+		}
+	}
+	return mismatches
+}
+
 // Reset resets the VM and memory for the next test.
 func (tr *TestRunner) Reset() {
 	tr.vm.Reset()
@@ -229,6 +263,35 @@ func BenchmarkProgram(b *testing.B, source string) {
 	}
 }
 
+// BenchmarkProgramWithConfig benchmarks program execution on VMs built from
+// cfg, so configurations can be compared. Custom instructions in
+// cfg.InstructionRegistry are available to the source. MaxInstructions
+// defaults to 10000 if opts leaves it unset.
+func BenchmarkProgramWithConfig(b *testing.B, source string, cfg Config, opts ExecuteOptions) {
+	asm := NewAssembler()
+	if cfg.InstructionRegistry != nil {
+		asm.SetRegistry(cfg.InstructionRegistry)
+	}
+	program, err := asm.Assemble(source)
+	if err != nil {
+		b.Fatalf("Failed to assemble: %v", err)
+	}
+
+	if opts.MaxInstructions == 0 {
+		opts.MaxInstructions = 10000
+	}
+
+	pool := NewVMPool(cfg)
+	memory := NewSimpleMemory(256)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pool.Execute(program, memory, opts); err != nil {
+			b.Fatalf("Execution failed: %v", err)
+		}
+	}
+}
+
 // AssertProgramOutput is a helper for quick program testing.
 func AssertProgramOutput(t *testing.T, source string, expectedStackDepth int) {
 	t.Helper()
@@ -1,6 +1,7 @@
 package stackvm
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -78,6 +79,45 @@ func TestTestRunnerMemoryInt(t *testing.T) {
 	runner.ExpectMemoryInt(0, 42)
 }
 
+func TestTestRunnerExpectMemory(t *testing.T) {
+	runner := NewTestRunner(t)
+
+	source := `
+		PUSHI 1
+		STORE 0
+		PUSH 2.5
+		STORE 1
+		PUSHI 3
+		STORE 2
+		HALT
+	`
+
+	result := runner.AssembleAndRun(source)
+	runner.ExpectHalted(result)
+	runner.ExpectMemory(map[int]Value{
+		0: IntValue(1),
+		1: FloatValue(2.5),
+		2: IntValue(3),
+		3: NilValue(),
+	})
+
+	// A deliberate mismatch in two slots reports both, plus the bad index.
+	mismatches := memoryMismatches(runner.memory, map[int]Value{
+		0:  IntValue(1),
+		1:  FloatValue(9),
+		2:  IntValue(4),
+		-1: IntValue(0),
+	})
+	if len(mismatches) != 3 {
+		t.Fatalf("got %d mismatches, want 3: %v", len(mismatches), mismatches)
+	}
+	for i, prefix := range []string{"Memory[-1]: ", "Memory[1] = ", "Memory[2] = "} {
+		if !strings.HasPrefix(mismatches[i], prefix) {
+			t.Errorf("mismatch %d = %q, want prefix %q", i, mismatches[i], prefix)
+		}
+	}
+}
+
 func TestTestRunnerReset(t *testing.T) {
 	runner := NewTestRunner(t)
 
@@ -213,6 +253,37 @@ func TestBenchmarkProgram(t *testing.T) {
 	}
 }
 
+func TestBenchmarkProgramWithConfig(t *testing.T) {
+	source := `
+		PUSHI 1
+		PUSHI 2
+		PUSHI 3
+		PUSHI 4
+		PUSHI 5
+		HALT
+	`
+
+	tests := []struct {
+		name      string
+		stackSize int
+		wantRun   bool
+	}{
+		{"stack fits", 8, true},
+		{"stack too small", 4, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := testing.Benchmark(func(b *testing.B) {
+				BenchmarkProgramWithConfig(b, source, Config{StackSize: tt.stackSize}, ExecuteOptions{})
+			})
+			if ran := result.N > 0; ran != tt.wantRun {
+				t.Errorf("benchmark ran = %v (N=%d), want %v", ran, result.N, tt.wantRun)
+			}
+		})
+	}
+}
+
 func TestTestRunnerWithCustomInstructions(t *testing.T) {
 	// Create a custom instruction
 	registry := NewInstructionRegistry()
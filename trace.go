@@ -0,0 +1,77 @@
+package stackvm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TraceEntry records one executed instruction.
+type TraceEntry struct {
+	// PC is the address of the instruction.
+	PC int
+
+	// Instruction is the instruction that was executed.
+	Instruction Instruction
+
+	// StackDepth is the stack depth after the instruction ran.
+	StackDepth int
+
+	// Top is the value on top of the stack after the instruction ran,
+	// or the zero Value if the stack is empty.
+	Top Value
+}
+
+// Trace executes a program on a VM with the default configuration and
+// returns every successfully executed instruction, in order, for offline
+// analysis. Use ExecuteOptions.MaxTrace to bound the trace's size.
+// If execution fails, the entries recorded so far are returned with the
+// error.
+func Trace(program Program, memory Memory, opts ExecuteOptions) ([]TraceEntry, error) {
+	e := newExecutor(Config{StackSize: 256})
+	e.tracing = true
+	_, err := e.Execute(program, memory, opts)
+	return e.trace, err
+}
+
+// Transcript executes a program like Trace and renders the trace as
+// text, one line per executed instruction:
+//
+//	PC OPCODE OPERAND TOP
+//
+// where TOP is the type and value on top of the stack after the
+// instruction ran, or "-" if the stack is empty. The format depends only
+// on the program's behavior, so transcripts can be stored as golden files
+// and diffed to catch regressions between versions. If execution fails,
+// the transcript so far is returned with the error.
+func Transcript(program Program, memory Memory, opts ExecuteOptions) (string, error) {
+	trace, err := Trace(program, memory, opts)
+	var b strings.Builder
+	for _, entry := range trace {
+		top := "-"
+		if entry.StackDepth > 0 {
+			top = transcriptValue(entry.Top)
+		}
+		fmt.Fprintf(&b, "%d %s %d %s\n", entry.PC, entry.Instruction.Opcode, entry.Instruction.Operand, top)
+	}
+	return b.String(), err
+}
+
+// transcriptValue formats v with its type, so that values that print
+// alike, such as Int 1 and Float 1, stay distinct in a transcript.
+func transcriptValue(v Value) string {
+	switch v.Type {
+	case TypeNil:
+		return "nil"
+	case TypeFloat:
+		return "float:" + v.String()
+	case TypeInt:
+		return "int:" + v.String()
+	case TypeBool:
+		return "bool:" + v.String()
+	case TypeString:
+		return "string:" + strconv.Quote(v.String())
+	default:
+		return fmt.Sprintf("custom%d:%s", v.Type, v.String())
+	}
+}
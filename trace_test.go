@@ -0,0 +1,106 @@
+package stackvm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	program := MustAssembleFile("testdata/programs/factorial.asm")
+
+	trace, err := Trace(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Trace() failed: %v", err)
+	}
+
+	// 2 setup instructions, 10 per loop iteration for n = 5..2, and 7 to
+	// test n = 1 and finish.
+	if len(trace) != 49 {
+		t.Fatalf("len(trace) = %d, want 49", len(trace))
+	}
+
+	tests := []struct {
+		index int
+		want  TraceEntry
+	}{
+		{0, TraceEntry{PC: 0, Instruction: NewInstruction(OpPUSHI, 5), StackDepth: 1, Top: IntValue(5)}},
+		{1, TraceEntry{PC: 1, Instruction: NewInstruction(OpPUSHI, 1), StackDepth: 2, Top: IntValue(1)}},
+		{4, TraceEntry{PC: 4, Instruction: NewInstruction(OpLE, 0), StackDepth: 3, Top: BoolValue(false)}},
+		{11, TraceEntry{PC: 11, Instruction: NewInstruction(OpJMP, 2), StackDepth: 2, Top: FloatValue(5)}},
+		{12, TraceEntry{PC: 2, Instruction: NewInstruction(OpOVER, 0), StackDepth: 3, Top: FloatValue(4)}},
+		{48, TraceEntry{PC: 14, Instruction: NewInstruction(OpHALT, 0), StackDepth: 1, Top: FloatValue(120)}},
+	}
+	for _, tt := range tests {
+		if got := trace[tt.index]; got != tt.want {
+			t.Errorf("trace[%d] = %+v, want %+v", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestTraceMaxTrace(t *testing.T) {
+	program := MustAssembleFile("testdata/programs/factorial.asm")
+
+	trace, err := Trace(program, NewSimpleMemory(0), ExecuteOptions{MaxTrace: 10})
+	if err != nil {
+		t.Fatalf("Trace() failed: %v", err)
+	}
+	if len(trace) != 10 {
+		t.Errorf("len(trace) = %d, want 10", len(trace))
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	program := mustBuild(t, NewProgramBuilder().PushInt(1).Add())
+
+	trace, err := Trace(program, NewSimpleMemory(0), ExecuteOptions{})
+	if !errors.Is(err, ErrStackUnderflow) {
+		t.Fatalf("Trace() error = %v, want ErrStackUnderflow", err)
+	}
+	if len(trace) != 1 || trace[0].Instruction.Opcode != OpPUSHI {
+		t.Errorf("trace = %+v, want only the PUSHI", trace)
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	program := MustAssemble(`
+		PUSHI 2
+		STORE 0
+	loop:
+		LOAD 0
+		DEC
+		DUP
+		STORE 0
+		JMPNZ loop
+		PUSHI 3
+		PUSHI 4
+		LT
+		POP
+		HALT
+	`)
+
+	const want = `0 PUSHI 2 int:2
+1 STORE 0 -
+2 LOAD 0 int:2
+3 DEC 0 float:1
+4 DUP 0 float:1
+5 STORE 0 float:1
+6 JMPNZ 2 -
+2 LOAD 0 float:1
+3 DEC 0 float:0
+4 DUP 0 float:0
+5 STORE 0 float:0
+6 JMPNZ 2 -
+7 PUSHI 3 int:3
+8 PUSHI 4 int:4
+9 LT 0 bool:true
+10 POP 0 -
+11 HALT 0 -
+`
+	got, err := Transcript(program, NewSimpleMemory(1), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Transcript() failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Transcript() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package stackvm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValidateProgram performs static checks on a program without executing it.
+// It verifies that every opcode is either a defined standard opcode or in the
+// custom range, that jump and call targets are within the program, that
+// constant pool references exist, that static memory operands are
+// non-negative, and that any entry point is within the program.
+// Returns an error wrapping ErrInvalidProgram describing the first problem found.
+func ValidateProgram(program Program) error {
+	if program == nil {
+		return fmt.Errorf("%w: nil program", ErrInvalidProgram)
+	}
+
+	var constants []Value
+	if pool, ok := program.(ConstantPool); ok {
+		constants = pool.Constants()
+	}
+
+	instructions := programInstructions(program)
+	if ep, ok := program.(EntryPointer); ok {
+		if entry := ep.EntryPoint(); entry < 0 || entry > len(instructions) {
+			return fmt.Errorf("%w: entry point %d out of range [0, %d]",
+				ErrInvalidProgram, entry, len(instructions))
+		}
+	}
+
+	for i, inst := range instructions {
+		if !isDefinedOpcode(inst.Opcode) {
+			return fmt.Errorf("%w: instruction %d: unknown opcode %d", ErrInvalidProgram, i, inst.Opcode)
+		}
+
+		switch inst.Opcode {
+		case OpJMP, OpJMPZ, OpJMPNZ, OpCALL:
+			// A target equal to the program length is allowed: it ends execution.
+			if inst.Operand < 0 || int(inst.Operand) > len(instructions) {
+				return fmt.Errorf("%w: instruction %d: %s target %d out of range [0, %d]",
+					ErrInvalidProgram, i, inst.Opcode, inst.Operand, len(instructions))
+			}
+		case OpJMPR, OpJMPZR, OpJMPNZR:
+			if target := i + int(inst.Operand); target < 0 || target > len(instructions) {
+				return fmt.Errorf("%w: instruction %d: %s offset %d targets %d, out of range [0, %d]",
+					ErrInvalidProgram, i, inst.Opcode, inst.Operand, target, len(instructions))
+			}
+		case OpPUSHC:
+			if inst.Operand < 0 || int(inst.Operand) >= len(constants) {
+				return fmt.Errorf("%w: instruction %d: constant index %d out of range [0, %d)",
+					ErrInvalidProgram, i, inst.Operand, len(constants))
+			}
+		case OpTYPECHK:
+			if inst.Operand < 0 || inst.Operand > 255 {
+				return fmt.Errorf("%w: instruction %d: value type %d out of range [0, 255]",
+					ErrInvalidProgram, i, inst.Operand)
+			}
+		case OpDUPN, OpSWAPN, OpRLOAD, OpRSTORE:
+			if inst.Operand < 0 {
+				return fmt.Errorf("%w: instruction %d: %s operand %d is negative",
+					ErrInvalidProgram, i, inst.Opcode, inst.Operand)
+			}
+		case OpLOAD, OpSTORE, OpMEMCLR:
+			if inst.Operand < 0 {
+				return fmt.Errorf("%w: instruction %d: %s address %d is negative",
+					ErrInvalidProgram, i, inst.Opcode, inst.Operand)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateCustomOpcodes checks that every custom opcode (128-255) used by
+// the program has a handler in registry. A nil registry has no handlers.
+// Returns an error wrapping ErrUnregisteredOpcode that lists each missing
+// opcode once, in ascending order.
+func ValidateCustomOpcodes(program Program, registry InstructionRegistry) error {
+	var missing [256]bool
+	for _, inst := range programInstructions(program) {
+		if !inst.Opcode.IsCustomOpcode() {
+			continue
+		}
+		if registry != nil {
+			if _, ok := registry.Get(inst.Opcode); ok {
+				continue
+			}
+		}
+		missing[inst.Opcode] = true
+	}
+
+	var list []string
+	for op, m := range missing {
+		if m {
+			list = append(list, strconv.Itoa(op))
+		}
+	}
+	if len(list) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnregisteredOpcode, strings.Join(list, ", "))
+	}
+	return nil
+}
+
+// isDefinedOpcode returns true if the opcode is a known standard opcode or
+// falls in the custom range.
+func isDefinedOpcode(op Opcode) bool {
+	if op.IsCustomOpcode() {
+		return true
+	}
+	_, ok := op.Info()
+	return ok
+}
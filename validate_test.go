@@ -0,0 +1,214 @@
+package stackvm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateProgram(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		wantErr      bool
+	}{
+		{
+			name: "valid program",
+			instructions: []Instruction{
+				NewInstruction(OpPUSHI, 1),
+				NewInstruction(OpJMPZ, 3),
+				NewInstruction(OpSTORE, 0),
+				NewInstruction(OpHALT, 0),
+			},
+			wantErr: false,
+		},
+		{
+			name: "jump to end of program",
+			instructions: []Instruction{
+				NewInstruction(OpJMP, 1),
+			},
+			wantErr: false,
+		},
+		{
+			name: "jump target out of range",
+			instructions: []Instruction{
+				NewInstruction(OpJMP, 10),
+				NewInstruction(OpHALT, 0),
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative call target",
+			instructions: []Instruction{
+				NewInstruction(OpCALL, -1),
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative jump backward to start",
+			instructions: []Instruction{
+				NewInstruction(OpNOP, 0),
+				NewInstruction(OpJMPR, -1),
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative jump before start",
+			instructions: []Instruction{
+				NewInstruction(OpJMPZR, -1),
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative jump past end",
+			instructions: []Instruction{
+				NewInstruction(OpJMPNZR, 2),
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown standard opcode",
+			instructions: []Instruction{
+				NewInstruction(Opcode(100), 0),
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative static address",
+			instructions: []Instruction{
+				NewInstruction(OpLOAD, -5),
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom opcode",
+			instructions: []Instruction{
+				NewInstruction(Opcode(200), 7),
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProgram(NewProgram(tt.instructions))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateProgram() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidProgram) {
+				t.Errorf("error should wrap ErrInvalidProgram, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExecuteSafeRejectsInvalidProgram(t *testing.T) {
+	vm := New()
+	memory := NewSimpleMemory(1)
+
+	// The STORE would modify memory if anything ran before validation.
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 42),
+		NewInstruction(OpSTORE, 0),
+		NewInstruction(OpJMP, 99),
+	})
+
+	result, err := vm.ExecuteSafe(program, memory, ExecuteOptions{})
+	if !errors.Is(err, ErrInvalidProgram) {
+		t.Fatalf("ExecuteSafe() error = %v, want ErrInvalidProgram", err)
+	}
+	if result != nil {
+		t.Errorf("ExecuteSafe() result = %+v, want nil", result)
+	}
+
+	val, _ := memory.Load(0)
+	if !val.IsNil() {
+		t.Errorf("memory[0] = %v, want nil (no instruction should run)", val)
+	}
+}
+
+func TestExecuteSafeRunsValidProgram(t *testing.T) {
+	vm := New()
+	memory := NewSimpleMemory(1)
+
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 42),
+		NewInstruction(OpSTORE, 0),
+		NewInstruction(OpHALT, 0),
+	})
+
+	result, err := vm.ExecuteSafe(program, memory, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("ExecuteSafe() error = %v", err)
+	}
+	if !result.Halted {
+		t.Error("Expected program to halt")
+	}
+
+	val, _ := memory.Load(0)
+	if i, _ := val.AsInt(); i != 42 {
+		t.Errorf("memory[0] = %v, want 42", val)
+	}
+}
+
+func TestValidateEntryPoint(t *testing.T) {
+	program := NewProgram([]Instruction{NewInstruction(OpHALT, 0)})
+
+	program.SetEntryPoint(1)
+	if err := ValidateProgram(program); err != nil {
+		t.Errorf("ValidateProgram() error = %v, want nil", err)
+	}
+
+	program.SetEntryPoint(2)
+	if err := ValidateProgram(program); !errors.Is(err, ErrInvalidProgram) {
+		t.Errorf("ValidateProgram() error = %v, want ErrInvalidProgram", err)
+	}
+}
+
+func TestValidateCustomOpcodes(t *testing.T) {
+	registry := NewInstructionRegistry()
+	if err := registry.Register(128, &mockHandler{name: "KNOWN"}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	program := NewProgram([]Instruction{
+		NewInstruction(131, 0),
+		NewInstruction(128, 0),
+		NewInstruction(130, 0),
+		NewInstruction(131, 0),
+		NewInstruction(OpHALT, 0),
+	})
+
+	err := ValidateCustomOpcodes(program, registry)
+	if !errors.Is(err, ErrUnregisteredOpcode) {
+		t.Fatalf("ValidateCustomOpcodes() error = %v, want ErrUnregisteredOpcode", err)
+	}
+	if want := "unregistered custom opcode: 130, 131"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+
+	if err := ValidateCustomOpcodes(NewProgram([]Instruction{NewInstruction(128, 0)}), registry); err != nil {
+		t.Errorf("ValidateCustomOpcodes() with registered opcode = %v", err)
+	}
+	if err := ValidateCustomOpcodes(NewProgram([]Instruction{NewInstruction(128, 0)}), nil); err == nil {
+		t.Error("ValidateCustomOpcodes() with nil registry should fail")
+	}
+}
+
+func TestExecuteSafeRejectsUnregisteredOpcode(t *testing.T) {
+	memory := NewSimpleMemory(1)
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 42),
+		NewInstruction(OpSTORE, 0),
+		NewInstruction(130, 0),
+	})
+
+	result, err := New().ExecuteSafe(program, memory, ExecuteOptions{})
+	if !errors.Is(err, ErrUnregisteredOpcode) {
+		t.Fatalf("ExecuteSafe() error = %v, want ErrUnregisteredOpcode", err)
+	}
+	if result != nil {
+		t.Errorf("ExecuteSafe() result = %+v, want nil", result)
+	}
+	if val, _ := memory.Load(0); !val.IsNil() {
+		t.Errorf("memory[0] = %v, want nil (no instruction should run)", val)
+	}
+}
@@ -3,7 +3,9 @@ package stackvm
 
 import (
 	"fmt"
+	"hash/fnv"
 	"strconv"
+	"sync"
 )
 
 // ValueType represents the type of a Value in the VM.
@@ -167,10 +169,26 @@ func (v Value) String() string {
 		return s
 	default:
 		// Custom types
+		if ops, ok := lookupCustomType(v.Type); ok {
+			return ops.String(v.Data)
+		}
 		return fmt.Sprintf("<custom:%d:%v>", v.Type, v.Data)
 	}
 }
 
+// Hash returns a 64-bit hash of the value. Values that are Equal have the
+// same hash; for custom types this holds if the registered String is
+// consistent with Equal.
+func (v Value) Hash() uint64 {
+	if f, err := v.AsFloat(); err == nil && f == 0 {
+		v = FloatValue(0) // -0 equals 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte{byte(v.Type)})
+	h.Write([]byte(v.String()))
+	return h.Sum64()
+}
+
 // Equal performs type-aware equality comparison.
 func (v Value) Equal(other Value) bool {
 	// Different types are never equal
@@ -198,7 +216,60 @@ func (v Value) Equal(other Value) bool {
 		s2, _ := other.AsString()
 		return s1 == s2
 	default:
-		// Custom types - compare underlying data
+		// Custom types - use registered ops, else compare underlying data
+		if ops, ok := lookupCustomType(v.Type); ok {
+			return ops.Equal(v.Data, other.Data)
+		}
 		return v.Data == other.Data
 	}
 }
+
+// CustomTypeOps supplies formatting and equality for a host-defined value type.
+// Registering ops is required for custom types whose Data is not comparable
+// with == (for example slices or maps).
+type CustomTypeOps interface {
+	// String returns a human-readable representation of the data.
+	String(data interface{}) string
+
+	// Equal reports whether two data values of this type are equal.
+	Equal(a, b interface{}) bool
+}
+
+// customTypes holds the registered custom type operations.
+var customTypes = struct {
+	mu  sync.RWMutex
+	ops map[ValueType]CustomTypeOps
+}{ops: make(map[ValueType]CustomTypeOps)}
+
+// RegisterCustomType registers formatting and equality operations for a
+// custom value type. Value.String and Value.Equal consult the registered ops.
+// The type must be in the custom range (128-255). Registering a type again
+// replaces its ops. The registry is process-wide and safe for concurrent use.
+func RegisterCustomType(typ ValueType, ops CustomTypeOps) error {
+	if typ < 128 {
+		return fmt.Errorf("cannot register value type %d: reserved for built-in types", typ)
+	}
+	if ops == nil {
+		return fmt.Errorf("nil ops for value type %d", typ)
+	}
+
+	customTypes.mu.Lock()
+	defer customTypes.mu.Unlock()
+	customTypes.ops[typ] = ops
+	return nil
+}
+
+// UnregisterCustomType removes the operations registered for a custom value type.
+func UnregisterCustomType(typ ValueType) {
+	customTypes.mu.Lock()
+	defer customTypes.mu.Unlock()
+	delete(customTypes.ops, typ)
+}
+
+// lookupCustomType returns the registered ops for a custom value type.
+func lookupCustomType(typ ValueType) (CustomTypeOps, bool) {
+	customTypes.mu.RLock()
+	defer customTypes.mu.RUnlock()
+	ops, ok := customTypes.ops[typ]
+	return ops, ok
+}
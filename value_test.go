@@ -1,6 +1,8 @@
 package stackvm
 
 import (
+	"fmt"
+	"math"
 	"testing"
 )
 
@@ -280,6 +282,30 @@ func TestValueEqual(t *testing.T) {
 	}
 }
 
+func TestValueHash(t *testing.T) {
+	tests := []struct {
+		name string
+		v1   Value
+		v2   Value
+		same bool
+	}{
+		{"Equal ints", IntValue(42), IntValue(42), true},
+		{"Equal strings", StringValue("hi"), StringValue("hi"), true},
+		{"Zero and negative zero", FloatValue(0), FloatValue(math.Copysign(0, -1)), true},
+		{"Different ints", IntValue(1), IntValue(2), false},
+		{"Int and float", IntValue(1), FloatValue(1), false},
+		{"Int and string", IntValue(1), StringValue("1"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v1.Hash() == tt.v2.Hash(); got != tt.same {
+				t.Errorf("Hash(%v) == Hash(%v) is %v, want %v", tt.v1, tt.v2, got, tt.same)
+			}
+		})
+	}
+}
+
 func TestCustomValue(t *testing.T) {
 	t.Run("Custom type 128", func(t *testing.T) {
 		v := CustomValue(128, "custom data")
@@ -325,3 +351,52 @@ func TestCustomValue(t *testing.T) {
 		}
 	})
 }
+
+// intSliceOps implements CustomTypeOps for []int data.
+type intSliceOps struct{}
+
+func (intSliceOps) String(data interface{}) string {
+	return fmt.Sprintf("vec%v", data.([]int))
+}
+
+func (intSliceOps) Equal(a, b interface{}) bool {
+	as, ok1 := a.([]int)
+	bs, ok2 := b.([]int)
+	if !ok1 || !ok2 || len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRegisterCustomType(t *testing.T) {
+	const vecType ValueType = 200
+	if err := RegisterCustomType(vecType, intSliceOps{}); err != nil {
+		t.Fatalf("RegisterCustomType() failed: %v", err)
+	}
+	t.Cleanup(func() { UnregisterCustomType(vecType) })
+
+	v1 := CustomValue(vecType, []int{1, 2, 3})
+	v2 := CustomValue(vecType, []int{1, 2, 3})
+	v3 := CustomValue(vecType, []int{1, 2})
+
+	if !v1.Equal(v2) {
+		t.Error("Slice-backed values with equal contents should be equal")
+	}
+	if v1.Equal(v3) {
+		t.Error("Slice-backed values with different contents should not be equal")
+	}
+	if got := v1.String(); got != "vec[1 2 3]" {
+		t.Errorf("String() = %q, want %q", got, "vec[1 2 3]")
+	}
+}
+
+func TestRegisterCustomTypeRejectsBuiltin(t *testing.T) {
+	if err := RegisterCustomType(TypeInt, intSliceOps{}); err == nil {
+		t.Error("RegisterCustomType(TypeInt) should fail for built-in types")
+	}
+}
@@ -2,6 +2,8 @@ package stackvm
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"time"
 )
 
@@ -11,6 +13,19 @@ type VM interface {
 	// Returns execution results and statistics, or an error.
 	Execute(program Program, memory Memory, opts ExecuteOptions) (*Result, error)
 
+	// ExecuteSafe validates the program with ValidateProgram and checks that
+	// its custom opcodes are registered (see ValidateCustomOpcodes) before
+	// running it. If validation fails the error is returned and no
+	// instruction is executed.
+	ExecuteSafe(program Program, memory Memory, opts ExecuteOptions) (*Result, error)
+
+	// ExecuteFrom is like Execute but starts at the instruction at startPC
+	// instead of the program's entry point, for example to run a single
+	// routine. The data segment is still loaded first. It returns an error
+	// wrapping ErrInvalidOperand, without executing anything, if startPC
+	// is not the address of an instruction.
+	ExecuteFrom(program Program, memory Memory, startPC int, opts ExecuteOptions) (*Result, error)
+
 	// Reset clears the VM state for reuse.
 	Reset()
 }
@@ -32,13 +47,111 @@ type ExecuteOptions struct {
 	// Context provides cancellation support (nil = no cancellation).
 	// Returns the context error if cancelled.
 	Context context.Context
+
+	// OnCustomError is called when a custom instruction handler returns an
+	// error (nil = abort on error). If it returns nil, execution continues
+	// with the next instruction; otherwise execution aborts with the returned
+	// error. Built-in instructions are not affected.
+	OnCustomError func(err error, pc int) error
+
+	// MemTrace records the memory addresses read and written by LOAD, STORE,
+	// LOADD, STORED, and MEMCLR. The sets are returned in Result.ReadAddrs and
+	// Result.WriteAddrs.
+	MemTrace bool
+
+	// MemoryWindow confines memory access to [Min, Max) regardless of the
+	// size of the backing Memory. Accesses outside the window fail with
+	// ErrInvalidMemoryAddress. Nil means no restriction.
+	MemoryWindow *MemoryWindow
+
+	// InitialStack pre-populates the stack before execution begins, bottom
+	// first. Exceeding the stack depth limit fails with ErrStackOverflow
+	// before any instruction runs.
+	InitialStack []Value
+
+	// MaxMemoryBytes caps the approximate bytes a program allocates
+	// (0 = unlimited). The count is the total length of every string pushed
+	// onto the stack, by any instruction including custom ones; other values
+	// are not counted. Returns ErrMemoryLimit if exceeded.
+	MaxMemoryBytes int
+
+	// MaxStackBytes caps the bytes held by the values currently on the
+	// stack (0 = unlimited). Unlike MaxMemoryBytes the count falls as
+	// values are popped, so it bounds a program's footprint rather than
+	// its total allocation. Strings count their length, as do custom
+	// values whose data is a string or byte slice; other values are not
+	// counted. Returns ErrStackBytesLimit if exceeded.
+	MaxStackBytes int
+
+	// DetectLoops fails execution with ErrInfiniteLoop when the VM returns
+	// to a state it was in recently: the same PC, stack, registers, and
+	// values in the memory slots the program has accessed with LOAD, STORE, LOADD,
+	// STORED, and MEMCLR. Detection is heuristic. Only the last few thousand states are
+	// remembered, memory changed by the host or by custom instructions is
+	// not seen, and a hash collision can report a loop that isn't there.
+	// Hashing the state before every instruction is expensive, so this is
+	// meant for analyzing untrusted programs alongside MaxInstructions.
+	DetectLoops bool
+
+	// MaxTrace caps the number of entries recorded by Trace (0 = unlimited).
+	// Execution continues past the cap; later instructions are not recorded.
+	MaxTrace int
+
+	// StrictOperands fails execution with ErrInvalidOperand when an
+	// instruction whose opcode takes no operand, such as ADD or HALT, has
+	// a non-zero operand. Such operands are otherwise ignored, which can
+	// hide encoding or code generation bugs. Custom opcodes are not
+	// checked.
+	StrictOperands bool
+
+	// CheckStackBalance fails execution with ErrStackImbalance when a
+	// subroutine returns with the stack at a different depth than its
+	// CALL found it, adjusted by the subroutine's entry in StackEffects.
+	// The error names the subroutine by its label.
+	CheckStackBalance bool
+
+	// StackEffects declares, by label, the net change in stack depth of
+	// subroutines that take arguments or return results: results minus
+	// arguments. Subroutines without an entry must leave the depth
+	// unchanged. Only used with CheckStackBalance.
+	StackEffects map[string]int
+
+	// ExpectStackDepth, if set, fails execution with
+	// ErrUnexpectedStackDepth when the program halts with a different
+	// number of values on the stack. Programs meant to produce a single
+	// result set it to 1 so that leftover values are caught. Executions
+	// that stop with an error are not checked.
+	ExpectStackDepth *int
+
+	// Watchpoints maps instruction addresses to callbacks that are called
+	// each time execution reaches the address, before the instruction
+	// runs. The callback receives a copy of the stack, bottom first.
+	Watchpoints map[int]func(stack []Value)
+}
+
+// MemoryWindow is a half-open range of memory addresses, [Min, Max).
+type MemoryWindow struct {
+	Min, Max int
+}
+
+// contains reports whether addr is inside the window. A nil window
+// contains every address.
+func (w *MemoryWindow) contains(addr int) bool {
+	return w == nil || (addr >= w.Min && addr < w.Max)
 }
 
 // Result contains execution statistics and results.
 type Result struct {
-	// InstructionCount is the number of instructions executed.
+	// InstructionCount is the number of instructions executed. When an
+	// instruction limit, timeout, or cancellation stops execution, it counts
+	// only instructions that completed; an instruction that fails with an
+	// error is included.
 	InstructionCount uint32
 
+	// Stack is a copy of the final stack contents, bottom first
+	// (nil if the stack is empty).
+	Stack []Value
+
 	// StackDepth is the final stack depth.
 	StackDepth int
 
@@ -48,8 +161,79 @@ type Result struct {
 	// Halted is true if a HALT instruction was reached.
 	Halted bool
 
+	// ExitCode is the value popped by an EXIT instruction. It is 0 when the
+	// program stops any other way.
+	ExitCode int
+
+	// HaltPC is the program counter at termination: the address of the
+	// HALT that stopped execution, or the program length if execution ran
+	// off the end of the instruction list.
+	HaltPC int
+
+	// TerminationReason describes why execution stopped. Unlike Halted, it
+	// distinguishes an explicit HALT from running off the end of the program.
+	TerminationReason TerminationReason
+
 	// Error is the execution error, if any (nil if successful).
 	Error error
+
+	// ReadAddrs lists the memory addresses read, sorted and unique.
+	// Only populated when ExecuteOptions.MemTrace is set.
+	ReadAddrs []int
+
+	// WriteAddrs lists the memory addresses written, sorted and unique.
+	// Only populated when ExecuteOptions.MemTrace is set.
+	WriteAddrs []int
+}
+
+// String summarizes the result for logging, for example
+// "explicit halt after 42 instructions in 1.5ms, stack depth 1". A non-zero
+// exit code and the error, if any, are appended.
+func (r *Result) String() string {
+	s := fmt.Sprintf("%s after %d instructions in %s, stack depth %d",
+		r.TerminationReason, r.InstructionCount, r.ExecutionTime, r.StackDepth)
+	if r.ExitCode != 0 {
+		s += fmt.Sprintf(", exit code %d", r.ExitCode)
+	}
+	if r.Error != nil {
+		s += ": " + r.Error.Error()
+	}
+	return s
+}
+
+// TerminationReason describes why execution stopped.
+type TerminationReason uint8
+
+const (
+	// TerminationExplicitHalt means a HALT instruction (or a handler calling
+	// Halt) stopped execution.
+	TerminationExplicitHalt TerminationReason = iota
+
+	// TerminationEndOfProgram means execution ran off the end of the
+	// instruction list without reaching a HALT.
+	TerminationEndOfProgram
+
+	// TerminationYielded means execution was suspended and may be resumed.
+	TerminationYielded
+
+	// TerminationError means execution stopped because of an error.
+	TerminationError
+)
+
+// String returns the name of the termination reason.
+func (r TerminationReason) String() string {
+	switch r {
+	case TerminationExplicitHalt:
+		return "explicit halt"
+	case TerminationEndOfProgram:
+		return "end of program"
+	case TerminationYielded:
+		return "yielded"
+	case TerminationError:
+		return "error"
+	default:
+		return fmt.Sprintf("TerminationReason(%d)", r)
+	}
 }
 
 // Config configures a VM instance.
@@ -57,29 +241,107 @@ type Config struct {
 	// StackSize is the initial stack capacity (default 256).
 	StackSize int
 
+	// MaxCallDepth limits the number of nested CALLs (default 256).
+	// Exceeding it fails with ErrCallStackOverflow.
+	MaxCallDepth int
+
 	// DefaultInstrLimit is the default instruction limit (0 = unlimited).
 	DefaultInstrLimit uint32
 
+	// NumRegisters is the number of registers addressed by RLOAD and
+	// RSTORE (default 16). Registers are cleared to nil before each run.
+	NumRegisters int
+
 	// InstructionRegistry provides custom instruction handlers (nil = standard only).
 	InstructionRegistry InstructionRegistry
 
+	// DivByZero selects how DIV and MOD handle a zero divisor
+	// (default DivByZeroError).
+	DivByZero DivByZeroPolicy
+
 	// ValueConverter provides custom type conversions (nil = defaults).
+	// It is consulted by numeric, boolean, and address coercions before the
+	// built-in rules.
 	ValueConverter ValueConverter
+
+	// ComparisonResult selects the type pushed by EQ, NE, GT, LT, GE, and LE
+	// (default ComparisonBool).
+	ComparisonResult ComparisonResultMode
+
+	// BitwiseIntLogic makes AND, OR, and XOR operate bitwise and push an
+	// IntValue when both operands are TypeInt. Any other operand types use
+	// truthiness and push a BoolValue, as when the flag is off. NOT is
+	// always logical.
+	BitwiseIntLogic bool
+
+	// Output receives the values written by PRINT, one per line in their
+	// String form (nil = discard). A write error aborts execution.
+	Output io.Writer
+
+	// TrapHandlers maps trap numbers to host callbacks invoked by TRAP.
+	// A TRAP whose number has no handler fails with ErrUnknownTrap.
+	TrapHandlers map[int32]func(ctx ExecutionContext) error
+
+	// StackBackend creates the storage for each VM's data stack
+	// (nil = a Go slice). It is called once per VM, so VMs created from the
+	// same Config, such as those in a VMPool, each get their own backend.
+	StackBackend func() StackBackend
+
+	// Middleware wraps the execution of every instruction, standard and
+	// custom. The first entry is the outermost. A middleware can observe
+	// the instruction and VM state, return an error to abort execution, or
+	// return without calling next to skip the instruction.
+	Middleware []func(next OpcodeFunc) OpcodeFunc
 }
 
+// OpcodeFunc executes a single instruction.
+type OpcodeFunc func(ctx ExecutionContext, inst Instruction) error
+
+// DivByZeroPolicy selects the behavior of division by zero.
+type DivByZeroPolicy uint8
+
+const (
+	// DivByZeroError makes DIV and MOD return ErrDivisionByZero.
+	DivByZeroError DivByZeroPolicy = iota
+
+	// DivByZeroIEEE makes DIV follow IEEE-754, producing ±Inf or NaN.
+	// MOD is integer modulo, which has no IEEE result, so it still returns
+	// ErrDivisionByZero.
+	DivByZeroIEEE
+
+	// DivByZeroZero makes DIV and MOD push zero.
+	DivByZeroZero
+)
+
+// ComparisonResultMode selects the value type produced by comparisons.
+type ComparisonResultMode uint8
+
+const (
+	// ComparisonBool makes comparisons push BoolValue results.
+	ComparisonBool ComparisonResultMode = iota
+
+	// ComparisonInt makes comparisons push IntValue(1) for true and
+	// IntValue(0) for false, so results can feed arithmetic directly.
+	ComparisonInt
+)
+
 // InstructionRegistry allows registration of custom instruction handlers.
 // This will be implemented in a future phase.
 type InstructionRegistry interface {
 	// Register adds a handler for a custom opcode (128-255).
 	Register(opcode Opcode, handler InstructionHandler) error
 
+	// RegisterMany adds the same handler for several custom opcodes. It is
+	// atomic: if any opcode cannot be registered, none are.
+	RegisterMany(opcodes []Opcode, handler InstructionHandler) error
+
 	// Unregister removes a handler for an opcode.
 	Unregister(opcode Opcode) error
 
 	// Get retrieves a handler for an opcode.
 	Get(opcode Opcode) (InstructionHandler, bool)
 
-	// List returns all registered custom opcodes.
+	// List returns all registered custom opcodes in ascending order.
 	List() []Opcode
 
 	// Names returns a mapping of opcodes to their names.
@@ -97,7 +359,9 @@ type InstructionHandler interface {
 }
 
 // ValueConverter provides custom type conversion logic.
-// This will be implemented in a future phase.
+// The executor calls it when an instruction needs a float, int, or bool and
+// the operand is of a different type. Returning an error defers to the
+// built-in conversion rules.
 type ValueConverter interface {
 	// Convert converts a value to the target type.
 	Convert(value Value, targetType ValueType) (Value, error)
@@ -2,6 +2,9 @@ package stackvm
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -209,7 +212,7 @@ func TestVMErrors(t *testing.T) {
 			MaxStackDepth: 256,
 		})
 
-		if err != ErrStackOverflow {
+		if !errors.Is(err, ErrStackOverflow) {
 			t.Errorf("Expected ErrStackOverflow, got %v", err)
 		}
 	})
@@ -437,3 +440,814 @@ func TestProgramWithoutHalt(t *testing.T) {
 		t.Errorf("StackDepth = %d, want 2", result.StackDepth)
 	}
 }
+
+func TestHaltPC(t *testing.T) {
+	t.Run("HALT after jump", func(t *testing.T) {
+		vm := New()
+		program := NewProgram([]Instruction{
+			NewInstruction(OpJMP, 3),
+			NewInstruction(OpPUSH, 1),
+			NewInstruction(OpHALT, 0),
+			NewInstruction(OpPUSH, 2),
+			NewInstruction(OpHALT, 0),
+			NewInstruction(OpPUSH, 3),
+		})
+		memory := NewSimpleMemory(0)
+
+		result, err := vm.Execute(program, memory, ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if result.HaltPC != 4 {
+			t.Errorf("HaltPC = %d, want 4", result.HaltPC)
+		}
+	})
+
+	t.Run("run off end", func(t *testing.T) {
+		vm := New()
+		program := NewProgram([]Instruction{
+			NewInstruction(OpPUSH, 1),
+			NewInstruction(OpPUSH, 2),
+		})
+		memory := NewSimpleMemory(0)
+
+		result, err := vm.Execute(program, memory, ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if result.HaltPC != 2 {
+			t.Errorf("HaltPC = %d, want 2", result.HaltPC)
+		}
+	})
+}
+
+func TestTerminationReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions []Instruction
+		want         TerminationReason
+		wantErr      bool
+	}{
+		{
+			name: "explicit HALT",
+			instructions: []Instruction{
+				NewInstruction(OpPUSH, 1),
+				NewInstruction(OpHALT, 0),
+			},
+			want: TerminationExplicitHalt,
+		},
+		{
+			name: "no HALT",
+			instructions: []Instruction{
+				NewInstruction(OpPUSH, 1),
+			},
+			want: TerminationEndOfProgram,
+		},
+		{
+			name: "error",
+			instructions: []Instruction{
+				NewInstruction(OpPOP, 0),
+			},
+			want:    TerminationError,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := New()
+			result, err := vm.Execute(NewProgram(tt.instructions), NewSimpleMemory(0), ExecuteOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.TerminationReason != tt.want {
+				t.Errorf("TerminationReason = %v, want %v", result.TerminationReason, tt.want)
+			}
+			if !tt.wantErr && !result.Halted {
+				t.Error("Halted should remain true for backward compatibility")
+			}
+		})
+	}
+}
+
+// stringNumberConverter converts numeric strings to floats.
+type stringNumberConverter struct{}
+
+func (stringNumberConverter) Convert(value Value, targetType ValueType) (Value, error) {
+	s, err := value.AsString()
+	if err != nil || targetType != TypeFloat {
+		return NilValue(), ErrTypeMismatch
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return NilValue(), ErrTypeMismatch
+	}
+	return FloatValue(f), nil
+}
+
+func TestValueConverter(t *testing.T) {
+	registry := NewInstructionRegistry()
+	registry.Register(128, &mockHandler{
+		name: "PUSHSTR",
+		fn: func(ctx ExecutionContext, operand int32) error {
+			return ctx.Push(StringValue("42"))
+		},
+	})
+
+	program := NewProgram([]Instruction{
+		NewInstruction(128, 0),
+		NewInstruction(OpPUSH, 8),
+		NewInstruction(OpADD, 0),
+		NewInstruction(OpSTORE, 0),
+		NewInstruction(OpHALT, 0),
+	})
+
+	t.Run("with converter", func(t *testing.T) {
+		vm := NewWithConfig(Config{
+			StackSize:           256,
+			InstructionRegistry: registry,
+			ValueConverter:      stringNumberConverter{},
+		})
+		memory := NewSimpleMemory(1)
+
+		if _, err := vm.Execute(program, memory, ExecuteOptions{}); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		val, _ := memory.Load(0)
+		if f, _ := val.AsFloat(); f != 50 {
+			t.Errorf("memory[0] = %v, want 50", val)
+		}
+	})
+
+	t.Run("without converter", func(t *testing.T) {
+		vm := NewWithConfig(Config{
+			StackSize:           256,
+			InstructionRegistry: registry,
+		})
+
+		_, err := vm.Execute(program, NewSimpleMemory(1), ExecuteOptions{})
+		if !errors.Is(err, ErrTypeMismatch) {
+			t.Errorf("Execute() error = %v, want ErrTypeMismatch", err)
+		}
+	})
+}
+
+func TestNewProgramCopiesInstructions(t *testing.T) {
+	instructions := []Instruction{
+		NewInstruction(OpPUSH, 1),
+		NewInstruction(OpHALT, 0),
+	}
+	program := NewProgram(instructions)
+	withMeta := NewProgramWithMetadata(instructions, ProgramMetadata{Name: "copy"})
+
+	instructions[0] = NewInstruction(OpPUSHI, 99)
+
+	for _, p := range []*SimpleProgram{program, withMeta} {
+		got := p.Instructions()[0]
+		if got.Opcode != OpPUSH || got.Operand != 1 {
+			t.Errorf("Instructions()[0] = %v, want PUSH 1", got)
+		}
+	}
+}
+
+func TestProgramInstructionAccessors(t *testing.T) {
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSH, 1),
+		NewInstruction(OpHALT, 0),
+	})
+
+	if program.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", program.Len())
+	}
+	if inst, ok := program.InstructionAt(1); !ok || inst.Opcode != OpHALT {
+		t.Errorf("InstructionAt(1) = %v, %v, want HALT, true", inst, ok)
+	}
+	if _, ok := program.InstructionAt(2); ok {
+		t.Error("InstructionAt(2) should report out of range")
+	}
+	if _, ok := program.InstructionAt(-1); ok {
+		t.Error("InstructionAt(-1) should report out of range")
+	}
+
+	// Mutating the returned slice must not change the program.
+	program.Instructions()[0] = NewInstruction(OpPUSHI, 99)
+	if inst, _ := program.InstructionAt(0); inst.Opcode != OpPUSH || inst.Operand != 1 {
+		t.Errorf("program changed after mutating Instructions(): %v", inst)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		program Program
+		want    int
+	}{
+		{"EXIT", mustBuild(t, NewProgramBuilder().PushInt(7).Exit()), 7},
+		{"HALT", mustBuild(t, NewProgramBuilder().PushInt(7).Halt()), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New().Execute(tt.program, NewSimpleMemory(0), ExecuteOptions{})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !result.Halted {
+				t.Error("Expected program to halt")
+			}
+			if result.ExitCode != tt.want {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, tt.want)
+			}
+		})
+	}
+
+	if _, err := New().Execute(NewProgram([]Instruction{NewInstruction(OpEXIT, 0)}), NewSimpleMemory(0), ExecuteOptions{}); err != ErrStackUnderflow {
+		t.Errorf("EXIT on empty stack error = %v, want ErrStackUnderflow", err)
+	}
+}
+
+func mustBuild(t *testing.T, b *ProgramBuilder) Program {
+	t.Helper()
+	program, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	return program
+}
+
+func TestLimitsReportCompletedInstructions(t *testing.T) {
+	const slowOp Opcode = 128
+	const cancelOp Opcode = 129
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewInstructionRegistry()
+	registry.Register(slowOp, &mockHandler{name: "SLOW", fn: func(ExecutionContext, int32) error {
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}})
+	registry.Register(cancelOp, &mockHandler{name: "CANCEL", fn: func(ExecutionContext, int32) error {
+		cancel()
+		return nil
+	}})
+	vm := NewWithConfig(Config{StackSize: 16, InstructionRegistry: registry})
+
+	repeat := func(op Opcode, n int) Program {
+		instructions := make([]Instruction, 0, n+1)
+		for i := 0; i < n; i++ {
+			instructions = append(instructions, NewInstruction(op, 0))
+		}
+		return NewProgram(append(instructions, NewInstruction(OpHALT, 0)))
+	}
+
+	tests := []struct {
+		name    string
+		program Program
+		opts    ExecuteOptions
+		wantErr error
+		want    uint32
+	}{
+		{
+			name:    "instruction limit",
+			program: repeat(OpNOP, 10),
+			opts:    ExecuteOptions{MaxInstructions: 4},
+			wantErr: ErrInstructionLimit,
+			want:    4,
+		},
+		{
+			// Each SLOW costs at least 30ms, so the deadline passes during the
+			// third and execution stops before the fourth.
+			name:    "timeout",
+			program: repeat(slowOp, 10),
+			opts:    ExecuteOptions{Timeout: 75 * time.Millisecond},
+			wantErr: ErrTimeout,
+			want:    3,
+		},
+		{
+			name: "cancellation",
+			program: NewProgram([]Instruction{
+				NewInstruction(OpNOP, 0),
+				NewInstruction(OpNOP, 0),
+				NewInstruction(cancelOp, 0),
+				NewInstruction(OpNOP, 0),
+				NewInstruction(OpHALT, 0),
+			}),
+			opts:    ExecuteOptions{Context: ctx},
+			wantErr: context.Canceled,
+			want:    3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := vm.Execute(tt.program, NewSimpleMemory(0), tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			if result.InstructionCount != tt.want {
+				t.Errorf("InstructionCount = %d, want %d", result.InstructionCount, tt.want)
+			}
+			if result.HaltPC != int(tt.want) {
+				t.Errorf("HaltPC = %d, want %d", result.HaltPC, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitialStack(t *testing.T) {
+	program := NewProgram([]Instruction{
+		NewInstruction(OpADD, 0),
+		NewInstruction(OpHALT, 0),
+	})
+
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+		InitialStack: []Value{IntValue(10), IntValue(5)},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result.Stack) != 1 {
+		t.Fatalf("Stack = %v, want one value", result.Stack)
+	}
+	if f, _ := result.Stack[0].AsFloat(); f != 15 {
+		t.Errorf("Stack[0] = %v, want 15", result.Stack[0])
+	}
+
+	// The seed counts against MaxStackDepth.
+	result, err = New().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+		InitialStack:  []Value{IntValue(1), IntValue(2), IntValue(3)},
+		MaxStackDepth: 2,
+	})
+	if !errors.Is(err, ErrStackOverflow) {
+		t.Errorf("Execute() error = %v, want ErrStackOverflow", err)
+	}
+	if result.InstructionCount != 0 {
+		t.Errorf("InstructionCount = %d, want 0", result.InstructionCount)
+	}
+}
+
+func TestWatchpoints(t *testing.T) {
+	program := MustAssemble(`
+		PUSHI 100
+		PUSH 3
+	loop:
+		PUSHI 1
+		SUB         ; address 3
+		DUP
+		JMPNZ loop
+		HALT        ; address 6
+	`)
+
+	var counters []float64
+	halts := 0
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+		Watchpoints: map[int]func([]Value){
+			3: func(stack []Value) {
+				if len(stack) != 3 {
+					t.Fatalf("stack before SUB = %v, want 3 values", stack)
+				}
+				f, _ := stack[1].AsFloat()
+				counters = append(counters, f)
+				stack[0] = IntValue(-1) // a copy; must not affect the VM
+			},
+			6: func([]Value) { halts++ },
+		},
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := []float64{3, 2, 1}
+	if len(counters) != len(want) {
+		t.Fatalf("counters seen = %v, want %v", counters, want)
+	}
+	for i := range want {
+		if counters[i] != want[i] {
+			t.Errorf("iteration %d: counter = %v, want %v", i, counters[i], want[i])
+		}
+	}
+	if halts != 1 {
+		t.Errorf("HALT watchpoint called %d times, want 1", halts)
+	}
+	if !result.Stack[0].Equal(IntValue(100)) {
+		t.Errorf("Stack[0] = %v, want 100", result.Stack[0])
+	}
+}
+
+func TestExecuteFrom(t *testing.T) {
+	program := MustAssemble(`
+		PUSHI 1
+		STORE 0
+		HALT
+	second:
+		PUSHI 6
+		PUSHI 7
+		MUL
+		STORE 0
+		LOAD 0
+		HALT
+	`)
+	start := -1
+	for addr, name := range program.SymbolTable() {
+		if name == "second" {
+			start = addr
+		}
+	}
+
+	memory := NewSimpleMemory(1)
+	result, err := New().ExecuteFrom(program, memory, start, ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("ExecuteFrom() error = %v", err)
+	}
+	if len(result.Stack) != 1 || !result.Stack[0].Equal(FloatValue(42)) {
+		t.Errorf("Stack = %v, want [42]", result.Stack)
+	}
+	if result.InstructionCount != 6 {
+		t.Errorf("InstructionCount = %d, want 6", result.InstructionCount)
+	}
+
+	for _, pc := range []int{-1, program.Len()} {
+		if _, err := New().ExecuteFrom(program, memory, pc, ExecuteOptions{}); !errors.Is(err, ErrInvalidOperand) {
+			t.Errorf("ExecuteFrom(%d) error = %v, want ErrInvalidOperand", pc, err)
+		}
+	}
+}
+
+func TestStrictOperands(t *testing.T) {
+	program := NewProgram([]Instruction{
+		NewInstruction(OpPUSHI, 2),
+		NewInstruction(OpPUSHI, 3),
+		NewInstruction(OpADD, 5),
+		NewInstruction(OpHALT, 0),
+	})
+
+	result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if f, _ := result.Stack[0].AsFloat(); f != 5 {
+		t.Errorf("Stack[0] = %v, want 5", result.Stack[0])
+	}
+
+	result, err = New().Execute(program, NewSimpleMemory(0), ExecuteOptions{StrictOperands: true})
+	var vmErr *VMError
+	if !errors.Is(err, ErrInvalidOperand) || !errors.As(err, &vmErr) || vmErr.PC != 2 {
+		t.Fatalf("Execute() error = %v, want ErrInvalidOperand at PC 2", err)
+	}
+	if result.StackDepth != 2 {
+		t.Errorf("StackDepth = %d, want ADD not executed", result.StackDepth)
+	}
+
+	// Operands of opcodes that take one are not affected.
+	if _, err := New().Execute(MustAssemble("PUSHI 7\nSTORE 0\nJMP end\nend: HALT"), NewSimpleMemory(1),
+		ExecuteOptions{StrictOperands: true}); err != nil {
+		t.Errorf("Execute() with operands error = %v", err)
+	}
+}
+
+func TestExpectStackDepth(t *testing.T) {
+	one := 1
+	tests := []struct {
+		name    string
+		source  string
+		wantErr bool
+	}{
+		{"single result", "PUSHI 2\nPUSHI 3\nMUL\nHALT", false},
+		{"end of program", "PUSHI 2\nPUSHI 3\nMUL", false},
+		{"leftover value", "PUSHI 1\nPUSHI 2\nPUSHI 3\nMUL\nHALT", true},
+		{"no result", "PUSHI 1\nPOP\nHALT", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New().Execute(MustAssemble(tt.source), NewSimpleMemory(0),
+				ExecuteOptions{ExpectStackDepth: &one})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Execute() error = %v", err)
+				}
+				return
+			}
+			var vmErr *VMError
+			if !errors.Is(err, ErrUnexpectedStackDepth) || !errors.As(err, &vmErr) {
+				t.Fatalf("Execute() error = %v, want ErrUnexpectedStackDepth", err)
+			}
+			if result.StackDepth == 1 || result.TerminationReason != TerminationError {
+				t.Errorf("StackDepth = %d, TerminationReason = %v", result.StackDepth, result.TerminationReason)
+			}
+		})
+	}
+
+	// Errors take precedence over the depth check.
+	_, err := New().Execute(MustAssemble("PUSHI 1\nPUSHI 0\nDIV\nHALT"), NewSimpleMemory(0),
+		ExecuteOptions{ExpectStackDepth: &one})
+	if !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Execute() error = %v, want ErrDivisionByZero", err)
+	}
+}
+
+func TestResultStackIsCopy(t *testing.T) {
+	vm := New()
+	program := NewProgram([]Instruction{NewInstruction(OpPUSHI, 1)})
+
+	first, _ := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+	vm.Execute(NewProgram([]Instruction{NewInstruction(OpPUSHI, 2)}), NewSimpleMemory(0), ExecuteOptions{})
+
+	if !first.Stack[0].Equal(IntValue(1)) {
+		t.Errorf("first Stack[0] = %v, want 1 after VM reuse", first.Stack[0])
+	}
+
+	empty, _ := vm.Execute(NewProgram(nil), NewSimpleMemory(0), ExecuteOptions{})
+	if empty.Stack != nil {
+		t.Errorf("Stack = %v, want nil for an empty stack", empty.Stack)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	program := mustBuild(t, NewProgramBuilder().
+		PushInt(1).Store(0).
+		PushInt(2).Store(1).
+		Halt())
+
+	t.Run("count stores", func(t *testing.T) {
+		stores := 0
+		var order []string
+		vm := NewWithConfig(Config{Middleware: []func(OpcodeFunc) OpcodeFunc{
+			func(next OpcodeFunc) OpcodeFunc {
+				return func(ctx ExecutionContext, inst Instruction) error {
+					if inst.Opcode == OpSTORE {
+						stores++
+						order = append(order, "outer")
+					}
+					return next(ctx, inst)
+				}
+			},
+			func(next OpcodeFunc) OpcodeFunc {
+				return func(ctx ExecutionContext, inst Instruction) error {
+					if inst.Opcode == OpSTORE {
+						order = append(order, "inner")
+					}
+					return next(ctx, inst)
+				}
+			},
+		}})
+		memory := NewSimpleMemory(2)
+		result, err := vm.Execute(program, memory, ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() failed: %v", err)
+		}
+		if stores != 2 {
+			t.Errorf("stores = %d, want 2", stores)
+		}
+		if len(order) != 4 || order[0] != "outer" || order[1] != "inner" {
+			t.Errorf("order = %v, want outer before inner", order)
+		}
+		if !result.Halted {
+			t.Error("program should halt")
+		}
+		if val, _ := memory.Load(1); !val.Equal(IntValue(2)) {
+			t.Errorf("memory[1] = %v, want 2", val)
+		}
+	})
+
+	t.Run("veto halt", func(t *testing.T) {
+		errVeto := errors.New("HALT not allowed")
+		vm := NewWithConfig(Config{Middleware: []func(OpcodeFunc) OpcodeFunc{
+			func(next OpcodeFunc) OpcodeFunc {
+				return func(ctx ExecutionContext, inst Instruction) error {
+					if inst.Opcode == OpHALT {
+						return errVeto
+					}
+					return next(ctx, inst)
+				}
+			},
+		}})
+		result, err := vm.Execute(program, NewSimpleMemory(2), ExecuteOptions{})
+		if !errors.Is(err, errVeto) {
+			t.Fatalf("Execute() error = %v, want veto error", err)
+		}
+		if result.Halted || result.InstructionCount != 5 {
+			t.Errorf("Halted = %v, InstructionCount = %d, want false, 5", result.Halted, result.InstructionCount)
+		}
+	})
+}
+
+func TestMaxMemoryBytes(t *testing.T) {
+	// GROW pushes a string ten bytes longer than the previous one.
+	newRegistry := func() InstructionRegistry {
+		length := 0
+		registry := NewInstructionRegistry()
+		registry.Register(150, &mockHandler{
+			name: "GROW",
+			fn: func(ctx ExecutionContext, operand int32) error {
+				length += 10
+				return ctx.Push(StringValue(strings.Repeat("x", length)))
+			},
+		})
+		return registry
+	}
+	program := mustBuild(t, NewProgramBuilder().
+		Label("loop").
+		Custom(150, 0).
+		Pop().
+		Jmp("loop"))
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		wantErr  error
+	}{
+		{"limit triggers", 1000, ErrMemoryLimit},
+		{"no limit", 0, ErrInstructionLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := NewWithConfig(Config{InstructionRegistry: newRegistry()})
+			result, err := vm.Execute(program, NewSimpleMemory(0), ExecuteOptions{
+				MaxMemoryBytes:  tt.maxBytes,
+				MaxInstructions: 300,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+			// 10+20+...+130 = 910 bytes fit; the 14th push reaches 1050.
+			if tt.wantErr == ErrMemoryLimit && result.InstructionCount != 40 {
+				t.Errorf("InstructionCount = %d, want 40", result.InstructionCount)
+			}
+		})
+	}
+
+	t.Run("PUSHC strings count", func(t *testing.T) {
+		program := mustBuild(t, NewProgramBuilder().
+			PushConst(StringValue("abcd")).
+			Dup().
+			Dup().
+			Halt())
+		_, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{MaxMemoryBytes: 10})
+		if !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("Execute() error = %v, want ErrMemoryLimit", err)
+		}
+	})
+
+	t.Run("CONCAT results count", func(t *testing.T) {
+		// The PUSHC strings alone would take 500 iterations to reach
+		// the limit; the growing CONCAT results reach it in about 30.
+		program := mustBuild(t, NewProgramBuilder().
+			PushConst(StringValue("ab")).
+			Label("loop").
+			PushConst(StringValue("cd")).
+			Concat().
+			Jmp("loop"))
+		_, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+			MaxMemoryBytes:  1000,
+			MaxInstructions: 300,
+		})
+		if !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("Execute() error = %v, want ErrMemoryLimit", err)
+		}
+	})
+}
+
+func TestMaxStackBytes(t *testing.T) {
+	chunk := StringValue(strings.Repeat("x", 100))
+
+	t.Run("limit triggers below depth limit", func(t *testing.T) {
+		program := mustBuild(t, NewProgramBuilder().
+			Label("loop").
+			PushConst(chunk).
+			Jmp("loop"))
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+			MaxStackBytes:   1000,
+			MaxInstructions: 1000,
+		})
+		if !errors.Is(err, ErrStackBytesLimit) {
+			t.Fatalf("Execute() error = %v, want ErrStackBytesLimit", err)
+		}
+		// Ten strings fit; the eleventh would make 1100 bytes.
+		if result.StackDepth != 10 {
+			t.Errorf("StackDepth = %d, want 10", result.StackDepth)
+		}
+	})
+
+	t.Run("popped values are released", func(t *testing.T) {
+		program := mustBuild(t, NewProgramBuilder().
+			Label("loop").
+			PushConst(chunk).
+			Dup().
+			Concat().
+			Pop().
+			Jmp("loop"))
+		_, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{
+			MaxStackBytes:   300,
+			MaxInstructions: 500,
+		})
+		if !errors.Is(err, ErrInstructionLimit) {
+			t.Errorf("Execute() error = %v, want ErrInstructionLimit", err)
+		}
+	})
+
+	t.Run("custom instructions count", func(t *testing.T) {
+		registry := NewInstructionRegistry()
+		registry.Register(150, &mockHandler{
+			name: "BLOB",
+			fn: func(ctx ExecutionContext, operand int32) error {
+				return ctx.Push(CustomValue(200, make([]byte, 64)))
+			},
+		})
+		program := mustBuild(t, NewProgramBuilder().Custom(150, 0).Custom(150, 0).Halt())
+		_, err := NewWithConfig(Config{InstructionRegistry: registry}).Execute(program, NewSimpleMemory(0),
+			ExecuteOptions{MaxStackBytes: 100})
+		if !errors.Is(err, ErrStackBytesLimit) {
+			t.Errorf("Execute() error = %v, want ErrStackBytesLimit", err)
+		}
+	})
+}
+
+func TestStackOverflowError(t *testing.T) {
+	instructions := make([]Instruction, 0, 11)
+	for i := 0; i < 10; i++ {
+		instructions = append(instructions, NewInstruction(OpPUSHI, int32(i)))
+	}
+	instructions = append(instructions, NewInstruction(OpHALT, 0))
+
+	_, err := New().Execute(NewProgram(instructions), NewSimpleMemory(0), ExecuteOptions{
+		MaxStackDepth: 8,
+	})
+
+	var overflow *StackOverflowError
+	if !errors.As(err, &overflow) {
+		t.Fatalf("Execute() error = %v, want StackOverflowError", err)
+	}
+	if overflow.Limit != 8 || overflow.Attempted != 9 {
+		t.Errorf("Limit = %d, Attempted = %d, want 8 and 9", overflow.Limit, overflow.Attempted)
+	}
+	if !errors.Is(err, ErrStackOverflow) {
+		t.Errorf("errors.Is(%v, ErrStackOverflow) = false", err)
+	}
+
+	var vmErr *VMError
+	if !errors.As(err, &vmErr) {
+		t.Fatalf("Execute() error = %v, want VMError", err)
+	}
+	if vmErr.PC != 8 || vmErr.Opcode != OpPUSHI || vmErr.StackDepth != 8 {
+		t.Errorf("VMError = %+v, want PC 8, PUSHI, stack depth 8", vmErr)
+	}
+	if !strings.Contains(err.Error(), "depth 9 exceeds limit 8") {
+		t.Errorf("Error() = %q, want limit and attempted depth", err.Error())
+	}
+}
+
+func TestResultString(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		program := NewProgram([]Instruction{
+			NewInstruction(OpPUSHI, 1),
+			NewInstruction(OpPUSHI, 2),
+			NewInstruction(OpHALT, 0),
+		})
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		s := result.String()
+		for _, want := range []string{"explicit halt", "after 3 instructions", "stack depth 2"} {
+			if !strings.Contains(s, want) {
+				t.Errorf("String() = %q, missing %q", s, want)
+			}
+		}
+		if strings.Contains(s, "exit code") {
+			t.Errorf("String() = %q, should not mention exit code 0", s)
+		}
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		program := NewProgram([]Instruction{
+			NewInstruction(OpPUSHI, 3),
+			NewInstruction(OpEXIT, 0),
+		})
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if s := result.String(); !strings.Contains(s, "exit code 3") {
+			t.Errorf("String() = %q, missing exit code", s)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		program := NewProgram([]Instruction{
+			NewInstruction(OpPUSHI, 1),
+			NewInstruction(OpADD, 0),
+		})
+		result, err := New().Execute(program, NewSimpleMemory(0), ExecuteOptions{})
+		if err == nil {
+			t.Fatal("Execute() should fail")
+		}
+		s := result.String()
+		for _, want := range []string{"error after 2 instructions", "stack underflow"} {
+			if !strings.Contains(s, want) {
+				t.Errorf("String() = %q, missing %q", s, want)
+			}
+		}
+	})
+}